@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoadCheckpoint returns the PR number an interrupted run of owner/repo had
+// processed every newer PR down to, or 0 without a checkpoint.
+func LoadCheckpoint(ctx context.Context, owner, repo string) (int, error) {
+	if Pool == nil {
+		return 0, errors.New("Postgres not connected")
+	}
+	var number int
+	err := Pool.QueryRow(ctx, `SELECT pr_number FROM scrape_checkpoints WHERE owner = $1 AND repo = $2`, owner, repo).Scan(&number)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return number, err
+}
+
+// SaveCheckpoint records that the run runID has processed every PR of
+// owner/repo newer than number, and number itself.
+func SaveCheckpoint(ctx context.Context, owner, repo string, number int, runID string) error {
+	if Pool == nil {
+		return errors.New("Postgres not connected")
+	}
+	_, err := Pool.Exec(ctx, `
+        INSERT INTO scrape_checkpoints (owner, repo, pr_number, run_id, updated_at)
+        VALUES ($1, $2, $3, $4, now())
+        ON CONFLICT (owner, repo) DO UPDATE SET
+            pr_number = EXCLUDED.pr_number,
+            run_id = EXCLUDED.run_id,
+            updated_at = EXCLUDED.updated_at
+    `, owner, repo, number, nullIfEmpty(runID))
+	return err
+}
+
+// ClearCheckpoint removes owner/repo's checkpoint, once a run finished.
+func ClearCheckpoint(ctx context.Context, owner, repo string) error {
+	if Pool == nil {
+		return errors.New("Postgres not connected")
+	}
+	_, err := Pool.Exec(ctx, `DELETE FROM scrape_checkpoints WHERE owner = $1 AND repo = $2`, owner, repo)
+	return err
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// StoredComments are the comment counts of a stored PR row, as written by
+// the run in RunID.
+type StoredComments struct {
+	RunID     string
+	Total     int
+	Issue     int
+	Review    int
+	Bot       int
+	Author    int
+	FirstDay  int
+	FirstWeek int
+	// Bots is nil when the row was stored without a bot breakdown.
+	Bots      map[string]int
+	Truncated bool
+}
+
+// StoredCommentCounts returns the comment counts of a repo's stored PRs by
+// number. Rows without a run ID are left out.
+func StoredCommentCounts(ctx context.Context, owner, repo string) (map[int]StoredComments, error) {
+	if Pool == nil {
+		return nil, errors.New("Postgres not connected")
+	}
+	rows, err := Pool.Query(ctx, `
+        SELECT split_part(id, ':', 1)::int, last_run_id, comment_count, issue_comments, review_comments, bot_comments, author_comments,
+               comments_first_day, comments_first_week, bot_comment_breakdown, comments_truncated
+        FROM prs
+        WHERE owner = $1 AND repo = $2 AND last_run_id IS NOT NULL
+    `, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[int]StoredComments)
+	for rows.Next() {
+		var (
+			number int
+			c      StoredComments
+		)
+		if err := rows.Scan(&number, &c.RunID, &c.Total, &c.Issue, &c.Review, &c.Bot, &c.Author, &c.FirstDay, &c.FirstWeek, &c.Bots, &c.Truncated); err != nil {
+			return nil, err
+		}
+		stored[number] = c
+	}
+	return stored, rows.Err()
+}
@@ -2,10 +2,14 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/dickeyy/github-scraper/types"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
@@ -14,58 +18,753 @@ var (
 	Pool *pgxpool.Pool
 )
 
-func Init(ctx context.Context) error {
-	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD"), os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_DB"))
-	pool, err := pgxpool.New(ctx, connString)
-	if err != nil {
+// prColumn is a prs column with the data_type Postgres reports for it in
+// information_schema.
+type prColumn struct {
+	name     string
+	dataType string
+}
+
+// prColumns lists the prs columns written by InsertPRRow, in the same order
+// as the values returned by prRowArgs.
+var prColumns = []prColumn{
+	{"id", "text"},
+	{"owner", "text"},
+	{"repo", "text"},
+	{"comment_count", "integer"},
+	{"github_comment_count", "integer"},
+	{"bot_comments", "integer"},
+	{"author_comments", "integer"},
+	{"lines_changed", "integer"},
+	{"stats_truncated", "boolean"},
+	{"files_added", "integer"},
+	{"files_modified", "integer"},
+	{"files_removed", "integer"},
+	{"status", "text"},
+	{"body_word_count", "integer"},
+	{"checklist_total", "integer"},
+	{"checklist_checked", "integer"},
+	{"created_at", "timestamp with time zone"},
+	{"open_duration_days", "double precision"},
+	{"merge_commit_sha", "text"},
+	{"base_sha", "text"},
+	{"head_sha", "text"},
+	{"checks", "ARRAY"},
+	{"auto_merged", "boolean"},
+	{"auto_merge_enabled_by", "text"},
+	{"commit_count", "integer"},
+	{"commit_source", "text"},
+	{"bot_comment_breakdown", "jsonb"},
+	{"reviewers", "ARRAY"},
+	{"node_id", "text"},
+	{"review_request_events", "integer"},
+	{"base_ref", "text"},
+	{"last_run_id", "text"},
+	{"title", "text"},
+	{"body", "text"},
+	{"mergeable", "text"},
+	{"resolved_threads", "integer"},
+	{"unresolved_threads", "integer"},
+	{"comments_first_day", "integer"},
+	{"comments_first_week", "integer"},
+	{"review_response_latency", "integer"},
+	{"comments_truncated", "boolean"},
+	{"dedup_group", "text"},
+	{"file_types", "jsonb"},
+	{"base_protected", "boolean"},
+	{"requires_approving_reviews", "boolean"},
+	{"required_approving_reviews", "integer"},
+	{"comment_sentiment", "double precision"},
+	{"origin", "text"},
+	{"closed_at", "timestamp with time zone"},
+	{"merged_at", "timestamp with time zone"},
+	{"author", "text"},
+	{"labels", "ARRAY"},
+	{"approved_reviews", "integer"},
+	{"changes_requested_reviews", "integer"},
+	{"commented_reviews", "integer"},
+	{"issue_comments", "integer"},
+	{"review_comments", "integer"},
+	{"state", "text"},
+	{"additions", "integer"},
+	{"deletions", "integer"},
+}
+
+// keptColumns are only written when a flag asks for them. Upserting NULL
+// into one keeps the stored value, so a run without the flag does not wipe
+// what an earlier run with it stored.
+var keptColumns = map[string]bool{
+	// -include-files
+	"files_added":    true,
+	"files_modified": true,
+	"files_removed":  true,
+	"file_types":     true,
+	// -include-body
+	"body_word_count":   true,
+	"checklist_total":   true,
+	"checklist_checked": true,
+	// -store-bodies, possibly redacted
+	"title": true,
+	"body":  true,
+	// -include-review-latency
+	"review_response_latency": true,
+	// -analyze-sentiment
+	"comment_sentiment": true,
+	// -include-review-counts
+	"approved_reviews":          true,
+	"changes_requested_reviews": true,
+	"commented_reviews":         true,
+	// -include-auto-merge
+	"auto_merged": true,
+	// -include-checks
+	"checks": true,
+	// -include-commits
+	"commit_count":  true,
+	"commit_source": true,
+	// -bot-breakdown
+	"bot_comment_breakdown": true,
+	// -include-reviewers
+	"reviewers": true,
+	// -include-timeline
+	"review_request_events": true,
+	// -include-review-threads
+	"resolved_threads":   true,
+	"unresolved_threads": true,
+	// -include-protection
+	"base_protected":             true,
+	"requires_approving_reviews": true,
+	"required_approving_reviews": true,
+	// GitHub's own count, which only GraphQL enumeration reports
+	"github_comment_count": true,
+}
+
+// keptWith maps columns that are NULL for most rows even with their flag,
+// such as a pending auto-merge's enabler, to the kept column written with
+// them. They keep the stored value exactly when that column does.
+var keptWith = map[string]string{
+	"auto_merge_enabled_by": "auto_merged",
+}
+
+// prevColumns keep each row's values from the run before its last one; they
+// are maintained by the upsert rather than written directly.
+var prevColumns = []struct {
+	prColumn
+	from string
+}{
+	{prColumn{"prev_run_id", "text"}, "last_run_id"},
+	{prColumn{"prev_status", "text"}, "status"},
+	{prColumn{"prev_comment_count", "integer"}, "comment_count"},
+}
+
+// ConnectOptions controls how long Connect waits for Postgres to come up
+// and, for Init, how a missing prs table is created.
+type ConnectOptions struct {
+	// Partitioned creates prs as a table partitioned by owner, with a
+	// partition added for each owner on first insert. It only applies when
+	// prs does not exist yet; an existing table is used as it is.
+	Partitioned bool
+	// Retries is the number of extra connection attempts after the first.
+	Retries int
+	// Interval is the wait before the first retry; it doubles after each
+	// failed retry, up to maxConnectInterval.
+	Interval time.Duration
+	// FoldIDCase lowercases owner and repo in the ids rows are stored
+	// under, so runs given differently-cased names write the same rows.
+	// Init then also folds ids already stored with other casing.
+	FoldIDCase bool
+}
+
+// foldIDCase is set by Init from ConnectOptions.FoldIDCase.
+var foldIDCase bool
+
+const maxConnectInterval = 30 * time.Second
+
+// Init connects to Postgres and creates or migrates the prs table. With
+// copts.FoldIDCase it then lowercases stored ids; see foldStoredIDs.
+func Init(ctx context.Context, copts ConnectOptions) error {
+	if err := Connect(ctx, copts); err != nil {
 		return err
 	}
-	if err := pool.Ping(ctx); err != nil {
-		pool.Close()
+	if err := ensureSchema(ctx, copts.Partitioned); err != nil {
+		return err
+	}
+	foldIDCase = copts.FoldIDCase
+	if !foldIDCase {
+		return nil
+	}
+	return foldStoredIDs(ctx)
+}
+
+// Connect connects to Postgres without touching the schema, retrying while
+// the server is not reachable yet (e.g. during container startup).
+func Connect(ctx context.Context, copts ConnectOptions) error {
+	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD"), os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_DB"))
+	err := retryConnect(ctx, copts, func(ctx context.Context) error {
+		pool, err := pgxpool.New(ctx, connString)
+		if err != nil {
+			return err
+		}
+		if err := pool.Ping(ctx); err != nil {
+			pool.Close()
+			return err
+		}
+		Pool = pool
+		return nil
+	})
+	if err != nil {
 		return err
 	}
-	Pool = pool
 	log.Info().Msg("connected to Postgres")
-	return ensureSchema(ctx)
+	return nil
+}
+
+// retryConnect calls dial until it succeeds, the retries are used up, or ctx
+// is cancelled, returning dial's last error in the latter cases.
+func retryConnect(ctx context.Context, copts ConnectOptions, dial func(context.Context) error) error {
+	wait := copts.Interval
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		err := dial(ctx)
+		if err == nil || attempt >= copts.Retries {
+			return err
+		}
+		log.Warn().Err(err).Int("attempt", attempt+1).Int("retries", copts.Retries).Dur("sleep_for", wait).Msg("Postgres not reachable yet; retrying")
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, maxConnectInterval)
+	}
 }
 
-func ensureSchema(ctx context.Context) error {
+func ensureSchema(ctx context.Context, partition bool) error {
+	// Unique keys of a partitioned table must include the partition key.
+	// ids embed the owner, so (id, owner) is as unique as id alone, up to
+	// the owner's casing, which deleteRenamed reconciles.
+	key, tail := "PRIMARY KEY (id)", ""
+	if partition {
+		key, tail = "PRIMARY KEY (id, owner)", " PARTITION BY LIST (owner)"
+	}
 	_, err := Pool.Exec(ctx, `
         CREATE TABLE IF NOT EXISTS prs (
-            id TEXT PRIMARY KEY,
+            id TEXT NOT NULL,
             repo TEXT NOT NULL,
             owner TEXT NOT NULL,
             comment_count INTEGER NOT NULL,
             bot_comments INTEGER NOT NULL DEFAULT 0,
             lines_changed INTEGER NOT NULL,
             status TEXT NOT NULL DEFAULT 'open',
+            created_at TIMESTAMPTZ NOT NULL,
+            `+key+`
+        )`+tail)
+	if err != nil {
+		return err
+	}
+	if err := checkIDType(ctx); err != nil {
+		return err
+	}
+	if partitioned, err = isPartitioned(ctx); err != nil {
+		return err
+	}
+	if partition && !partitioned {
+		log.Warn().Msg("prs already exists and is not partitioned; -partitioned only applies to a new table")
+	}
+	nodeIDKey := `CREATE UNIQUE INDEX IF NOT EXISTS prs_node_id_key ON prs (node_id)`
+	// Deployments reference prs by id, which a partitioned prs cannot
+	// enforce; replaceDeployments and deleteRenamed keep them in step
+	// instead.
+	deploymentsRef := " REFERENCES prs(id) ON DELETE CASCADE"
+	if partitioned {
+		nodeIDKey = `CREATE UNIQUE INDEX IF NOT EXISTS prs_node_id_key ON prs (node_id, owner)`
+		deploymentsRef = ""
+	}
+
+	// Columns added after the initial schema; applied to existing tables too.
+	migrations := []string{
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS merge_commit_sha TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS base_sha TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS head_sha TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS author_comments INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS open_duration_days DOUBLE PRECISION`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS files_added INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS files_modified INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS files_removed INTEGER`,
+		// NULL when files were not fetched; tables created before that
+		// stored 0.
+		`ALTER TABLE prs ALTER COLUMN files_added DROP NOT NULL`,
+		`ALTER TABLE prs ALTER COLUMN files_modified DROP NOT NULL`,
+		`ALTER TABLE prs ALTER COLUMN files_removed DROP NOT NULL`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS github_comment_count INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS stats_truncated BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS body_word_count INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS checklist_total INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS checklist_checked INTEGER`,
+		// NULL when the body was not fetched; tables created before
+		// that stored 0.
+		`ALTER TABLE prs ALTER COLUMN body_word_count DROP NOT NULL`,
+		`ALTER TABLE prs ALTER COLUMN checklist_total DROP NOT NULL`,
+		`ALTER TABLE prs ALTER COLUMN checklist_checked DROP NOT NULL`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS checks TEXT[]`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS auto_merged BOOLEAN`,
+		// NULL without -include-auto-merge; tables created before that
+		// stored false.
+		`ALTER TABLE prs ALTER COLUMN auto_merged DROP NOT NULL`,
+		`ALTER TABLE prs ALTER COLUMN auto_merged DROP DEFAULT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS auto_merge_enabled_by TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS commit_count INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS commit_source TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS bot_comment_breakdown JSONB`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS reviewers TEXT[]`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS node_id TEXT`,
+		nodeIDKey,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS review_request_events INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS base_ref TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS last_run_id TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS title TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS body TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS mergeable TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS resolved_threads INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS unresolved_threads INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS comments_first_day INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS comments_first_week INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS review_response_latency INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS comments_truncated BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS dedup_group TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS file_types JSONB`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS base_protected BOOLEAN`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS requires_approving_reviews BOOLEAN`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS required_approving_reviews INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS comment_sentiment DOUBLE PRECISION`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS origin TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS merged_at TIMESTAMPTZ`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS labels TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS approved_reviews INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS changes_requested_reviews INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS commented_reviews INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS issue_comments INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS review_comments INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS prev_run_id TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS prev_status TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS prev_comment_count INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS state TEXT`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS additions INTEGER`,
+		`ALTER TABLE prs ADD COLUMN IF NOT EXISTS deletions INTEGER`,
+	}
+	for _, m := range migrations {
+		if _, err := Pool.Exec(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	_, err = Pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS pr_deployments (
+            pr_id TEXT NOT NULL`+deploymentsRef+`,
+            environment TEXT NOT NULL,
             created_at TIMESTAMPTZ NOT NULL
         );
+        CREATE INDEX IF NOT EXISTS pr_deployments_pr_id_idx ON pr_deployments (pr_id);
+        CREATE TABLE IF NOT EXISTS repos (
+            owner TEXT NOT NULL,
+            name TEXT NOT NULL,
+            license TEXT,
+            topics TEXT[] NOT NULL DEFAULT '{}',
+            updated_at TIMESTAMPTZ NOT NULL,
+            PRIMARY KEY (owner, name)
+        );
+        CREATE TABLE IF NOT EXISTS scrape_checkpoints (
+            owner TEXT NOT NULL,
+            repo TEXT NOT NULL,
+            pr_number INTEGER NOT NULL,
+            run_id TEXT,
+            updated_at TIMESTAMPTZ NOT NULL,
+            PRIMARY KEY (owner, repo)
+        );
+        ALTER TABLE repos ADD COLUMN IF NOT EXISTS total_issues INTEGER;
+        ALTER TABLE repos ADD COLUMN IF NOT EXISTS open_issues INTEGER;
     `)
 	return err
 }
 
-func InsertPRRow(ctx context.Context, row types.PRRow) error {
+// foldStoredIDs is the migration to lowercase ids, for tables written by
+// runs without FoldIDCase. Of rows whose ids differ only in case the most
+// recently scraped one is kept; the others are deleted with their
+// deployments, which in a partitioned prs are not deleted by cascade.
+// Deployments are rewritten in the same statement as their rows so the
+// foreign key holds at its end. Once folded, a table has nothing left to do.
+func foldStoredIDs(ctx context.Context) error {
+	var mixed int
+	if err := Pool.QueryRow(ctx, `SELECT count(*) FROM prs WHERE id <> lower(id)`).Scan(&mixed); err != nil {
+		return err
+	}
+	if mixed == 0 {
+		return nil
+	}
+	log.Info().Int("ids", mixed).Msg("migrating: lowercasing PR ids stored with upper-case owner or repo; of rows that then collide, the most recently scraped is kept")
+	dedupe := `
+        WITH gone AS (
+            DELETE FROM prs USING prs newer
+            WHERE lower(prs.id) = lower(newer.id)
+                AND (COALESCE(prs.last_run_id, ''), prs.id, prs.owner) < (COALESCE(newer.last_run_id, ''), newer.id, newer.owner)
+            RETURNING prs.id
+        )`
+	if partitioned {
+		dedupe += `, deployments AS (
+            DELETE FROM pr_deployments WHERE pr_id IN (SELECT id FROM gone)
+        )`
+	}
+	var deleted int64
+	if err := Pool.QueryRow(ctx, dedupe+`
+        SELECT count(*) FROM gone`).Scan(&deleted); err != nil {
+		return err
+	}
+	tag, err := Pool.Exec(ctx, `
+        WITH deployments AS (
+            UPDATE pr_deployments SET pr_id = lower(pr_id) WHERE pr_id <> lower(pr_id)
+        )
+        UPDATE prs SET id = lower(id) WHERE id <> lower(id)`)
+	if err != nil {
+		return err
+	}
+	log.Info().Int64("lowercased", tag.RowsAffected()).Int64("deleted", deleted).Msg("migrated PR ids to lowercase")
+	return nil
+}
+
+// checkIDType refuses a prs table whose id is not text, e.g. one created by
+// hand with an integer id: ids combine number, owner and repo, so an integer
+// column would reject them or collide PRs of the same number across repos.
+func checkIDType(ctx context.Context) error {
+	var dataType string
+	err := Pool.QueryRow(ctx, `
+        SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'prs' AND column_name = 'id'
+    `).Scan(&dataType)
+	if err != nil {
+		return err
+	}
+	if dataType != "text" {
+		return fmt.Errorf("prs.id has type %s, but ids are stored as text \"<number>:<owner>:<repo>\"; convert it with: ALTER TABLE prs ALTER COLUMN id TYPE TEXT USING id || ':' || owner || ':' || repo", dataType)
+	}
+	return nil
+}
+
+// prID is the id a row is stored under. GitHub names are case-insensitive,
+// so with foldIDCase owner and repo are lowercased: runs given
+// differently-cased names write the same row.
+func prID(row types.PRRow) string {
 	id := fmt.Sprintf("%d:%s:%s", row.ID, row.Owner, row.Repo)
-	_, err := Pool.Exec(ctx, `
-        INSERT INTO prs (id, owner, repo, comment_count, bot_comments, lines_changed, status, created_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-        ON CONFLICT (id)
+	if foldIDCase {
+		return strings.ToLower(id)
+	}
+	return id
+}
+
+// prRowArgs returns the insert arguments for a row, matching prColumns.
+func prRowArgs(row types.PRRow) []any {
+	return []any{
+		prID(row),
+		row.Owner,
+		row.Repo,
+		row.CommentCount,
+		row.GitHubCommentCount,
+		row.BotComments,
+		row.AuthorComments,
+		row.LinesChanged,
+		row.StatsTruncated,
+		row.FilesAdded,
+		row.FilesModified,
+		row.FilesRemoved,
+		row.Status,
+		row.BodyWordCount,
+		row.ChecklistTotal,
+		row.ChecklistChecked,
+		row.CreatedAt,
+		row.OpenDuration,
+		nullIfEmpty(row.MergeCommitSHA),
+		nullIfEmpty(row.BaseSHA),
+		nullIfEmpty(row.HeadSHA),
+		row.Checks,
+		row.AutoMerged,
+		nullIfEmpty(row.AutoMergeEnabledBy),
+		row.CommitCount,
+		nullIfEmpty(row.CommitSource),
+		nullIfNilMap(row.BotCommentBreakdown),
+		row.Reviewers,
+		nullIfEmpty(row.NodeID),
+		row.ReviewRequestEvents,
+		nullIfEmpty(row.BaseRef),
+		nullIfEmpty(row.LastRunID),
+		nullIfEmpty(row.Title),
+		nullIfEmpty(row.Body),
+		nullIfEmpty(row.Mergeable),
+		row.ResolvedThreads,
+		row.UnresolvedThreads,
+		row.CommentsFirstDay,
+		row.CommentsFirstWeek,
+		row.ReviewResponseLatency,
+		row.CommentsTruncated,
+		nullIfEmpty(row.DedupGroup),
+		nullIfNilMap(row.FileTypes),
+		row.BaseProtected,
+		row.RequiresApprovingReviews,
+		row.RequiredApprovingReviews,
+		row.CommentSentiment,
+		nullIfEmpty(row.Origin),
+		row.ClosedAt,
+		row.MergedAt,
+		row.Author,
+		emptyIfNil(row.Labels),
+		row.ApprovedReviews,
+		row.ChangesRequestedReviews,
+		row.CommentedReviews,
+		row.IssueComments,
+		row.ReviewComments,
+		nullIfEmpty(row.State),
+		row.Additions,
+		row.Deletions,
+	}
+}
+
+// upsertPRSQL builds the INSERT ... ON CONFLICT statement for prColumns.
+func upsertPRSQL() string {
+	placeholders := make([]string, len(prColumns))
+	for i := range prColumns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return upsertPRSQLValues(placeholders)
+}
+
+// upsertPRSQLValues builds the upsert with the given value expressions, one
+// per prColumns entry.
+func upsertPRSQLValues(values []string) string {
+	return upsertPRSQLTuples([]string{"(" + strings.Join(values, ", ") + ")"})
+}
+
+// upsertPRSQLTuples builds the upsert of several rows, each a parenthesized
+// list of value expressions.
+func upsertPRSQLTuples(tuples []string) string {
+	names := make([]string, len(prColumns))
+	updates := make([]string, 0, len(prColumns)-1)
+	for i, c := range prColumns {
+		names[i] = c.name
+		switch {
+		case c.name == "id":
+		case keptColumns[c.name]:
+			updates = append(updates, fmt.Sprintf("%s = COALESCE(EXCLUDED.%s, prs.%s)", c.name, c.name, c.name))
+		case keptWith[c.name] != "":
+			updates = append(updates, fmt.Sprintf("%s = CASE WHEN EXCLUDED.%s IS NULL THEN prs.%s ELSE EXCLUDED.%s END", c.name, keptWith[c.name], c.name, c.name))
+		default:
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", c.name, c.name))
+		}
+	}
+	// Roll the stored values into prev_* once per run; re-upserts within
+	// the same run keep the earlier snapshot. SET reads the old row, so
+	// the order of assignments doesn't matter.
+	for _, c := range prevColumns {
+		updates = append(updates, fmt.Sprintf("%s = CASE WHEN prs.last_run_id IS DISTINCT FROM EXCLUDED.last_run_id THEN prs.%s ELSE prs.%s END", c.name, c.from, c.name))
+	}
+	conflict := "id"
+	if partitioned {
+		conflict = "id, owner"
+	}
+	return fmt.Sprintf(`
+        INSERT INTO prs (%s)
+        VALUES %s
+        ON CONFLICT (%s)
         DO UPDATE SET
-            owner = EXCLUDED.owner,
-            repo = EXCLUDED.repo,
-            comment_count = EXCLUDED.comment_count,
-            bot_comments = EXCLUDED.bot_comments,
-            lines_changed = EXCLUDED.lines_changed,
-            status = EXCLUDED.status,
-            created_at = EXCLUDED.created_at;
-    `, id, row.Owner, row.Repo, row.CommentCount, row.BotComments, row.LinesChanged, row.Status, row.CreatedAt)
-	if err == nil {
-		log.Debug().Str("id", id).Str("owner", row.Owner).Str("repo", row.Repo).Msg("inserted PR row")
+            %s;
+    `, strings.Join(names, ", "), strings.Join(tuples, ",\n            "), conflict, strings.Join(updates, ",\n            "))
+}
+
+// InsertPRRow upserts a row in one transaction. A stored row with the same
+// node ID under a different id (the repo was renamed or transferred) is
+// replaced, and when the row carries deployments they replace the stored
+// ones, so a re-scrape also drops deployments that no longer exist.
+func InsertPRRow(ctx context.Context, row types.PRRow) error {
+	args := prRowArgs(row)
+	id := args[0]
+
+	if partitioned {
+		if err := ensurePartition(ctx, row.Owner); err != nil {
+			return err
+		}
+	}
+
+	tx, err := Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if row.NodeID != "" {
+		if err := deleteRenamed(ctx, tx, []string{row.NodeID}, []string{id.(string)}, []string{row.Owner}); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, upsertPRSQL(), args...); err != nil {
+		return err
+	}
+	if row.Deployments != nil {
+		if err := replaceDeployments(ctx, tx, id, row.Deployments); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Debug().Str("id", id.(string)).Str("owner", row.Owner).Str("repo", row.Repo).Msg("inserted PR row")
+	return nil
+}
+
+// maxBindParams is Postgres's limit on parameters per statement.
+const maxBindParams = 65535
+
+// InsertPRRows upserts rows like InsertPRRow, but all in one transaction
+// with multi-row statements, saving a round trip per row. Of rows sharing
+// an id the last wins. It does nothing without a connection.
+func InsertPRRows(ctx context.Context, rows []types.PRRow) error {
+	if Pool == nil || len(rows) == 0 {
+		return nil
 	}
+	// One statement cannot upsert the same id twice.
+	byID := make(map[string]int, len(rows))
+	var args [][]any
+	for _, row := range rows {
+		a := prRowArgs(row)
+		id := a[0].(string)
+		if i, ok := byID[id]; ok {
+			args[i] = a
+			continue
+		}
+		byID[id] = len(args)
+		args = append(args, a)
+	}
+
+	if partitioned {
+		for _, row := range rows {
+			if err := ensurePartition(ctx, row.Owner); err != nil {
+				return err
+			}
+		}
+	}
+
+	tx, err := Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var nodeIDs, ids, owners []string
+	for _, row := range rows {
+		if row.NodeID != "" {
+			nodeIDs = append(nodeIDs, row.NodeID)
+			ids = append(ids, prID(row))
+			owners = append(owners, row.Owner)
+		}
+	}
+	if len(nodeIDs) > 0 {
+		if err := deleteRenamed(ctx, tx, nodeIDs, ids, owners); err != nil {
+			return err
+		}
+	}
+
+	perStmt := maxBindParams / len(prColumns)
+	for start := 0; start < len(args); start += perStmt {
+		chunk := args[start:min(start+perStmt, len(args))]
+		tuples := make([]string, len(chunk))
+		flat := make([]any, 0, len(chunk)*len(prColumns))
+		for i, a := range chunk {
+			placeholders := make([]string, len(a))
+			for j := range a {
+				placeholders[j] = fmt.Sprintf("$%d", len(flat)+j+1)
+			}
+			tuples[i] = "(" + strings.Join(placeholders, ", ") + ")"
+			flat = append(flat, a...)
+		}
+		if _, err := tx.Exec(ctx, upsertPRSQLTuples(tuples), flat...); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if row.Deployments != nil {
+			if err := replaceDeployments(ctx, tx, prID(row), row.Deployments); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Debug().Int("rows", len(rows)).Msg("inserted PR rows")
+	return nil
+}
+
+// deleteRenamed deletes stored rows that have one of nodeIDs under another
+// id than the matching one of ids, i.e. PRs of a renamed or transferred
+// repo. In a partitioned prs, whose deployments are not deleted by cascade,
+// their deployments go with them in the same statement; there a row under
+// the same id but a differently-cased owner sits in another partition, so
+// it goes too.
+func deleteRenamed(ctx context.Context, tx pgx.Tx, nodeIDs, ids, owners []string) error {
+	stmt := `
+        DELETE FROM prs USING unnest($1::text[], $2::text[], $3::text[]) AS renamed(node_id, id, owner)
+        WHERE prs.node_id = renamed.node_id AND prs.id <> renamed.id`
+	if partitioned {
+		// Deployments under a kept id belong to the row being upserted.
+		stmt = `
+        WITH gone AS (` + stmt + `
+                OR prs.node_id = renamed.node_id AND prs.owner <> renamed.owner
+            RETURNING prs.id
+        )
+        DELETE FROM pr_deployments
+        WHERE pr_id IN (SELECT id FROM gone) AND pr_id <> ALL ($2::text[])`
+	}
+	_, err := tx.Exec(ctx, stmt, nodeIDs, ids, owners)
 	return err
 }
 
+// replaceDeployments replaces the deployments stored for the PR with id.
+func replaceDeployments(ctx context.Context, tx pgx.Tx, id any, deployments []types.Deployment) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM pr_deployments WHERE pr_id = $1`, id); err != nil {
+		return err
+	}
+	for _, d := range deployments {
+		if _, err := tx.Exec(ctx, `INSERT INTO pr_deployments (pr_id, environment, created_at) VALUES ($1, $2, $3)`, id, d.Environment, d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullIfEmpty maps an empty string to a SQL NULL.
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// emptyIfNil maps a nil slice to an empty array rather than SQL NULL, for
+// columns that are always populated.
+func emptyIfNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// nullIfNilMap maps a nil map to a SQL NULL rather than JSON null.
+func nullIfNilMap(m map[string]int) any {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
 func Close() {
 	if Pool != nil {
 		Pool.Close()
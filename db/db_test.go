@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestRetryConnectSucceedsAfterFailures(t *testing.T) {
+	calls := 0
+	err := retryConnect(context.Background(), ConnectOptions{Retries: 5, Interval: time.Millisecond}, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retryConnect = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("dialled %d times, want 3", calls)
+	}
+}
+
+func TestRetryConnectGivesUp(t *testing.T) {
+	refused := errors.New("connection refused")
+	calls := 0
+	err := retryConnect(context.Background(), ConnectOptions{Retries: 2, Interval: time.Millisecond}, func(context.Context) error {
+		calls++
+		return refused
+	})
+	if !errors.Is(err, refused) {
+		t.Fatalf("retryConnect = %v, want the dial error", err)
+	}
+	if calls != 3 {
+		t.Errorf("dialled %d times, want 1 + 2 retries", calls)
+	}
+}
+
+func TestRetryConnectStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := retryConnect(ctx, ConnectOptions{Retries: 10, Interval: time.Hour}, func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("retryConnect = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("dialled %d times after cancel, want 1", calls)
+	}
+}
+
+func TestPRIDCase(t *testing.T) {
+	t.Cleanup(func() { foldIDCase = false })
+	tests := []struct {
+		fold bool
+		want string
+	}{
+		{false, "7:Facebook:React"},
+		{true, "7:facebook:react"},
+	}
+	for _, tt := range tests {
+		foldIDCase = tt.fold
+		if got := prID(types.PRRow{ID: 7, Owner: "Facebook", Repo: "React"}); got != tt.want {
+			t.Errorf("fold %v: prID = %q, want %q", tt.fold, got, tt.want)
+		}
+	}
+}
+
+func TestDifferentlyCasedRunsShareRows(t *testing.T) {
+	const owner = "github-scraper-test-case"
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		fold bool
+		want int
+	}{
+		{false, 2},
+		{true, 1},
+	} {
+		t.Run(fmt.Sprintf("fold=%v", tt.fold), func(t *testing.T) {
+			ctx := testDB(t, owner, ConnectOptions{FoldIDCase: tt.fold})
+			for _, o := range []string{"GitHub-Scraper-Test-Case", owner} {
+				if err := InsertPRRow(ctx, types.PRRow{ID: 1, Owner: o, Repo: "Demo", Status: "open", CreatedAt: created}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var n int
+			if err := Pool.QueryRow(ctx, `SELECT count(*) FROM prs WHERE lower(owner) = $1`, owner).Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("two differently-cased runs stored %d rows, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameNumberInTwoRepos(t *testing.T) {
+	const owner = "github-scraper-test-number"
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []types.PRRow{
+		{ID: 5, NodeID: "PR_a", Owner: owner, Repo: "alpha", Status: "open", CreatedAt: created},
+		{ID: 5, NodeID: "PR_b", Owner: owner, Repo: "beta", Status: "merged", CreatedAt: created},
+	}
+	for _, tt := range []struct {
+		name   string
+		insert func(context.Context) error
+	}{
+		{"one by one", func(ctx context.Context) error {
+			for _, r := range rows {
+				if err := InsertPRRow(ctx, r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"batched", func(ctx context.Context) error { return InsertPRRows(ctx, rows) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testDB(t, owner, ConnectOptions{})
+			if err := tt.insert(ctx); err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rows {
+				var status string
+				err := Pool.QueryRow(ctx, `SELECT status FROM prs WHERE owner = $1 AND repo = $2 AND id = $3`, owner, r.Repo, prID(r)).Scan(&status)
+				if err != nil {
+					t.Fatalf("#5 in %s: %v", r.Repo, err)
+				}
+				if status != r.Status {
+					t.Errorf("#5 in %s has status %q, want %q", r.Repo, status, r.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestFoldStoredIDs(t *testing.T) {
+	const owner = "github-scraper-test-fold"
+	ctx := testDB(t, owner, ConnectOptions{})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, o := range []string{"GitHub-Scraper-Test-Fold", owner} {
+		row := types.PRRow{ID: 1, Owner: o, Repo: "Demo", Status: "open", CreatedAt: created, LastRunID: fmt.Sprintf("run-%d", i)}
+		if err := InsertPRRow(ctx, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A run with -dedupe-repo-case migrates the rows written without it.
+	Close()
+	if err := Init(ctx, ConnectOptions{FoldIDCase: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { foldIDCase = false })
+	var id, runID string
+	var n int
+	err := Pool.QueryRow(ctx, `SELECT min(id), min(last_run_id), count(*) FROM prs WHERE lower(owner) = $1`, owner).Scan(&id, &runID, &n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || id != "1:"+owner+":demo" || runID != "run-1" {
+		t.Errorf("got %d rows, id %q from %s; want the newest row under 1:%s:demo", n, id, runID, owner)
+	}
+}
+
+func TestFlaglessRunKeepsOptInColumns(t *testing.T) {
+	const owner = "github-scraper-test-kept"
+	ctx := testDB(t, owner, ConnectOptions{})
+	n := func(v int) *int { return &v }
+	yes := true
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := types.PRRow{ID: 1, Owner: owner, Repo: "demo", Status: "open", CreatedAt: created}
+
+	full := base
+	full.LastRunID = "run-1"
+	full.Checks = []string{"ci:success"}
+	full.CommitCount, full.CommitSource = n(3), "pr"
+	full.BotCommentBreakdown = map[string]int{"dependabot[bot]": 2}
+	full.Reviewers = []string{"bob"}
+	full.ReviewRequestEvents = n(1)
+	full.ResolvedThreads, full.UnresolvedThreads = n(4), n(1)
+	full.BaseProtected, full.RequiresApprovingReviews, full.RequiredApprovingReviews = &yes, &yes, n(2)
+	full.GitHubCommentCount = n(5)
+	bare := base
+	bare.LastRunID = "run-2"
+	for _, r := range []types.PRRow{full, bare} {
+		if err := InsertPRRow(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got types.PRRow
+	err := Pool.QueryRow(ctx, `
+        SELECT checks, commit_count, commit_source, bot_comment_breakdown, reviewers, review_request_events,
+            resolved_threads, unresolved_threads, base_protected, requires_approving_reviews,
+            required_approving_reviews, github_comment_count
+        FROM prs WHERE id = $1`, prID(base)).Scan(
+		&got.Checks, &got.CommitCount, &got.CommitSource, &got.BotCommentBreakdown, &got.Reviewers, &got.ReviewRequestEvents,
+		&got.ResolvedThreads, &got.UnresolvedThreads, &got.BaseProtected, &got.RequiresApprovingReviews,
+		&got.RequiredApprovingReviews, &got.GitHubCommentCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.ID, got.Owner, got.Repo, got.Status, got.CreatedAt, got.LastRunID = full.ID, full.Owner, full.Repo, full.Status, full.CreatedAt, full.LastRunID
+	if !reflect.DeepEqual(got, full) {
+		t.Errorf("after a flagless run got %+v, want the opt-in values kept: %+v", got, full)
+	}
+}
+
+// testDB connects to the Postgres configured by the POSTGRES_* variables,
+// skipping the test when POSTGRES_HOST is unset, and deletes the rows of
+// owner before and after the test.
+func testDB(t *testing.T, owner string, copts ConnectOptions) context.Context {
+	t.Helper()
+	if os.Getenv("POSTGRES_HOST") == "" {
+		t.Skip("POSTGRES_HOST not set")
+	}
+	ctx := context.Background()
+	if err := Init(ctx, copts); err != nil {
+		t.Fatal(err)
+	}
+	clear := func() {
+		if _, err := Pool.Exec(ctx, `DELETE FROM prs WHERE lower(owner) = lower($1)`, owner); err != nil {
+			t.Error(err)
+		}
+	}
+	clear()
+	t.Cleanup(func() {
+		clear()
+		Close()
+		foldIDCase = false
+	})
+	return ctx
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RunDiff is what changed for a repo's PRs since a previous run.
+type RunDiff struct {
+	PreviousRunID string          `json:"previous_run_id"`
+	New           []int           `json:"new"`
+	StateChanged  []StateChange   `json:"state_changed"`
+	NewComments   []CommentChange `json:"new_comments"`
+}
+
+// StateChange is a PR whose status changed, e.g. open to merged.
+type StateChange struct {
+	Number int    `json:"number"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// CommentChange is a PR that gained comments.
+type CommentChange struct {
+	Number int `json:"number"`
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// LatestRunID returns the most recent run ID stored for a repo, or "" when
+// it has never been scraped with run IDs. Run IDs sort chronologically.
+func LatestRunID(ctx context.Context, owner, repo string) (string, error) {
+	if Pool == nil {
+		return "", errors.New("Postgres not connected")
+	}
+	var id *string
+	err := Pool.QueryRow(ctx, `SELECT max(last_run_id) FROM prs WHERE owner = $1 AND repo = $2`, owner, repo).Scan(&id)
+	if err != nil || id == nil {
+		return "", err
+	}
+	return *id, nil
+}
+
+// LatestCreatedAt returns the creation time of the newest PR stored for a
+// repo, or the zero time when none is stored.
+func LatestCreatedAt(ctx context.Context, owner, repo string) (time.Time, error) {
+	if Pool == nil {
+		return time.Time{}, errors.New("Postgres not connected")
+	}
+	var at *time.Time
+	err := Pool.QueryRow(ctx, `SELECT max(created_at) FROM prs WHERE owner = $1 AND repo = $2`, owner, repo).Scan(&at)
+	if err != nil || at == nil {
+		return time.Time{}, err
+	}
+	return *at, nil
+}
+
+// DiffSinceRun reports the repo's PRs stored by runs after previousRunID
+// that are new since then, changed status, or gained comments. Changes are
+// judged against each PR's values from the run before its latest one; PRs
+// first seen after previousRunID count as new.
+func DiffSinceRun(ctx context.Context, owner, repo, previousRunID string) (RunDiff, error) {
+	diff := RunDiff{PreviousRunID: previousRunID, New: []int{}, StateChanged: []StateChange{}, NewComments: []CommentChange{}}
+	if Pool == nil {
+		return diff, errors.New("Postgres not connected")
+	}
+	rows, err := Pool.Query(ctx, `
+        SELECT split_part(id, ':', 1)::int, status, comment_count, prev_run_id, prev_status, prev_comment_count
+        FROM prs
+        WHERE owner = $1 AND repo = $2 AND last_run_id > $3
+        ORDER BY 1 DESC
+    `, owner, repo, previousRunID)
+	if err != nil {
+		return diff, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			number, comments int
+			status           string
+			prevRun          *string
+			prevStatus       *string
+			prevComments     *int
+		)
+		if err := rows.Scan(&number, &status, &comments, &prevRun, &prevStatus, &prevComments); err != nil {
+			return diff, err
+		}
+		if prevRun == nil || *prevRun > previousRunID {
+			diff.New = append(diff.New, number)
+			continue
+		}
+		if prevStatus != nil && *prevStatus != status {
+			diff.StateChanged = append(diff.StateChanged, StateChange{Number: number, From: *prevStatus, To: status})
+		}
+		if prevComments != nil && comments > *prevComments {
+			diff.NewComments = append(diff.NewComments, CommentChange{Number: number, Before: *prevComments, After: comments})
+		}
+	}
+	return diff, rows.Err()
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestDiffSinceRun(t *testing.T) {
+	const owner, repo = "github-scraper-test-diff", "demo"
+	ctx := testDB(t, owner, ConnectOptions{})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	row := func(number int, status string, comments int, runID string) types.PRRow {
+		return types.PRRow{ID: number, Owner: owner, Repo: repo, Author: "alice", Status: status, CommentCount: comments, CreatedAt: created, LastRunID: runID}
+	}
+
+	// The previous run saw #1 to #3.
+	for _, r := range []types.PRRow{row(1, "open", 1, "run-1"), row(2, "open", 4, "run-1"), row(3, "open", 0, "run-1")} {
+		if err := InsertPRRow(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// This run merged #1, commented on #2, left #3 alone and found #4.
+	for _, r := range []types.PRRow{row(1, "merged", 1, "run-2"), row(2, "open", 6, "run-2"), row(3, "open", 0, "run-2"), row(4, "open", 0, "run-2")} {
+		if err := InsertPRRow(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := DiffSinceRun(ctx, owner, repo, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RunDiff{
+		PreviousRunID: "run-1",
+		New:           []int{4},
+		StateChanged:  []StateChange{{Number: 1, From: "open", To: "merged"}},
+		NewComments:   []CommentChange{{Number: 2, Before: 4, After: 6}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffSinceRun = %+v, want %+v", diff, want)
+	}
+}
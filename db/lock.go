@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// InstanceLock is a set of Postgres session advisory locks held on a
+// dedicated connection until Release.
+type InstanceLock struct {
+	conn *pgxpool.Conn
+	keys []int64
+}
+
+// InstanceLockKey derives the advisory lock key for a token and API host.
+// Only a hash of the token reaches Postgres.
+func InstanceLockKey(token, host string) int64 {
+	sum := sha256.Sum256([]byte(token + "\x00" + host))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// TryInstanceLock takes the advisory locks for keys without waiting, all
+// on one connection. busy lists the indexes of the keys another session
+// already holds; the returned lock holds the others, and is nil when it
+// holds none.
+func TryInstanceLock(ctx context.Context, keys ...int64) (lock *InstanceLock, busy []int, err error) {
+	if Pool == nil {
+		return nil, nil, errors.New("Postgres not connected")
+	}
+	conn, err := Pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := &InstanceLock{conn: conn}
+	for i, key := range keys {
+		var ok bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+			l.Release(ctx)
+			return nil, nil, err
+		}
+		if !ok {
+			busy = append(busy, i)
+			continue
+		}
+		l.keys = append(l.keys, key)
+	}
+	if len(l.keys) == 0 {
+		conn.Release()
+		return nil, busy, nil
+	}
+	return l, busy, nil
+}
+
+// Release unlocks and returns the connection to the pool. It must run
+// before Close, which waits for acquired connections.
+func (l *InstanceLock) Release(ctx context.Context) error {
+	var errs []error
+	for _, key := range l.keys {
+		if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	l.conn.Release()
+	return errors.Join(errs...)
+}
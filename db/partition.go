@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// partitioned is set by Init when prs is a partitioned table, whether it
+// was just created so or already existed that way.
+var partitioned bool
+
+// partitions remembers owners whose partition exists, so each is created
+// at most once per process.
+var partitions sync.Map
+
+// partitionMu serializes partition creation within the process; other
+// processes racing us are handled by tolerating "already exists".
+var partitionMu sync.Mutex
+
+// isPartitioned reports whether prs exists as a partitioned table.
+func isPartitioned(ctx context.Context) (bool, error) {
+	var ok bool
+	err := Pool.QueryRow(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM pg_partitioned_table p
+            JOIN pg_class c ON c.oid = p.partrelid
+            WHERE c.relname = 'prs' AND c.relnamespace = current_schema()::regnamespace
+        )
+    `).Scan(&ok)
+	return ok, err
+}
+
+var unsafePartitionChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// partitionName derives a table name for owner's partition. Owners are
+// case-sensitive partition values, so a hash keeps e.g. "Foo" and "foo"
+// apart after lowercasing and stays within identifier length limits.
+func partitionName(owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	slug := unsafePartitionChars.ReplaceAllString(strings.ToLower(owner), "_")
+	if len(slug) > 40 {
+		slug = slug[:40]
+	}
+	return fmt.Sprintf("prs_%s_%s", slug, hex.EncodeToString(sum[:4]))
+}
+
+// ensurePartition creates the prs partition for owner unless it is known
+// to exist. It runs outside the row's transaction so a lost creation race
+// doesn't abort the upsert.
+func ensurePartition(ctx context.Context, owner string) error {
+	if _, ok := partitions.Load(owner); ok {
+		return nil
+	}
+	partitionMu.Lock()
+	defer partitionMu.Unlock()
+	if _, ok := partitions.Load(owner); ok {
+		return nil
+	}
+	stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF prs FOR VALUES IN (%s)`,
+		pgx.Identifier{partitionName(owner)}.Sanitize(), quoteLiteral(owner))
+	if _, err := Pool.Exec(ctx, stmt); err != nil && !alreadyExists(err) {
+		return fmt.Errorf("create partition for owner %q: %w", owner, err)
+	}
+	partitions.Store(owner, struct{}{})
+	log.Debug().Str("owner", owner).Str("partition", partitionName(owner)).Msg("ensured prs partition")
+	return nil
+}
+
+// alreadyExists reports errors from concurrently creating the same table:
+// IF NOT EXISTS checks before the catalog insert, so the loser of a race
+// can still see a duplicate table or catalog row.
+func alreadyExists(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "42P07" || pgErr.Code == "23505"
+}
+
+// quoteLiteral quotes s as a SQL string literal for DDL, which cannot take
+// bind parameters, and for scripts rendered by RenderPRRow.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestPartitionName(t *testing.T) {
+	upper, lower := partitionName("Foo"), partitionName("foo")
+	if upper == lower {
+		t.Errorf("owners differing in case share partition %s", upper)
+	}
+	if !strings.HasPrefix(lower, "prs_foo_") {
+		t.Errorf("partitionName(foo) = %s", lower)
+	}
+	if long := partitionName(strings.Repeat("x", 100) + "-Org"); len(long) > 63 {
+		t.Errorf("partitionName is %d bytes, over Postgres's identifier limit", len(long))
+	}
+}
+
+// TestPartitionedInserts creates a partitioned prs in a schema of its own,
+// so it runs against any Postgres configured by POSTGRES_*.
+func TestPartitionedInserts(t *testing.T) {
+	if os.Getenv("POSTGRES_HOST") == "" {
+		t.Skip("POSTGRES_HOST not set")
+	}
+	ctx := context.Background()
+	schema := fmt.Sprintf("scraper_test_%d", time.Now().UnixNano())
+	if err := Connect(ctx, ConnectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pool.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+	t.Cleanup(func() {
+		Close()
+		if err := Connect(ctx, ConnectOptions{}); err != nil {
+			t.Error(err)
+			return
+		}
+		defer Close()
+		if _, err := Pool.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Setenv("PGOPTIONS", "-c search_path="+schema)
+	partitions.Clear()
+	if err := Init(ctx, ConnectOptions{Partitioned: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { partitioned = false })
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	merged := created.Add(time.Hour)
+	rows := []types.PRRow{
+		{ID: 1, NodeID: "PR_a", Owner: "alpha", Repo: "one", Status: "merged", CreatedAt: created, MergedAt: &merged,
+			Deployments: []types.Deployment{{Environment: "prod", CreatedAt: merged}}},
+		{ID: 2, NodeID: "PR_b", Owner: "Beta", Repo: "two", Status: "open", CreatedAt: created},
+	}
+	for _, r := range rows {
+		if err := InsertPRRow(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := InsertPRRows(ctx, []types.PRRow{{ID: 3, NodeID: "PR_c", Owner: "gamma", Repo: "three", Status: "open", CreatedAt: created}}); err != nil {
+		t.Fatal(err)
+	}
+	for owner, want := range map[string]int{"alpha": 1, "Beta": 1, "gamma": 1} {
+		var n int
+		q := "SELECT count(*) FROM " + pgx.Identifier{partitionName(owner)}.Sanitize()
+		if err := Pool.QueryRow(ctx, q).Scan(&n); err != nil {
+			t.Fatalf("partition for %s: %v", owner, err)
+		}
+		if n != want {
+			t.Errorf("partition for %s holds %d rows, want %d", owner, n, want)
+		}
+	}
+
+	// alpha/one moved to delta/one: the old row and its deployments go.
+	moved := rows[0]
+	moved.Owner, moved.Deployments = "delta", nil
+	if err := InsertPRRow(ctx, moved); err != nil {
+		t.Fatal(err)
+	}
+	var prs, deployments int
+	if err := Pool.QueryRow(ctx, `SELECT count(*) FROM prs WHERE owner = 'alpha'`).Scan(&prs); err != nil {
+		t.Fatal(err)
+	}
+	if err := Pool.QueryRow(ctx, `SELECT count(*) FROM pr_deployments WHERE pr_id = $1`, prID(rows[0])).Scan(&deployments); err != nil {
+		t.Fatal(err)
+	}
+	if prs != 0 || deployments != 0 {
+		t.Errorf("after the transfer %d old rows and %d of their deployments remain, want none", prs, deployments)
+	}
+}
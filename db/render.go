@@ -0,0 +1,110 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// RenderPRRow writes the statements InsertPRRow would run for row as a SQL
+// script with the values inlined, wrapped in the same transaction. It needs
+// no connection, so it always targets a non-partitioned prs table. The
+// output is meant for review and for applying through other channels; no
+// values are redacted.
+func RenderPRRow(w io.Writer, row types.PRRow) error {
+	args := prRowArgs(row)
+	values := make([]string, len(args))
+	for i, a := range args {
+		v, err := sqlLiteral(a)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", prColumns[i].name, err)
+		}
+		values[i] = v
+	}
+	id := values[0]
+
+	var b strings.Builder
+	b.WriteString("BEGIN;\n")
+	if row.NodeID != "" {
+		fmt.Fprintf(&b, "DELETE FROM prs WHERE node_id = %s AND id <> %s;\n", quoteLiteral(row.NodeID), id)
+	}
+	b.WriteString(strings.TrimSpace(upsertPRSQLValues(values)))
+	b.WriteString("\n")
+	if row.Deployments != nil {
+		fmt.Fprintf(&b, "DELETE FROM pr_deployments WHERE pr_id = %s;\n", id)
+		for _, d := range row.Deployments {
+			fmt.Fprintf(&b, "INSERT INTO pr_deployments (pr_id, environment, created_at) VALUES (%s, %s, %s);\n", id, quoteLiteral(d.Environment), timeLiteral(d.CreatedAt))
+		}
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sqlLiteral renders an insert argument the way pgx would bind it.
+func sqlLiteral(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteLiteral(v), nil
+	case *string:
+		if v == nil {
+			return "NULL", nil
+		}
+		return quoteLiteral(*v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case *int:
+		if v == nil {
+			return "NULL", nil
+		}
+		return strconv.Itoa(*v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case *bool:
+		if v == nil {
+			return "NULL", nil
+		}
+		return strconv.FormatBool(*v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case *float64:
+		if v == nil {
+			return "NULL", nil
+		}
+		return strconv.FormatFloat(*v, 'g', -1, 64), nil
+	case time.Time:
+		return timeLiteral(v), nil
+	case *time.Time:
+		if v == nil {
+			return "NULL", nil
+		}
+		return timeLiteral(*v), nil
+	case []string:
+		if v == nil {
+			return "NULL", nil
+		}
+		elems := make([]string, len(v))
+		for i, s := range v {
+			elems[i] = quoteLiteral(s)
+		}
+		return "ARRAY[" + strings.Join(elems, ", ") + "]::text[]", nil
+	case map[string]int:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return quoteLiteral(string(data)) + "::jsonb", nil
+	}
+	return "", fmt.Errorf("no SQL rendering for %T", v)
+}
+
+func timeLiteral(t time.Time) string {
+	return quoteLiteral(t.UTC().Format(time.RFC3339Nano)) + "::timestamptz"
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files under testdata")
+
+func TestRenderPRRowGolden(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	merged := created.Add(26 * time.Hour)
+	reviews := 2
+	row := types.PRRow{
+		ID:              42,
+		NodeID:          "PR_kwDOA",
+		Owner:           "octo",
+		Repo:            "demo",
+		Author:          "o'brien",
+		Status:          "merged",
+		CommentCount:    3,
+		IssueComments:   2,
+		ReviewComments:  1,
+		LinesChanged:    120,
+		CreatedAt:       created,
+		MergedAt:        &merged,
+		Labels:          []string{"bug", "needs review"},
+		ApprovedReviews: &reviews,
+		FileTypes:       map[string]int{".go": 3},
+		LastRunID:       "20240302T000000Z",
+		Deployments:     []types.Deployment{{Environment: "prod", CreatedAt: merged.Add(time.Hour)}},
+	}
+	var buf bytes.Buffer
+	if err := RenderPRRow(&buf, row); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "render.golden.sql")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("RenderPRRow output differs from %s (rerun with -update if the schema changed):\n%s", golden, buf.Bytes())
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	n := 7
+	var none *int
+	for _, tt := range []struct {
+		in   any
+		want string
+	}{
+		{"it's", "'it''s'"},
+		{&n, "7"},
+		{none, "NULL"},
+		{[]string{"a", "b'c"}, "ARRAY['a', 'b''c']::text[]"},
+		{[]string(nil), "NULL"},
+		{map[string]int{".go": 1}, `'{".go":1}'::jsonb`},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "'2024-01-02T03:04:05Z'::timestamptz"},
+	} {
+		got, err := sqlLiteral(tt.in)
+		if err != nil {
+			t.Fatalf("sqlLiteral(%#v): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("sqlLiteral(%#v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if _, err := sqlLiteral(struct{}{}); err == nil {
+		t.Error("sqlLiteral accepted an unsupported type")
+	}
+}
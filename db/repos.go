@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// RepoRow is a repository's row in the repos table.
+type RepoRow struct {
+	Owner string
+	Name  string
+	// License is the SPDX ID; empty (stored as NULL) when GitHub detected
+	// no license.
+	License string
+	// Topics is stored as an empty array, not NULL, when there are none.
+	Topics []string
+	// TotalIssues and OpenIssues are NULL when the repo has issues
+	// disabled.
+	TotalIssues *int
+	OpenIssues  *int
+}
+
+// UpsertRepo inserts or refreshes a repository's row in the repos table.
+func UpsertRepo(ctx context.Context, r RepoRow) error {
+	if Pool == nil {
+		return errors.New("Postgres not connected")
+	}
+	topics := r.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	_, err := Pool.Exec(ctx, `
+        INSERT INTO repos (owner, name, license, topics, total_issues, open_issues, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, now())
+        ON CONFLICT (owner, name) DO UPDATE SET
+            license = EXCLUDED.license,
+            topics = EXCLUDED.topics,
+            total_issues = EXCLUDED.total_issues,
+            open_issues = EXCLUDED.open_issues,
+            updated_at = EXCLUDED.updated_at
+    `, r.Owner, r.Name, nullIfEmpty(r.License), topics, r.TotalIssues, r.OpenIssues)
+	return err
+}
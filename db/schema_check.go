@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SchemaIssue describes a prs column that is missing or has an unexpected
+// type compared with what InsertPRRow writes.
+type SchemaIssue struct {
+	Column   string
+	Expected string
+	// Actual is empty when the column is missing.
+	Actual string
+}
+
+func (i SchemaIssue) String() string {
+	if i.Actual == "" {
+		return fmt.Sprintf("column %s is missing (expected %s)", i.Column, i.Expected)
+	}
+	return fmt.Sprintf("column %s has type %s (expected %s)", i.Column, i.Actual, i.Expected)
+}
+
+// CheckSchema compares the prs table in the current schema against the
+// columns the app writes, without modifying anything. Extra columns are
+// ignored.
+func CheckSchema(ctx context.Context) ([]SchemaIssue, error) {
+	if Pool == nil {
+		return nil, errors.New("Postgres not connected")
+	}
+	rows, err := Pool.Query(ctx, `
+        SELECT column_name, data_type
+        FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'prs'
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actual := make(map[string]string)
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		actual[name] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return compareSchema(actual), nil
+}
+
+// compareSchema reports expected columns absent from or mistyped in actual,
+// which maps column names to information_schema data types.
+func compareSchema(actual map[string]string) []SchemaIssue {
+	var issues []SchemaIssue
+	expected := append([]prColumn(nil), prColumns...)
+	for _, c := range prevColumns {
+		expected = append(expected, c.prColumn)
+	}
+	for _, c := range expected {
+		got, ok := actual[c.name]
+		if !ok {
+			issues = append(issues, SchemaIssue{Column: c.name, Expected: c.dataType})
+			continue
+		}
+		if got != c.dataType {
+			issues = append(issues, SchemaIssue{Column: c.name, Expected: c.dataType, Actual: got})
+		}
+	}
+	return issues
+}
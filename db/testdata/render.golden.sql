@@ -0,0 +1,71 @@
+BEGIN;
+DELETE FROM prs WHERE node_id = 'PR_kwDOA' AND id <> '42:octo:demo';
+INSERT INTO prs (id, owner, repo, comment_count, github_comment_count, bot_comments, author_comments, lines_changed, stats_truncated, files_added, files_modified, files_removed, status, body_word_count, checklist_total, checklist_checked, created_at, open_duration_days, merge_commit_sha, base_sha, head_sha, checks, auto_merged, auto_merge_enabled_by, commit_count, commit_source, bot_comment_breakdown, reviewers, node_id, review_request_events, base_ref, last_run_id, title, body, mergeable, resolved_threads, unresolved_threads, comments_first_day, comments_first_week, review_response_latency, comments_truncated, dedup_group, file_types, base_protected, requires_approving_reviews, required_approving_reviews, comment_sentiment, origin, closed_at, merged_at, author, labels, approved_reviews, changes_requested_reviews, commented_reviews, issue_comments, review_comments, state, additions, deletions)
+        VALUES ('42:octo:demo', 'octo', 'demo', 3, NULL, 0, 0, 120, false, NULL, NULL, NULL, 'merged', NULL, NULL, NULL, '2024-03-01T08:30:00Z'::timestamptz, 0, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, 'PR_kwDOA', NULL, NULL, '20240302T000000Z', NULL, NULL, NULL, NULL, NULL, 0, 0, NULL, false, NULL, '{".go":3}'::jsonb, NULL, NULL, NULL, NULL, NULL, NULL, '2024-03-02T10:30:00Z'::timestamptz, 'o''brien', ARRAY['bug', 'needs review']::text[], 2, NULL, NULL, 2, 1, NULL, 0, 0)
+        ON CONFLICT (id)
+        DO UPDATE SET
+            owner = EXCLUDED.owner,
+            repo = EXCLUDED.repo,
+            comment_count = EXCLUDED.comment_count,
+            github_comment_count = COALESCE(EXCLUDED.github_comment_count, prs.github_comment_count),
+            bot_comments = EXCLUDED.bot_comments,
+            author_comments = EXCLUDED.author_comments,
+            lines_changed = EXCLUDED.lines_changed,
+            stats_truncated = EXCLUDED.stats_truncated,
+            files_added = COALESCE(EXCLUDED.files_added, prs.files_added),
+            files_modified = COALESCE(EXCLUDED.files_modified, prs.files_modified),
+            files_removed = COALESCE(EXCLUDED.files_removed, prs.files_removed),
+            status = EXCLUDED.status,
+            body_word_count = COALESCE(EXCLUDED.body_word_count, prs.body_word_count),
+            checklist_total = COALESCE(EXCLUDED.checklist_total, prs.checklist_total),
+            checklist_checked = COALESCE(EXCLUDED.checklist_checked, prs.checklist_checked),
+            created_at = EXCLUDED.created_at,
+            open_duration_days = EXCLUDED.open_duration_days,
+            merge_commit_sha = EXCLUDED.merge_commit_sha,
+            base_sha = EXCLUDED.base_sha,
+            head_sha = EXCLUDED.head_sha,
+            checks = COALESCE(EXCLUDED.checks, prs.checks),
+            auto_merged = COALESCE(EXCLUDED.auto_merged, prs.auto_merged),
+            auto_merge_enabled_by = CASE WHEN EXCLUDED.auto_merged IS NULL THEN prs.auto_merge_enabled_by ELSE EXCLUDED.auto_merge_enabled_by END,
+            commit_count = COALESCE(EXCLUDED.commit_count, prs.commit_count),
+            commit_source = COALESCE(EXCLUDED.commit_source, prs.commit_source),
+            bot_comment_breakdown = COALESCE(EXCLUDED.bot_comment_breakdown, prs.bot_comment_breakdown),
+            reviewers = COALESCE(EXCLUDED.reviewers, prs.reviewers),
+            node_id = EXCLUDED.node_id,
+            review_request_events = COALESCE(EXCLUDED.review_request_events, prs.review_request_events),
+            base_ref = EXCLUDED.base_ref,
+            last_run_id = EXCLUDED.last_run_id,
+            title = COALESCE(EXCLUDED.title, prs.title),
+            body = COALESCE(EXCLUDED.body, prs.body),
+            mergeable = EXCLUDED.mergeable,
+            resolved_threads = COALESCE(EXCLUDED.resolved_threads, prs.resolved_threads),
+            unresolved_threads = COALESCE(EXCLUDED.unresolved_threads, prs.unresolved_threads),
+            comments_first_day = EXCLUDED.comments_first_day,
+            comments_first_week = EXCLUDED.comments_first_week,
+            review_response_latency = COALESCE(EXCLUDED.review_response_latency, prs.review_response_latency),
+            comments_truncated = EXCLUDED.comments_truncated,
+            dedup_group = EXCLUDED.dedup_group,
+            file_types = COALESCE(EXCLUDED.file_types, prs.file_types),
+            base_protected = COALESCE(EXCLUDED.base_protected, prs.base_protected),
+            requires_approving_reviews = COALESCE(EXCLUDED.requires_approving_reviews, prs.requires_approving_reviews),
+            required_approving_reviews = COALESCE(EXCLUDED.required_approving_reviews, prs.required_approving_reviews),
+            comment_sentiment = COALESCE(EXCLUDED.comment_sentiment, prs.comment_sentiment),
+            origin = EXCLUDED.origin,
+            closed_at = EXCLUDED.closed_at,
+            merged_at = EXCLUDED.merged_at,
+            author = EXCLUDED.author,
+            labels = EXCLUDED.labels,
+            approved_reviews = COALESCE(EXCLUDED.approved_reviews, prs.approved_reviews),
+            changes_requested_reviews = COALESCE(EXCLUDED.changes_requested_reviews, prs.changes_requested_reviews),
+            commented_reviews = COALESCE(EXCLUDED.commented_reviews, prs.commented_reviews),
+            issue_comments = EXCLUDED.issue_comments,
+            review_comments = EXCLUDED.review_comments,
+            state = EXCLUDED.state,
+            additions = EXCLUDED.additions,
+            deletions = EXCLUDED.deletions,
+            prev_run_id = CASE WHEN prs.last_run_id IS DISTINCT FROM EXCLUDED.last_run_id THEN prs.last_run_id ELSE prs.prev_run_id END,
+            prev_status = CASE WHEN prs.last_run_id IS DISTINCT FROM EXCLUDED.last_run_id THEN prs.status ELSE prs.prev_status END,
+            prev_comment_count = CASE WHEN prs.last_run_id IS DISTINCT FROM EXCLUDED.last_run_id THEN prs.comment_count ELSE prs.prev_comment_count END;
+DELETE FROM pr_deployments WHERE pr_id = '42:octo:demo';
+INSERT INTO pr_deployments (pr_id, environment, created_at) VALUES ('42:octo:demo', 'prod', '2024-03-02T11:30:00Z'::timestamptz);
+COMMIT;
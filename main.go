@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 	t "time"
 
 	"github.com/dickeyy/github-scraper/db"
 	"github.com/dickeyy/github-scraper/scraper"
 	"github.com/dickeyy/github-scraper/services"
+	"github.com/dickeyy/github-scraper/sinks"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -24,42 +29,1007 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to load .env file")
 	}
 
+	o, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid flags")
+	}
+
+	ctx := context.Background()
+	services.SetRequestBudget(o.MaxRequests)
+
+	if o.PrintRate {
+		if err := services.InitGitHub(ctx); err != nil {
+			log.Fatal().Err(err).Msg("failed to initialize GitHub client")
+		}
+		if err := services.PrintRateLimits(ctx, os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("failed to fetch rate limits")
+		}
+		return
+	}
+
+	if o.ListRepos {
+		if err := services.InitGitHub(ctx); err != nil {
+			log.Fatal().Err(err).Msg("failed to initialize GitHub client")
+		}
+		if err := printRepos(ctx, o.Org, o.Owner, o.RepoFilter); err != nil {
+			log.Fatal().Err(err).Msg("failed to list repos")
+		}
+		return
+	}
+
+	if o.SchemaCheck {
+		os.Exit(checkSchema(ctx, o.DB))
+	}
+
+	var repos []scraper.RepoRef
+	if o.ConfigFile != "" {
+		if repos, err = scraper.LoadBatchConfig(o.ConfigFile); err != nil {
+			log.Fatal().Err(err).Msg("failed to read config file")
+		}
+		if len(repos) == 0 {
+			log.Fatal().Str("file", o.ConfigFile).Msg("config file lists no repositories")
+		}
+	} else if o.ReposFile != "" {
+		if repos, err = scraper.ReadReposFile(o.ReposFile); err != nil {
+			log.Fatal().Err(err).Msg("failed to read repos file")
+		}
+		if len(repos) == 0 {
+			log.Fatal().Str("file", o.ReposFile).Msg("repos file lists no repositories")
+		}
+	}
+
+	if o.ETagCache != "" {
+		if err := services.SetETagCache(o.ETagCache); err != nil {
+			log.Fatal().Err(err).Str("dir", o.ETagCache).Msg("failed to create -etag-cache directory")
+		}
+	}
+
+	if err := services.InitGitHub(ctx); err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize GitHub client")
+	}
+	if err := services.InitGitHubGraphQL(ctx); err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize GitHub GraphQL client")
+	}
+
+	opts := o.Scrape
+	if o.Explain {
+		targets := repos
+		if targets == nil {
+			targets = []scraper.RepoRef{{Owner: o.Owner, Repo: o.Repo}}
+		}
+		eopts := services.EnumerateOptions{IncludeBody: opts.IncludeBody, IncludeChecks: opts.IncludeChecks, IncludeCommits: opts.IncludeCommits, IncludeDeployments: opts.IncludeDeployments, IncludeReviewers: opts.IncludeReviewers, IncludeTimeline: opts.IncludeTimeline, IncludeReviewThreads: opts.IncludeReviewThreads, IncludeReviewCounts: opts.IncludeReviewCounts, IncludeAutoMerge: opts.IncludeAutoMerge}
+		if err := explainCost(ctx, targets, eopts); err != nil {
+			log.Fatal().Err(err).Msg("failed to estimate query cost")
+		}
+		return
+	}
+
+	if o.Probe {
+		row, err := scraper.Probe(ctx, o.Owner, o.Repo, opts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("probe failed")
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(row); err != nil {
+			log.Fatal().Err(err).Msg("failed to print probed row")
+		}
+		return
+	}
+
+	var sink sinks.Sink
+	switch o.Output {
+	case "postgres":
+		if o.DryRunSQL != "" {
+			sc, err := sinks.NewSQLScript(o.DryRunSQL)
+			if err != nil {
+				log.Fatal().Err(err).Str("path", o.DryRunSQL).Msg("failed to create SQL script")
+			}
+			sink = sc
+			break
+		}
+		if err := db.Init(ctx, o.DB); err != nil {
+			log.Fatal().Err(err).Msg("failed to connect to Postgres")
+		}
+		defer db.Close()
+		if o.UniqueToken {
+			if lock := lockTokenHost(ctx, o.SingleInstance); lock != nil {
+				defer func() {
+					if err := lock.Release(context.Background()); err != nil {
+						log.Warn().Err(err).Msg("failed to release instance lock")
+					}
+				}()
+			}
+		}
+		if o.InsertBatch > 1 {
+			sink = sinks.NewPostgresBatch(o.InsertBatch)
+			break
+		}
+		sink = sinks.Postgres{}
+	case "clickhouse":
+		batch := 500
+		if o.InsertBatch > 1 {
+			batch = o.InsertBatch
+		}
+		ch, err := sinks.NewClickHouseFromEnv(ctx, batch)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to connect to ClickHouse")
+		}
+		sink = ch
+	case "jsonl":
+		if o.JSONPretty {
+			pj, err := sinks.NewPrettyJSON(o.OutputDir)
+			if err != nil {
+				log.Fatal().Err(err).Str("dir", o.OutputDir).Msg("failed to prepare JSON output")
+			}
+			sink = pj
+			break
+		}
+		js, err := sinks.NewJSONL(o.OutputDir, o.RotateSize)
+		if err != nil {
+			log.Fatal().Err(err).Str("dir", o.OutputDir).Msg("failed to prepare JSONL output")
+		}
+		sink = js
+	case "stream":
+		st, err := sinks.NewStream(ctx, o.StreamAddr)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to connect to stream consumer")
+		}
+		sink = st
+	case "kafka":
+		kf, err := sinks.NewKafka(ctx, o.KafkaBrokers, o.KafkaTopic)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to connect to Kafka")
+		}
+		sink = kf
+	case "table":
+		sink = sinks.NewTable(os.Stdout, o.TableLimit)
+	case "weekly":
+		wk, err := sinks.NewWeekly(os.Stdout, o.WeeklyFormat, o.WeeklyFillGaps)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid -weekly-format")
+		}
+		sink = wk
+	default:
+		log.Fatal().Str("output", o.Output).Msg("unknown -output; expected postgres, clickhouse, kafka, jsonl, stream, table, or weekly")
+	}
+
+	if o.GraphFile != "" {
+		sink = sinks.Multi{sink, sinks.NewGraph(o.GraphFile)}
+	}
+	if o.DedupeForks {
+		sink = sinks.NewDedupe(sink)
+	}
+	opts.Sink = sink
+
+	var stopProgress func()
+	if o.ProgressFD > 0 {
+		stopProgress = streamProgressFD(ctx, o.ProgressFD, &opts)
+	}
+
+	start := t.Now()
+	opts.RunID = scraper.NewRunID(start)
+
+	var (
+		stats     scraper.RunStats
+		repoStats []scraper.RunStats
+	)
+	if repos != nil {
+		repoStats, err = scraper.RunBatch(ctx, repos, scraper.BatchOptions{Options: opts, RepoDelay: o.RepoDelay, RepoConcurrency: o.RepoConcurrency, StateFile: o.BatchState})
+		stats = scraper.Aggregate(repoStats)
+	} else {
+		stats, err = scraper.Run(ctx, o.Owner, o.Repo, opts)
+		if stats.EndCursor != "" {
+			log.Info().Str("end_cursor", stats.EndCursor).Msg("GraphQL enumeration cursor; pass as -start-cursor to continue after it")
+		}
+	}
+	if stopProgress != nil {
+		stopProgress()
+	}
+	if o.OwnersReport != "" && stats.Ownership != nil {
+		if oerr := writeOwnershipReport(o.OwnersReport, stats.Ownership); oerr != nil {
+			log.Error().Err(oerr).Str("path", o.OwnersReport).Msg("failed to write CODEOWNERS report")
+		}
+	}
+	if stats.BudgetExhausted {
+		log.Warn().Int64("max_requests", o.MaxRequests).Msg("request budget exhausted; the scraped data is incomplete")
+	}
+
+	if cerr := sink.Close(); cerr != nil {
+		log.Error().Err(cerr).Str("output", o.Output).Msg("failed to flush output")
+		if err == nil {
+			err = cerr
+		}
+	}
+
+	if o.CompareRepos {
+		summaries := make([]scraper.RepoSummary, 0, len(repoStats))
+		for _, s := range repoStats {
+			if s.Summary != nil {
+				summaries = append(summaries, *s.Summary)
+			}
+		}
+		_ = scraper.SortSummaries(summaries, o.CompareBy) // validated by parseFlags
+		if cerr := scraper.RenderComparison(os.Stdout, summaries); cerr != nil {
+			log.Error().Err(cerr).Msg("failed to print repo comparison")
+		}
+	}
+	if o.DiffReport != "" {
+		if derr := writeDiffReport(o.DiffReport, stats, repoStats); derr != nil {
+			log.Error().Err(derr).Str("path", o.DiffReport).Msg("failed to write diff report")
+		}
+	}
+	if o.MetricsFile != "" {
+		m := scraper.RunMetrics{Stats: stats, Duration: t.Since(start), APIRequests: services.APIRequests(), Success: err == nil, FinishedAt: t.Now()}
+		if merr := scraper.WriteMetricsFile(o.MetricsFile, m); merr != nil {
+			log.Warn().Err(merr).Str("path", o.MetricsFile).Msg("failed to write metrics file")
+		}
+	}
+	if o.WebhookURL != "" {
+		notifyWebhook(ctx, o.WebhookURL, o.WebhookTimeout, stats, repoStats, t.Since(start), err)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("scrape failed")
+	}
+
+	if o.ETagCache != "" {
+		log.Info().Int64("revalidated", services.ConditionalRequests()).Int64("not_modified", services.ConditionalHits()).Msg("ETag cache revalidations")
+	}
+	if o.Time {
+		log.Info().Int64("duration_ms", t.Since(start).Milliseconds()).Float64("duration_s", t.Since(start).Seconds()).Msg("scrape completed")
+	}
+}
+
+// Options are the command-line flags, as parsed and checked by parseFlags.
+type Options struct {
+	Owner string
+	Repo  string
+	// ReposFile and ConfigFile select batch mode; at most one is set.
+	ReposFile  string
+	ConfigFile string
+	// Scrape is what each repo is scraped with, all but its Sink and RunID.
+	Scrape          scraper.Options
+	BatchState      string
+	RepoDelay       t.Duration
+	RepoConcurrency int
+
+	// Output is the sink, with -db-driver clickhouse already applied.
+	Output         string
+	DB             db.ConnectOptions
+	DryRunSQL      string
+	InsertBatch    int
+	UniqueToken    bool
+	SingleInstance bool
+	StreamAddr     string
+	KafkaBrokers   string
+	KafkaTopic     string
+	OutputDir      string
+	RotateSize     int64
+	JSONPretty     bool
+	WeeklyFormat   string
+	WeeklyFillGaps bool
+	TableLimit     int
+
+	GraphFile      string
+	DedupeForks    bool
+	OwnersReport   string
+	DiffReport     string
+	MetricsFile    string
+	CompareRepos   bool
+	CompareBy      string
+	WebhookURL     string
+	WebhookTimeout t.Duration
+	ProgressFD     int
+	ETagCache      string
+	MaxRequests    int64
+	Time           bool
+
+	// Modes that exit instead of scraping.
+	PrintRate   bool
+	ListRepos   bool
+	Org         string
+	RepoFilter  services.RepoFilter
+	SchemaCheck bool
+	Explain     bool
+	Probe       bool
+}
+
+// batch reports whether repos come from -repos-file or -config.
+func (o Options) batch() bool { return o.ReposFile != "" || o.ConfigFile != "" }
+
+// parseFlags parses the command-line arguments args (without the program
+// name), applies the flags implied by others, and checks that they fit
+// together. -print-rate-limit, -list-repos and -dry-schema-check only need
+// what they use, so the rest is not checked for them.
+func parseFlags(args []string) (Options, error) {
+	fs := flag.NewFlagSet("github-scraper", flag.ContinueOnError)
 	var (
-		owner       string
-		repo        string
-		concurrency int
-		time        bool
+		owner        string
+		repo         string
+		concurrency  int
+		adaptive     bool
+		minComments  int
+		minLines     int
+		maxLines     int
+		inclFiles    bool
+		inclBody     bool
+		inclChecks   bool
+		inclCommits  bool
+		commitSrc    string
+		inclDeploys  bool
+		inclProtect  bool
+		botBreakdn   bool
+		inclReviews  bool
+		graphFile    string
+		explain      bool
+		botRatio     float64
+		inclTimeline bool
+		inclThreads  bool
+		inclRevCount bool
+		inclAutoMrg  bool
+		inclLatency  bool
+		baseRefs     listFlag
+		mergedToDef  bool
+		storeBodies  bool
+		compareRepos bool
+		dedupeForks  bool
+		busFactor    bool
+		batchState   string
+		etagCache    string
+		weeklyFmt    string
+		weeklyGaps   bool
+		jsonPretty   bool
+		compareBy    string
+		redactBodies bool
+		redactPats   listFlag
+		metricsFile  string
+		authors      string
+		exclAuthors  string
+		cmtAuthors   bool
+		maxCmtAuth   int
+		startPage    int
+		endPage      int
+		diffReport   string
+		timePrec     string
+		probe        bool
+		strict       bool
+		validate     bool
+		failFast     bool
+		resumeFrom   int
+		sincePR      int
+		sinceDate    string
+		untilDate    string
+		retryEmpty   bool
+		sentiment    bool
+		incrComments bool
+		uniqueToken  bool
+		progressFD   int
+		force        bool
+		incremental  bool
+		insertBatch  int
+		dbDriver     string
+		singleInst   bool
+		spillAbove   int
+		maxRequests  int64
+		ownersReport string
+		startCursor  string
+		divergence   int
+		output       string
+		streamAddr   string
+		kafkaBrokers string
+		kafkaTopic   string
+		dryRunSQL    string
+		tableLimit   int
+		outputDir    string
+		rotateSize   int64
+		time         bool
+		printRate    bool
+		schemaCheck  bool
+		dbRetries    int
+		partitionDB  bool
+		dbInterval   t.Duration
+		listRepos    bool
+		org          string
+		inclArchive  bool
+		inclForks    bool
+		minStars     int
+		webhookURL   string
+		webhookTO    t.Duration
+		reposFile    string
+		configFile   string
+		botLogins    string
+		dedupeCase   bool
+		ownerRenames string
+		repoDelay    t.Duration
+		repoConc     int
 	)
 
-	flag.StringVar(&owner, "owner", "", "GitHub repository owner/org")
-	flag.StringVar(&repo, "repo", "", "GitHub repository name")
-	flag.IntVar(&concurrency, "concurrency", 4, "Number of workers for detail fetch + insert")
-	flag.BoolVar(&time, "time", false, "Time the scraper")
-	flag.Parse()
+	fs.StringVar(&owner, "owner", "", "GitHub repository owner/org")
+	fs.StringVar(&repo, "repo", "", "GitHub repository name")
+	fs.StringVar(&reposFile, "repos-file", "", "File with one owner/repo per line to scrape in batch (instead of -owner/-repo)")
+	fs.StringVar(&configFile, "config", "", "JSON batch config listing repos with per-repo option overrides (instead of -owner/-repo)")
+	fs.StringVar(&ownerRenames, "owner-rename-map", "", "Comma-separated old=new owner names; rows of old owners are stored under the new name")
+	fs.BoolVar(&dedupeCase, "dedupe-repo-case", false, "Store rows under GitHub's canonical owner/repo casing")
+	fs.StringVar(&botLogins, "bot-logins", "", "Comma-separated extra logins whose comments count as bot comments")
+	fs.BoolVar(&cmtAuthors, "comment-authors", false, "Aggregate comment counts per commenter into the run summary")
+	fs.IntVar(&maxCmtAuth, "max-comment-authors", 10000, "Stop tracking new commenters for -comment-authors after N distinct authors (0 for no limit)")
+	fs.BoolVar(&botBreakdn, "bot-breakdown", false, "Store each PR's bot comments tallied by bot login")
+	fs.StringVar(&batchState, "batch-state", "", "With -repos-file or -config, record completed repos in this file and skip them when the batch is rerun")
+	fs.BoolVar(&busFactor, "bus-factor", false, "Log how concentrated each repo's merged PRs are among their authors")
+	fs.BoolVar(&dedupeForks, "dedupe-across-forks", false, "With -repos-file or -config, mark likely-duplicate PRs across the repos (same author and head commit or title) with a dedup_group; holds all output until the batch ends")
+	fs.BoolVar(&compareRepos, "compare-repos", false, "With -repos-file or -config, print a side-by-side comparison of the repos after scraping")
+	fs.StringVar(&compareBy, "compare-by", scraper.CompareByPRs, "Metric sorting the -compare-repos table, highest first: prs, comments, bot-ratio, or merge-days")
+	fs.DurationVar(&repoDelay, "repo-delay", 0, "Pause between consecutive repos in batch mode")
+	fs.IntVar(&repoConc, "repo-concurrency", 1, "Number of repos scraped in parallel in batch mode")
+	fs.IntVar(&concurrency, "concurrency", 4, "Number of workers for detail fetch + insert")
+	fs.BoolVar(&adaptive, "adaptive-concurrency", false, "Scale active workers (up to -concurrency) with the remaining rate limit")
+	fs.IntVar(&minComments, "min-comments", 0, "Skip storing PRs with fewer than N comments")
+	fs.IntVar(&minLines, "min-lines-changed", 0, "Skip PRs with fewer than N lines added plus deleted")
+	fs.IntVar(&maxLines, "max-lines-changed", 0, "Skip PRs with more than N lines added plus deleted (0 for no limit)")
+	fs.BoolVar(&inclBody, "include-body", false, "Fetch PR descriptions to store word and checklist counts")
+	fs.BoolVar(&storeBodies, "store-bodies", false, "Also store PR titles and descriptions (implies -include-body)")
+	fs.BoolVar(&redactBodies, "redact-bodies", false, "Redact tokens, URL credentials, and emails from stored titles and descriptions")
+	fs.Var(&redactPats, "redact-pattern", "Additional regexp to redact with -redact-bodies (repeatable)")
+	fs.BoolVar(&inclChecks, "include-checks", false, "Fetch CI check/status contexts of each PR's head commit (raises GraphQL cost)")
+	fs.BoolVar(&inclCommits, "include-commits", false, "Store each PR's commit count")
+	fs.StringVar(&commitSrc, "commit-source", scraper.CommitSourcePR, "What -include-commits counts: pr (commits on the PR) or merged (commits the merge added to the base branch)")
+	fs.BoolVar(&inclProtect, "include-protection", false, "Store whether each PR's base branch is protected and how many approvals it requires (needs admin or maintain access)")
+	fs.BoolVar(&inclDeploys, "include-deployments", false, "Store the deployments of each PR's merge commit (raises GraphQL cost)")
+	fs.BoolVar(&inclReviews, "include-reviewers", false, "Store who reviewed each PR")
+	fs.StringVar(&graphFile, "graph-file", "", "Write an author -> reviewer collaboration graph in GraphViz DOT format to this file (implies -include-reviewers)")
+	fs.BoolVar(&inclLatency, "include-review-latency", false, "Store seconds from the first review request to the first review (implies -include-timeline and -include-reviewers)")
+	fs.BoolVar(&inclThreads, "include-review-threads", false, "Store counts of resolved and unresolved review threads")
+	fs.BoolVar(&inclRevCount, "include-review-counts", false, "Store how many reviews of each PR approved, requested changes, or only commented")
+	fs.BoolVar(&inclAutoMrg, "include-auto-merge", false, "Store whether each PR was merged by auto-merge and who enabled pending auto-merge")
+	fs.BoolVar(&inclTimeline, "include-timeline", false, "Store review-request churn (requests and removals) from each PR's timeline")
+	fs.BoolVar(&inclFiles, "include-files", false, "Fetch each PR's changed files to count them by status and extension (extra requests per PR)")
+	fs.BoolVar(&strict, "strict", false, "Fail a PR on unexpected null fields instead of storing defaults")
+	fs.BoolVar(&validate, "validate-rows", false, "Check each row's invariants before storing it")
+	fs.Float64Var(&botRatio, "warn-on-high-bot-ratio", 0, "Warn (fail under -strict) when bot comments exceed this share of a repo's comments, e.g. 0.9; 0 disables")
+	fs.IntVar(&divergence, "comment-divergence", 5, "Warn when the computed comment count differs from GitHub's totalCommentsCount by more than N")
+	fs.StringVar(&timePrec, "time-precision", "micro", "Precision of stored timestamps: micro or second")
+	fs.StringVar(&output, "output", "postgres", "Where rows go: postgres, clickhouse, kafka, jsonl, stream, table, or weekly")
+	fs.StringVar(&dryRunSQL, "dry-run-sql", "", "With -output postgres, write the INSERT statements to this file (- for stdout) for review instead of connecting to Postgres")
+	fs.StringVar(&streamAddr, "stream-addr", "", "Consumer for -output stream: host:port for TCP or unix:/path for a Unix socket")
+	fs.StringVar(&kafkaBrokers, "kafka-brokers", "", "Comma-separated host:port Kafka brokers for -output kafka")
+	fs.StringVar(&kafkaTopic, "kafka-topic", "", "Existing Kafka topic -output kafka publishes rows to")
+	fs.StringVar(&outputDir, "output-dir", ".", "Directory for -output jsonl files")
+	fs.Int64Var(&rotateSize, "rotate-size", 0, "Start a new -output jsonl file once the current one would exceed N bytes (0 rotates daily only)")
+	fs.BoolVar(&jsonPretty, "json-pretty", false, "With -output jsonl, write one indented JSON array per run instead of JSON lines (buffers all rows)")
+	fs.StringVar(&weeklyFmt, "weekly-format", sinks.WeeklyCSV, "Format of -output weekly: csv or json")
+	fs.BoolVar(&weeklyGaps, "weekly-fill-gaps", false, "With -output weekly, emit zero rows for weeks without PRs instead of skipping them")
+	fs.IntVar(&tableLimit, "table-limit", 50, "Maximum rows shown by -output table (0 for all)")
+	fs.StringVar(&authors, "authors", "", "Comma-separated logins; only process PRs by these authors")
+	fs.StringVar(&exclAuthors, "exclude-authors", "", "Comma-separated logins whose PRs are skipped (wins over -authors)")
+	fs.BoolVar(&mergedToDef, "merged-to-default", false, "Only process merged PRs targeting each repo's default branch (main, master, trunk, ...)")
+	fs.Var(&baseRefs, "base-ref", "Only process PRs targeting this base branch (repeatable)")
+	fs.IntVar(&startPage, "start-page", 0, "First page (1-based) fetched by REST enumeration; for debugging the REST fallback")
+	fs.IntVar(&endPage, "end-page", 0, "Last page fetched by REST enumeration (0 for all)")
+	fs.StringVar(&startCursor, "start-cursor", "", "Resume GraphQL enumeration after this cursor, as logged by an earlier run of the same repo")
+	fs.StringVar(&ownersReport, "codeowners-report", "", "Write PRs per CODEOWNERS owner as CSV to this file (- for stdout); implies -include-files")
+	fs.Int64Var(&maxRequests, "max-requests", 0, "Stop sending GitHub API requests after N (retries included) and finish with what was fetched (0 for no limit)")
+	fs.IntVar(&spillAbove, "spill-to-disk", 0, "Keep enumerated PRs in a temporary file instead of memory while processing repos with more than N PRs; enumeration still holds them all, so peak memory is not lowered (0 never spills)")
+	fs.StringVar(&dbDriver, "db-driver", "postgres", "Database -output postgres stores rows in: postgres, or clickhouse (same as -output clickhouse)")
+	fs.IntVar(&insertBatch, "insert-batch", 1, "With -output postgres, upsert rows N at a time in one transaction instead of one round trip per PR (1 upserts each row as it is built)")
+	fs.BoolVar(&incremental, "incremental", false, "Only scrape PRs created since the newest PR already stored for the repo; stored PRs are not refreshed (requires -output postgres)")
+	fs.BoolVar(&force, "force", false, "Start over instead of resuming below the checkpoint of an interrupted run")
+	fs.IntVar(&progressFD, "progress-fd", 0, "Write progress events as JSON lines to this already open file descriptor (e.g. 3) for wrapper UIs; 0 disables")
+	fs.BoolVar(&uniqueToken, "enforce-unique-token-per-host", false, "Take a Postgres advisory lock keyed by a hash of GITHUB_TOKEN and the API host, and warn when another run already holds it (requires -output postgres)")
+	fs.BoolVar(&singleInst, "enforce-single-instance", false, "Like -enforce-unique-token-per-host, but exit instead of warning when another run holds the lock")
+	fs.BoolVar(&incrComments, "incremental-comments", false, "Add only comments created since each PR's stored row to its stored comment counts instead of rescanning every comment (requires -output postgres)")
+	fs.BoolVar(&sentiment, "analyze-sentiment", false, "Score each non-bot comment with a simple word-list sentiment scorer and store the average per PR")
+	fs.BoolVar(&retryEmpty, "retry-on-empty", false, "Retry an enumeration that found no PRs, with backoff, when the repo's PR count says it has some")
+	fs.StringVar(&sinceDate, "since", "", "Only scrape PRs created at or after this time (RFC3339, or a date like 2024-01-01), stopping enumeration once it is passed")
+	fs.StringVar(&untilDate, "until", "", "Only scrape PRs created before this time (RFC3339, or a date like 2024-04-01)")
+	fs.IntVar(&sincePR, "since-pr-number", 0, "Only scrape PRs numbered above N, stopping enumeration once it is reached")
+	fs.IntVar(&resumeFrom, "resume-from-number", 0, "Skip PRs numbered above N (resume an interrupted newest-first scrape)")
+	fs.BoolVar(&failFast, "fail-fast", false, "Abort on the first PR error")
+	fs.BoolVar(&time, "time", false, "Time the scraper")
+	fs.StringVar(&etagCache, "etag-cache", os.Getenv("GITHUB_ETAG_CACHE"), "Directory caching comment pages between runs, so unchanged pages are revalidated without using rate limit (defaults to $GITHUB_ETAG_CACHE; off when neither is set)")
+	fs.BoolVar(&printRate, "print-rate-limit", false, "Print the current GitHub rate limits and exit")
+	fs.BoolVar(&listRepos, "list-repos", false, "List the repos of -org or -owner (a user) and exit")
+	fs.StringVar(&org, "org", "", "Organization whose repos -list-repos lists")
+	fs.BoolVar(&inclArchive, "include-archived", false, "Include archived repos in -list-repos")
+	fs.BoolVar(&inclForks, "include-forks", false, "Include forks in -list-repos")
+	fs.IntVar(&minStars, "min-stars", 0, "Only list repos with at least N stars in -list-repos")
+	fs.BoolVar(&partitionDB, "partitioned", false, "Create a new prs table partitioned by owner, adding a partition per owner on first insert")
+	fs.IntVar(&dbRetries, "db-connect-retries", 0, "Retry connecting to Postgres N times before giving up (waits for the DB to start)")
+	fs.DurationVar(&dbInterval, "db-connect-interval", 2*t.Second, "Wait before the first Postgres connection retry; doubles per retry up to 30s")
+	fs.BoolVar(&explain, "explain", false, "Estimate the GraphQL point cost of enumerating PRs with the selected -include-* fields, then exit")
+	fs.BoolVar(&probe, "probe", false, "Run the full pipeline for the newest PR only and print its row as JSON without storing it")
+	fs.BoolVar(&schemaCheck, "dry-schema-check", false, "Compare the prs table against the expected columns without changing it, then exit")
+	fs.StringVar(&diffReport, "diff-report", "", "Write new PRs, status changes, and new comments since the previous run as JSON to this file (- for stdout); requires -output postgres")
+	fs.StringVar(&metricsFile, "metrics-file", "", "Write run metrics in Prometheus textfile-collector format to this path on completion")
+	fs.StringVar(&webhookURL, "webhook-url", "", "POST a JSON run summary to this URL on completion")
+	fs.DurationVar(&webhookTO, "webhook-timeout", 10*t.Second, "Timeout for each webhook POST attempt")
+	if err := fs.Parse(args); err != nil {
+		return Options{}, err
+	}
+
+	if graphFile != "" {
+		inclReviews = true
+	}
+	if ownersReport != "" {
+		inclFiles = true
+	}
+	if storeBodies {
+		inclBody = true
+	}
+	if inclLatency {
+		inclTimeline, inclReviews = true, true
+	}
+	if singleInst {
+		uniqueToken = true
+	}
+
+	o := Options{
+		Owner:           owner,
+		Repo:            repo,
+		ReposFile:       reposFile,
+		ConfigFile:      configFile,
+		BatchState:      batchState,
+		RepoDelay:       repoDelay,
+		RepoConcurrency: repoConc,
+		Output:          output,
+		DB:              db.ConnectOptions{Retries: dbRetries, Interval: dbInterval, Partitioned: partitionDB, FoldIDCase: dedupeCase},
+		DryRunSQL:       dryRunSQL,
+		InsertBatch:     insertBatch,
+		UniqueToken:     uniqueToken,
+		SingleInstance:  singleInst,
+		StreamAddr:      streamAddr,
+		KafkaBrokers:    kafkaBrokers,
+		KafkaTopic:      kafkaTopic,
+		OutputDir:       outputDir,
+		RotateSize:      rotateSize,
+		JSONPretty:      jsonPretty,
+		WeeklyFormat:    weeklyFmt,
+		WeeklyFillGaps:  weeklyGaps,
+		TableLimit:      tableLimit,
+		GraphFile:       graphFile,
+		DedupeForks:     dedupeForks,
+		OwnersReport:    ownersReport,
+		DiffReport:      diffReport,
+		MetricsFile:     metricsFile,
+		CompareRepos:    compareRepos,
+		CompareBy:       compareBy,
+		WebhookURL:      webhookURL,
+		WebhookTimeout:  webhookTO,
+		ProgressFD:      progressFD,
+		ETagCache:       etagCache,
+		MaxRequests:     maxRequests,
+		Time:            time,
+		PrintRate:       printRate,
+		ListRepos:       listRepos,
+		Org:             org,
+		RepoFilter:      services.RepoFilter{IncludeArchived: inclArchive, IncludeForks: inclForks, MinStars: minStars},
+		SchemaCheck:     schemaCheck,
+		Explain:         explain,
+		Probe:           probe,
+	}
+	if printRate || listRepos || schemaCheck {
+		return o, nil
+	}
 
-	if owner == "" || repo == "" {
-		log.Fatal().Msg("owner and repo flags are required")
+	if reposFile != "" && configFile != "" {
+		return o, errors.New("-repos-file and -config are mutually exclusive")
+	}
+	if !o.batch() && (owner == "" || repo == "") {
+		return o, errors.New("owner and repo flags are required")
 	}
 
-	ctx := context.Background()
-	services.InitGitHub(ctx)
-	services.InitGitHubGraphQL(ctx)
+	switch dbDriver {
+	case "postgres":
+	case "clickhouse":
+		if o.Output == "postgres" {
+			o.Output = "clickhouse"
+		}
+	default:
+		return o, fmt.Errorf("unknown -db-driver %q; expected postgres or clickhouse", dbDriver)
+	}
+	if o.Output == "stream" && streamAddr == "" {
+		return o, errors.New("-output stream requires -stream-addr")
+	}
+	if o.Output == "kafka" && (kafkaBrokers == "" || kafkaTopic == "") {
+		return o, errors.New("-output kafka requires -kafka-brokers and -kafka-topic")
+	}
+	if diffReport != "" && o.Output != "postgres" {
+		return o, errors.New("-diff-report requires -output postgres")
+	}
+	if dryRunSQL != "" {
+		if o.Output != "postgres" {
+			return o, errors.New("-dry-run-sql requires -output postgres")
+		}
+		if diffReport != "" || partitionDB || incrComments {
+			return o, errors.New("-dry-run-sql does not connect to Postgres, so it cannot be combined with -diff-report, -partitioned or -incremental-comments")
+		}
+	}
+	if uniqueToken && (o.Output != "postgres" || dryRunSQL != "") {
+		return o, errors.New("-enforce-unique-token-per-host and -enforce-single-instance require -output postgres without -dry-run-sql")
+	}
+	if insertBatch < 1 {
+		return o, errors.New("-insert-batch must be at least 1")
+	}
+	if insertBatch > 1 && diffReport != "" {
+		return o, errors.New("-diff-report reads rows back before a batched -insert-batch has written them all; use -insert-batch 1")
+	}
+	if incremental && (o.Output != "postgres" || dryRunSQL != "") {
+		return o, errors.New("-incremental requires -output postgres without -dry-run-sql")
+	}
+	if incrComments {
+		if o.Output != "postgres" {
+			return o, errors.New("-incremental-comments requires -output postgres")
+		}
+		// Neither per-commenter counts nor sentiment scores are stored in
+		// a form new comments can be added to.
+		if sentiment || cmtAuthors {
+			return o, errors.New("-incremental-comments cannot be combined with -analyze-sentiment or -comment-authors")
+		}
+	}
+
+	precision, err := scraper.ParseTimePrecision(timePrec)
+	if err != nil {
+		return o, fmt.Errorf("invalid -time-precision: %w", err)
+	}
 
-	if err := db.Init(ctx); err != nil {
-		log.Fatal().Err(err).Msg("failed to connect to Postgres")
+	if batchState != "" && !o.batch() {
+		return o, errors.New("-batch-state requires -repos-file or -config")
+	}
+	if dedupeForks && !o.batch() {
+		return o, errors.New("-dedupe-across-forks requires -repos-file or -config")
+	}
+	if compareRepos {
+		if !o.batch() {
+			return o, errors.New("-compare-repos requires -repos-file or -config")
+		}
+		if err := scraper.SortSummaries(nil, compareBy); err != nil {
+			return o, fmt.Errorf("invalid -compare-by: %w", err)
+		}
+	}
+	if startCursor != "" && o.batch() {
+		return o, errors.New("-start-cursor applies to a single -owner/-repo, not batch mode")
+	}
+	if probe && o.batch() {
+		return o, errors.New("-probe takes a single -owner/-repo")
+	}
+
+	restPages := services.PageRange{Start: startPage, End: endPage}
+	if err := restPages.Validate(); err != nil {
+		return o, fmt.Errorf("invalid -start-page/-end-page: %w", err)
+	}
+
+	var redactor *scraper.Redactor
+	if redactBodies {
+		if !storeBodies {
+			return o, errors.New("-redact-bodies requires -store-bodies")
+		}
+		if redactor, err = scraper.NewRedactor(redactPats); err != nil {
+			return o, fmt.Errorf("invalid -redact-pattern: %w", err)
+		}
+	} else if len(redactPats) > 0 {
+		return o, errors.New("-redact-pattern requires -redact-bodies")
+	}
+
+	renames, err := scraper.ParseOwnerRenames(ownerRenames)
+	if err != nil {
+		return o, fmt.Errorf("invalid -owner-rename-map: %w", err)
+	}
+
+	since, err := parseDateFlag("since", sinceDate)
+	if err != nil {
+		return o, fmt.Errorf("invalid -since: %w", err)
+	}
+	until, err := parseDateFlag("until", untilDate)
+	if err != nil {
+		return o, fmt.Errorf("invalid -until: %w", err)
+	}
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		return o, fmt.Errorf("-since %s must not be after -until %s", since.Format(t.RFC3339), until.Format(t.RFC3339))
+	}
+	if sincePR > 0 && resumeFrom > 0 && sincePR >= resumeFrom {
+		return o, fmt.Errorf("-since-pr-number %d must be below -resume-from-number %d", sincePR, resumeFrom)
+	}
+	if maxLines > 0 && minLines > maxLines {
+		return o, fmt.Errorf("-min-lines-changed %d exceeds -max-lines-changed %d", minLines, maxLines)
+	}
+	if mergedToDef && len(baseRefs) > 0 {
+		return o, errors.New("-merged-to-default and -base-ref are mutually exclusive")
+	}
+	if commitSrc != scraper.CommitSourcePR && commitSrc != scraper.CommitSourceMerged {
+		return o, fmt.Errorf("unknown -commit-source %q; expected pr or merged", commitSrc)
+	}
+
+	copts := services.CommentOptions{BotLogins: splitList(botLogins)}
+	if sentiment {
+		copts.Scorer = services.LexiconScorer{}
+	}
+	// Buffered sinks write nothing until the end, so a checkpoint would
+	// claim PRs that were never stored.
+	checkpoint := o.Output == "postgres" && dryRunSQL == "" && !dedupeForks && insertBatch == 1
+	o.Scrape = scraper.Options{
+		Concurrency:          concurrency,
+		AdaptiveConcurrency:  adaptive,
+		MinComments:          minComments,
+		MinLinesChanged:      minLines,
+		MaxLinesChanged:      maxLines,
+		CanonicalRepoCase:    dedupeCase,
+		OwnerRenames:         renames,
+		Comments:             copts,
+		IncludeFiles:         inclFiles,
+		IncludeBody:          inclBody,
+		StoreBodies:          storeBodies,
+		Redactor:             redactor,
+		IncludeChecks:        inclChecks,
+		IncludeCommits:       inclCommits,
+		CommitSource:         commitSrc,
+		IncludeDeployments:   inclDeploys,
+		IncludeProtection:    inclProtect,
+		Codeowners:           ownersReport != "",
+		BotBreakdown:         botBreakdn,
+		CommentAuthors:       cmtAuthors,
+		MaxCommentAuthors:    maxCmtAuth,
+		IncludeReviewers:     inclReviews,
+		IncludeTimeline:      inclTimeline,
+		IncludeReviewThreads: inclThreads,
+		IncludeReviewCounts:  inclRevCount,
+		IncludeAutoMerge:     inclAutoMrg,
+		Strict:               strict,
+		ValidateRows:         validate,
+		FailFast:             failFast,
+		ResumeFromNumber:     resumeFrom,
+		SincePRNumber:        sincePR,
+		Since:                since,
+		Until:                until,
+		RetryOnEmpty:         retryEmpty,
+		SpillThreshold:       spillAbove,
+		IncrementalComments:  incrComments,
+		Checkpoint:           checkpoint,
+		IgnoreCheckpoint:     force,
+		Incremental:          incremental,
+		Summarize:            compareRepos,
+		BusFactor:            busFactor,
+		StartCursor:          startCursor,
+		BaseRefs:             baseRefs,
+		MergedToDefault:      mergedToDef,
+		RESTPages:            restPages,
+		TimePrecision:        precision,
+		DiffReport:           diffReport != "",
+		Authors:              splitList(authors),
+		ExcludeAuthors:       splitList(exclAuthors),
+		CommentDivergence:    divergence,
+		BotRatioThreshold:    botRatio,
+	}
+	return o, nil
+}
+
+// webhookPayload is the JSON body POSTed to -webhook-url.
+type webhookPayload struct {
+	Status     string           `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	DurationMS int64            `json:"duration_ms"`
+	Stats      scraper.RunStats `json:"stats"`
+	// Repos holds per-repo stats in batch mode; Stats is then their sum.
+	Repos []scraper.RunStats `json:"repos,omitempty"`
+}
+
+// notifyWebhook posts the run summary. Failures are logged, never fatal.
+func notifyWebhook(ctx context.Context, url string, timeout t.Duration, stats scraper.RunStats, repoStats []scraper.RunStats, dur t.Duration, runErr error) {
+	payload := webhookPayload{
+		Status:     "success",
+		DurationMS: dur.Milliseconds(),
+		Stats:      stats,
+		Repos:      repoStats,
+	}
+	if runErr != nil {
+		payload.Status = "failure"
+		payload.Error = runErr.Error()
+	}
+	if err := services.PostWebhook(ctx, url, payload, timeout); err != nil {
+		log.Warn().Err(err).Msg("failed to POST webhook summary")
+		return
+	}
+	log.Info().Str("status", payload.Status).Msg("posted webhook summary")
+}
+
+// listFlag collects the values of a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// splitList parses a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// streamProgressFD attaches a Progress to opts and streams it to file
+// descriptor fd, which the parent process opened for us. The returned func
+// writes the final event and closes fd.
+func streamProgressFD(ctx context.Context, fd int, opts *scraper.Options) func() {
+	f := os.NewFile(uintptr(fd), "progress-fd")
+	if _, err := f.Stat(); err != nil {
+		log.Fatal().Err(err).Int("fd", fd).Msg("-progress-fd is not an open file descriptor")
+	}
+	p := &scraper.Progress{}
+	opts.Progress = p
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := scraper.StreamProgress(ctx, f, p, scraper.ProgressInterval); err != nil {
+			log.Warn().Err(err).Int("fd", fd).Msg("stopped writing progress events")
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		f.Close()
+	}
+}
+
+// parseDateFlag parses the value of a -since or -until flag: an RFC3339
+// time or a date, taken as midnight UTC. Empty means unset.
+func parseDateFlag(name, value string) (t.Time, error) {
+	if value == "" {
+		return t.Time{}, nil
+	}
+	if ts, err := t.Parse(t.RFC3339, value); err == nil {
+		return ts, nil
+	}
+	ts, err := t.Parse(t.DateOnly, value)
+	if err != nil {
+		return t.Time{}, fmt.Errorf("-%s %q is neither an RFC3339 time nor a YYYY-MM-DD date", name, value)
+	}
+	return ts, nil
+}
+
+// lockTokenHost takes an advisory lock for each token against the API host,
+// since runs sharing any token drain its rate limit between them. When
+// another run holds one, it warns, or exits if enforce is set; the returned
+// lock is nil unless this run holds at least one.
+func lockTokenHost(ctx context.Context, enforce bool) *db.InstanceLock {
+	host := services.APIHost()
+	tokens := services.Tokens()
+	if len(tokens) == 0 {
+		// Unauthenticated runs share the host's per-IP limit.
+		tokens = []string{""}
+	}
+	keys := make([]int64, len(tokens))
+	for i, tok := range tokens {
+		keys[i] = db.InstanceLockKey(tok, host)
+	}
+	lock, busy, err := db.TryInstanceLock(ctx, keys...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to take instance lock")
+	}
+	if len(busy) > 0 {
+		// Tokens are numbered from 1, as in the token pool's logs.
+		numbers := make([]int, len(busy))
+		for i, b := range busy {
+			numbers[i] = b + 1
+		}
+		if enforce {
+			log.Fatal().Str("host", host).Ints("tokens", numbers).Msg("another run is using the same token against this host; exiting")
+		}
+		log.Warn().Str("host", host).Ints("tokens", numbers).Msg("another run is using the same token against this host; both share one rate limit")
+	}
+	return lock
+}
+
+// checkSchema reports prs columns the app relies on that are missing or
+// mistyped, returning the process exit code.
+func checkSchema(ctx context.Context, copts db.ConnectOptions) int {
+	if err := db.Connect(ctx, copts); err != nil {
+		log.Error().Err(err).Msg("failed to connect to Postgres")
+		return 1
 	}
 	defer db.Close()
 
-	var start t.Time
-	if time {
-		start = t.Now()
+	issues, err := db.CheckSchema(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to inspect schema")
+		return 1
+	}
+	for _, i := range issues {
+		fmt.Println(i)
+	}
+	if len(issues) > 0 {
+		log.Error().Int("issues", len(issues)).Msg("prs schema does not match what the scraper writes")
+		return 1
 	}
+	log.Info().Msg("prs schema matches")
+	return 0
+}
 
-	if err := scraper.Run(ctx, owner, repo, concurrency); err != nil {
-		log.Fatal().Err(err).Msg("scrape failed")
+// printRepos lists the repos of org, or of user when org is empty.
+func printRepos(ctx context.Context, org, user string, f services.RepoFilter) error {
+	var (
+		repos []services.RepoListing
+		err   error
+	)
+	switch {
+	case org != "":
+		repos, err = services.GetOrgRepos(ctx, org)
+	case user != "":
+		repos, err = services.GetUserRepos(ctx, user)
+	default:
+		return errors.New("-list-repos requires -org or -owner")
+	}
+	if err != nil {
+		return err
 	}
+	return services.WriteRepoList(os.Stdout, services.FilterRepos(repos, f))
+}
 
-	if time {
-		log.Info().Int64("duration_ms", t.Since(start).Milliseconds()).Float64("duration_s", t.Since(start).Seconds()).Msg("scrape completed")
+// explainCost prints the estimated GraphQL cost of enumerating each repo.
+func explainCost(ctx context.Context, repos []scraper.RepoRef, eopts services.EnumerateOptions) error {
+	estimates := make([]services.CostEstimate, 0, len(repos))
+	for _, r := range repos {
+		e, err := services.EstimateCost(ctx, r.Owner, r.Repo, eopts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+		estimates = append(estimates, e)
+	}
+	return services.WriteCostEstimates(os.Stdout, estimates)
+}
+
+// diffEntry is one repo's entry in the -diff-report file.
+type diffEntry struct {
+	Owner string      `json:"owner"`
+	Repo  string      `json:"repo"`
+	Diff  *db.RunDiff `json:"diff"`
+}
+
+// writeOwnershipReport writes the CODEOWNERS report to path, or stdout
+// for "-".
+func writeOwnershipReport(path string, ownership map[string]int) error {
+	if path == "-" {
+		return scraper.WriteOwnershipReport(os.Stdout, ownership)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := scraper.WriteOwnershipReport(f, ownership); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeDiffReport writes the diffs of every repo that completed as a JSON
+// array to path, or stdout for "-".
+func writeDiffReport(path string, stats scraper.RunStats, repoStats []scraper.RunStats) error {
+	if repoStats == nil {
+		repoStats = []scraper.RunStats{stats}
+	}
+	entries := make([]diffEntry, 0, len(repoStats))
+	for _, s := range repoStats {
+		if s.Diff != nil {
+			entries = append(entries, diffEntry{Owner: s.Owner, Repo: s.Repo, Diff: s.Diff})
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
 	}
+	return os.WriteFile(path, data, 0o644)
 }
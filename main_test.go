@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/scraper"
+)
+
+func TestNotifyWebhookPayload(t *testing.T) {
+	stats := scraper.RunStats{Owner: "octo", Repo: "demo", Total: 3, Processed: 2, Inserted: 2, Errors: 1}
+	tests := []struct {
+		name   string
+		runErr error
+		want   map[string]any
+	}{
+		{"success", nil, map[string]any{"status": "success", "duration_ms": 1500.0}},
+		{"failure", errors.New("rate limited"), map[string]any{"status": "failure", "error": "rate limited", "duration_ms": 1500.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(chan map[string]any, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Error(err)
+				}
+				got <- body
+			}))
+			defer srv.Close()
+
+			notifyWebhook(context.Background(), srv.URL, time.Second, stats, nil, 1500*time.Millisecond, tt.runErr)
+			body := <-got
+			repoStats, _ := body["stats"].(map[string]any)
+			delete(body, "stats")
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("payload = %v, want %v plus stats", body, tt.want)
+			}
+			for key, want := range map[string]any{"owner": "octo", "repo": "demo", "total": 3.0, "processed": 2.0, "inserted": 2.0, "errors": 1.0} {
+				if repoStats[key] != want {
+					t.Errorf("stats.%s = %v, want %v", key, repoStats[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+		check   func(t *testing.T, o Options)
+	}{
+		{name: "single repo", args: []string{"-owner", "octo", "-repo", "demo"}, check: func(t *testing.T, o Options) {
+			if o.Owner != "octo" || o.Repo != "demo" || o.Output != "postgres" || !o.Scrape.Checkpoint {
+				t.Errorf("got %+v", o)
+			}
+		}},
+		{name: "owner and repo required", args: []string{"-owner", "octo"}, wantErr: "owner and repo flags are required"},
+		{name: "rate limits need no repo", args: []string{"-print-rate-limit", "-insert-batch", "0"}, check: func(t *testing.T, o Options) {
+			if !o.PrintRate {
+				t.Error("PrintRate not set")
+			}
+		}},
+		{name: "implied flags", args: []string{"-owner", "octo", "-repo", "demo", "-graph-file", "g.dot", "-store-bodies", "-include-review-latency"}, check: func(t *testing.T, o Options) {
+			s := o.Scrape
+			if !s.IncludeReviewers || !s.IncludeBody || !s.IncludeTimeline {
+				t.Errorf("IncludeReviewers %v, IncludeBody %v, IncludeTimeline %v; want all implied", s.IncludeReviewers, s.IncludeBody, s.IncludeTimeline)
+			}
+		}},
+		{name: "clickhouse driver", args: []string{"-owner", "octo", "-repo", "demo", "-db-driver", "clickhouse"}, check: func(t *testing.T, o Options) {
+			if o.Output != "clickhouse" || o.Scrape.Checkpoint {
+				t.Errorf("Output %q, Checkpoint %v; want clickhouse without checkpoints", o.Output, o.Scrape.Checkpoint)
+			}
+		}},
+		{name: "unknown driver", args: []string{"-owner", "octo", "-repo", "demo", "-db-driver", "mysql"}, wantErr: `unknown -db-driver "mysql"`},
+		{name: "batch flag without batch", args: []string{"-owner", "octo", "-repo", "demo", "-batch-state", "s.json"}, wantErr: "-batch-state requires -repos-file or -config"},
+		{name: "stream without address", args: []string{"-owner", "octo", "-repo", "demo", "-output", "stream"}, wantErr: "-output stream requires -stream-addr"},
+		{name: "since after until", args: []string{"-owner", "octo", "-repo", "demo", "-since", "2024-02-01", "-until", "2024-01-01"}, wantErr: "must not be after -until"},
+		{name: "invalid date", args: []string{"-owner", "octo", "-repo", "demo", "-since", "yesterday"}, wantErr: "invalid -since"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := parseFlags(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFlags = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, o)
+		})
+	}
+}
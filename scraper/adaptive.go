@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog/log"
+)
+
+// semaphore is a counting semaphore whose capacity can be changed while
+// holders are active. Shrinking the limit never preempts holders; it only
+// delays new acquisitions until usage drops below the new limit.
+type semaphore struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+func newSemaphore(limit int) *semaphore {
+	s := &semaphore{limit: limit}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Acquire blocks until a slot is free or ctx is done.
+func (s *semaphore) Acquire(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.inUse >= s.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.cond.Wait()
+	}
+	s.inUse++
+	return nil
+}
+
+func (s *semaphore) Release() {
+	s.mu.Lock()
+	s.inUse--
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// SetLimit changes the capacity; values below one are clamped to one.
+func (s *semaphore) SetLimit(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	s.limit = n
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *semaphore) Limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
+
+// adaptiveTarget picks a worker count for the observed rate budget: all max
+// workers while at least half the budget remains, scaling down linearly as
+// it depletes, and never fewer than one.
+func adaptiveTarget(rate github.Rate, max int) int {
+	if max < 1 {
+		return 1
+	}
+	if rate.Limit <= 0 {
+		return max
+	}
+	frac := float64(rate.Remaining) / float64(rate.Limit)
+	if frac >= 0.5 {
+		return max
+	}
+	target := int(float64(max)*frac/0.5 + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	return target
+}
+
+// runAdaptiveController periodically resizes sem from the latest rate
+// snapshot observed by the service layer until ctx or done is closed.
+func runAdaptiveController(ctx context.Context, done <-chan struct{}, sem *semaphore, max int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			rate, ok := services.LatestRate()
+			if !ok {
+				continue
+			}
+			target := adaptiveTarget(rate, max)
+			if target != sem.Limit() {
+				log.Info().Int("workers", target).Int("rate_remaining", rate.Remaining).Int("rate_limit", rate.Limit).Time("rate_reset", rate.Reset.Time).Msg("adjusting concurrency")
+				sem.SetLimit(target)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestAdaptiveTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining int
+		limit     int
+		max       int
+		want      int
+	}{
+		{"full budget", 5000, 5000, 8, 8},
+		{"half left", 2500, 5000, 8, 8},
+		{"quarter left", 1250, 5000, 8, 4},
+		{"nearly spent", 100, 5000, 8, 1},
+		{"spent", 0, 5000, 8, 1},
+		{"unknown limit", 0, 0, 8, 8},
+		{"max below one", 5000, 5000, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := adaptiveTarget(github.Rate{Remaining: tt.remaining, Limit: tt.limit}, tt.max)
+			if got != tt.want {
+				t.Errorf("adaptiveTarget(%d/%d, %d) = %d, want %d", tt.remaining, tt.limit, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveTargetShrinksAndGrowsBack(t *testing.T) {
+	const max = 10
+	// The budget drains, then resets.
+	var got []int
+	for _, remaining := range []int{5000, 2000, 1000, 200, 0, 5000} {
+		got = append(got, adaptiveTarget(github.Rate{Remaining: remaining, Limit: 5000}, max))
+	}
+	for i := 1; i < len(got)-1; i++ {
+		if got[i] > got[i-1] {
+			t.Errorf("target grew from %d to %d while the budget drained: %v", got[i-1], got[i], got)
+		}
+	}
+	for i, n := range got {
+		if n < 1 || n > max {
+			t.Errorf("target %d = %d, outside [1, %d]", i, n, max)
+		}
+	}
+	if got[len(got)-2] != 1 || got[len(got)-1] != max {
+		t.Errorf("targets %v, want 1 when spent and %d after the reset", got, max)
+	}
+}
+
+func TestSemaphoreLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		start int
+		set   int
+		want  int
+	}{
+		{"shrink", 4, 2, 2},
+		{"grow", 2, 6, 6},
+		{"clamped to one", 3, 0, 1},
+		{"negative clamped to one", 3, -2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSemaphore(tt.start)
+			s.SetLimit(tt.set)
+			if got := s.Limit(); got != tt.want {
+				t.Fatalf("Limit = %d, want %d", got, tt.want)
+			}
+			// Exactly want slots can be held at once.
+			ctx := context.Background()
+			for i := 0; i < tt.want; i++ {
+				if err := s.Acquire(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			if err := s.Acquire(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("acquired slot %d beyond the limit of %d (err %v)", tt.want+1, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSemaphoreShrinkAndGrowWithHolders(t *testing.T) {
+	ctx := context.Background()
+	s := newSemaphore(3)
+	for i := 0; i < 3; i++ {
+		if err := s.Acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shrinking never preempts holders: once one of the three releases, the
+	// two left still fill the new limit.
+	s.SetLimit(2)
+	s.Release()
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquired a slot while 2 of 2 are held (err %v)", err)
+	}
+
+	// Growing wakes a waiter.
+	acquired := make(chan error, 1)
+	go func() { acquired <- s.Acquire(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+	s.SetLimit(3)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken after the limit grew")
+	}
+}
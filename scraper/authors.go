@@ -0,0 +1,74 @@
+package scraper
+
+import (
+	"sort"
+	"sync"
+)
+
+// authorTally aggregates comment counts per commenter across a run. Once
+// max distinct authors are tracked, new authors are dropped while tracked
+// ones keep counting, bounding memory on enormous repos.
+type authorTally struct {
+	max int
+
+	mu        sync.Mutex
+	counts    map[string]int
+	truncated bool
+}
+
+// newAuthorTally returns a tally tracking at most max authors (0 for no
+// limit).
+func newAuthorTally(max int) *authorTally {
+	return &authorTally{max: max, counts: make(map[string]int)}
+}
+
+// add merges one PR's per-login comment counts. It reports true the first
+// time an author is dropped because of the cap.
+func (t *authorTally) add(byLogin map[string]int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	first := false
+	for login, n := range byLogin {
+		if login == "" {
+			continue
+		}
+		if _, ok := t.counts[login]; !ok && t.max > 0 && len(t.counts) >= t.max {
+			if !t.truncated {
+				t.truncated = true
+				first = true
+			}
+			continue
+		}
+		t.counts[login] += n
+	}
+	return first
+}
+
+// snapshot returns a copy of the counts and whether any author was dropped.
+func (t *authorTally) snapshot() (map[string]int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]int, len(t.counts))
+	for k, v := range t.counts {
+		out[k] = v
+	}
+	return out, t.truncated
+}
+
+// topAuthors returns up to n logins with the most comments, most first.
+func topAuthors(counts map[string]int, n int) []string {
+	logins := make([]string, 0, len(counts))
+	for l := range counts {
+		logins = append(logins, l)
+	}
+	sort.Slice(logins, func(i, j int) bool {
+		if counts[logins[i]] != counts[logins[j]] {
+			return counts[logins[i]] > counts[logins[j]]
+		}
+		return logins[i] < logins[j]
+	})
+	if len(logins) > n {
+		logins = logins[:n]
+	}
+	return logins
+}
@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RepoRef identifies a repository to scrape.
+type RepoRef struct {
+	Owner string
+	Repo  string
+	// Overrides adjusts the batch's Options for this repo only.
+	Overrides Overrides
+}
+
+func (r RepoRef) String() string { return r.Owner + "/" + r.Repo }
+
+// BatchOptions configures RunBatch.
+type BatchOptions struct {
+	// Options is applied to every repo.
+	Options Options
+	// RepoDelay is slept between consecutive repo starts to give GitHub's
+	// secondary rate limits room; it is skipped after the last repo.
+	RepoDelay time.Duration
+	// RepoConcurrency is how many repos are scraped at once. Each repo runs
+	// its own Options.Concurrency workers, so the total is the product.
+	RepoConcurrency int
+	// StateFile, when set, records each repo that completes without error.
+	// A rerun with the same StateFile skips those repos, and the file is
+	// removed once a batch finishes with every repo done.
+	StateFile string
+}
+
+// ReadReposFile parses a file with one owner/repo per line. Blank lines and
+// lines starting with # are ignored.
+func ReadReposFile(path string) ([]RepoRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var repos []RepoRef
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		owner, repo, ok := strings.Cut(text, "/")
+		if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("%s:%d: expected owner/repo, got %q", path, line, text)
+		}
+		repos = append(repos, RepoRef{Owner: owner, Repo: repo})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// runRepo scrapes one repo of a batch; tests replace it to observe how
+// RunBatch drives Run without a GitHub to scrape.
+var runRepo = Run
+
+// RunBatch scrapes each repo with Run, up to RepoConcurrency at a time,
+// starting them in file order. A failing repo is logged and the batch moves
+// on; the joined errors are returned at the end alongside per-repo stats,
+// which are in the same order as repos.
+func RunBatch(ctx context.Context, repos []RepoRef, opts BatchOptions) ([]RunStats, error) {
+	parallel := opts.RepoConcurrency
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	// Resolve and validate every repo's options before starting any work.
+	repoOpts := make([]Options, len(repos))
+	for i, r := range repos {
+		o, err := r.Overrides.Apply(opts.Options)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r, err)
+		}
+		repoOpts[i] = o
+	}
+
+	if opts.Options.AdaptiveConcurrency && parallel > 1 {
+		done := make(chan struct{})
+		defer close(done)
+		sem := shareWorkers(repoOpts, parallel*max(opts.Options.Concurrency, 1))
+		go runAdaptiveController(ctx, done, sem, sem.Limit(), 2*time.Second)
+	}
+
+	var state *batchState
+	if opts.StateFile != "" {
+		var err error
+		if state, err = loadBatchState(opts.StateFile); err != nil {
+			return nil, fmt.Errorf("batch state %s: %w", opts.StateFile, err)
+		}
+	}
+
+	all := make([]RunStats, len(repos))
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+		// incomplete is set when a repo ran out of request budget.
+		incomplete bool
+	)
+	slots := make(chan struct{}, parallel)
+
+	started := 0
+	for i, r := range repos {
+		if state != nil && state.done(r) {
+			log.Info().Str("owner", r.Owner).Str("repo", r.Repo).Str("state_file", opts.StateFile).Msg("skipping repo completed in an earlier run")
+			all[i] = RunStats{Owner: r.Owner, Repo: r.Repo}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return all, ctx.Err()
+		case slots <- struct{}{}:
+		}
+		if started++; started > 1 && opts.RepoDelay > 0 {
+			log.Debug().Dur("delay", opts.RepoDelay).Str("next", r.String()).Msg("pausing between repos")
+			select {
+			case <-ctx.Done():
+				<-slots
+				wg.Wait()
+				return all, ctx.Err()
+			case <-time.After(opts.RepoDelay):
+			}
+		}
+
+		wg.Add(1)
+		go func(i int, r RepoRef) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			log.Info().Str("owner", r.Owner).Str("repo", r.Repo).Int("index", i+1).Int("repos", len(repos)).Msg("starting repo")
+			stats, err := runRepo(ctx, r.Owner, r.Repo, repoOpts[i])
+			all[i] = stats
+			if err != nil {
+				log.Error().Err(err).Str("owner", r.Owner).Str("repo", r.Repo).Msg("repo scrape failed; continuing with batch")
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", r, err))
+				mu.Unlock()
+				return
+			}
+			if stats.BudgetExhausted {
+				mu.Lock()
+				incomplete = true
+				mu.Unlock()
+				return
+			}
+			// Rows the sink failed to store need the repo scraped again.
+			if state != nil && stats.ErrorsByClass[ErrClassDB] == 0 {
+				if serr := state.complete(r); serr != nil {
+					log.Warn().Err(serr).Str("state_file", opts.StateFile).Msg("failed to record completed repo")
+				}
+			}
+		}(i, r)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return all, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return all, err
+	}
+	if state != nil && !incomplete {
+		if err := state.clear(); err != nil {
+			log.Warn().Err(err).Str("state_file", opts.StateFile).Msg("failed to remove batch state file")
+		}
+	}
+	return all, nil
+}
+
+// shareWorkers gives every repo one adaptive semaphore of limit slots.
+// The rate budget is the token's, not a repo's, so concurrent repos must
+// shrink together as it drains rather than each keeping its own workers.
+func shareWorkers(repoOpts []Options, limit int) *semaphore {
+	sem := newSemaphore(limit)
+	for i := range repoOpts {
+		repoOpts[i].sharedWorkers = sem
+	}
+	return sem
+}
+
+// Aggregate sums per-repo stats into a batch-wide total.
+func Aggregate(stats []RunStats) RunStats {
+	var agg RunStats
+	for _, s := range stats {
+		agg.Total += s.Total
+		agg.Processed += s.Processed
+		agg.Inserted += s.Inserted
+		agg.Filtered += s.Filtered
+		agg.Errors += s.Errors
+		agg.ExcludedAuthors += s.ExcludedAuthors
+		for login, n := range s.CommentAuthors {
+			if agg.CommentAuthors == nil {
+				agg.CommentAuthors = make(map[string]int)
+			}
+			agg.CommentAuthors[login] += n
+		}
+		agg.CommentAuthorsTruncated = agg.CommentAuthorsTruncated || s.CommentAuthorsTruncated
+		agg.BudgetExhausted = agg.BudgetExhausted || s.BudgetExhausted
+		for owner, n := range s.Ownership {
+			if agg.Ownership == nil {
+				agg.Ownership = make(map[string]int)
+			}
+			agg.Ownership[owner] += n
+		}
+		for class, n := range s.ErrorsByClass {
+			if agg.ErrorsByClass == nil {
+				agg.ErrorsByClass = make(map[string]int64)
+			}
+			agg.ErrorsByClass[class] += n
+		}
+	}
+	return agg
+}
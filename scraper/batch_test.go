@@ -0,0 +1,211 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShareWorkers(t *testing.T) {
+	repoOpts := make([]Options, 3)
+	sem := shareWorkers(repoOpts, 2)
+	for i, o := range repoOpts {
+		if o.sharedWorkers != sem {
+			t.Fatalf("repo %d has its own semaphore", i)
+		}
+	}
+
+	// Two repos hold the two slots; a third repo's worker has to wait.
+	ctx := context.Background()
+	for _, o := range repoOpts[:2] {
+		if err := o.sharedWorkers.Acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := repoOpts[2].sharedWorkers.Acquire(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("third repo acquired a slot beyond the shared limit (err %v)", err)
+	}
+
+	// Shrinking the shared limit holds back every repo.
+	sem.SetLimit(1)
+	repoOpts[0].sharedWorkers.Release()
+	waitCtx, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := repoOpts[2].sharedWorkers.Acquire(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquired a slot while the other repo still uses the only one (err %v)", err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	stats := []RunStats{
+		{Owner: "octo", Repo: "a", Total: 5, Processed: 4, Inserted: 3, Filtered: 1, Errors: 1, ExcludedAuthors: 2,
+			ErrorsByClass: map[string]int64{"not_found": 1}, Ownership: map[string]int{"@team": 2}},
+		{Owner: "octo", Repo: "b", Total: 2, Processed: 2, Inserted: 2, ExcludedAuthors: 1, BudgetExhausted: true,
+			Ownership: map[string]int{"@team": 1, "@infra": 1}},
+		{Owner: "octo", Repo: "c"},
+	}
+	got := Aggregate(stats)
+	want := RunStats{Total: 7, Processed: 6, Inserted: 5, Filtered: 1, Errors: 1, ExcludedAuthors: 3, BudgetExhausted: true,
+		ErrorsByClass: map[string]int64{"not_found": 1}, Ownership: map[string]int{"@team": 3, "@infra": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Aggregate = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunBatchSharesWorkerLimit(t *testing.T) {
+	const sharedLimit = 2
+	var (
+		mu      sync.Mutex
+		sems    = map[*semaphore]bool{}
+		active  int
+		maxSeen int
+	)
+	prev := runRepo
+	t.Cleanup(func() { runRepo = prev })
+	perRepo := map[string]RunStats{
+		"a": {Total: 4, Processed: 4, Inserted: 3, Errors: 1, ExcludedAuthors: 1},
+		"b": {Total: 2, Processed: 2, Inserted: 2, Filtered: 1},
+		"c": {Total: 5, Processed: 5, Inserted: 5, ExcludedAuthors: 2},
+	}
+	runRepo = func(ctx context.Context, owner, repo string, opts Options) (RunStats, error) {
+		sem := opts.sharedWorkers
+		if sem == nil {
+			return RunStats{}, errors.New("no shared semaphore")
+		}
+		mu.Lock()
+		sems[sem] = true
+		mu.Unlock()
+		// As the controller would when the budget runs low.
+		sem.SetLimit(sharedLimit)
+
+		var wg sync.WaitGroup
+		for w := 0; w < opts.Concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 3 {
+					if err := sem.Acquire(ctx); err != nil {
+						return
+					}
+					mu.Lock()
+					active++
+					maxSeen = max(maxSeen, active)
+					mu.Unlock()
+					time.Sleep(time.Millisecond)
+					mu.Lock()
+					active--
+					mu.Unlock()
+					sem.Release()
+				}
+			}()
+		}
+		wg.Wait()
+		stats := perRepo[repo]
+		stats.Owner, stats.Repo = owner, repo
+		return stats, nil
+	}
+
+	repos := []RepoRef{{Owner: "octo", Repo: "a"}, {Owner: "octo", Repo: "b"}, {Owner: "octo", Repo: "c"}}
+	stats, err := RunBatch(context.Background(), repos, BatchOptions{
+		Options:         Options{Concurrency: 3, AdaptiveConcurrency: true},
+		RepoConcurrency: len(repos),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sems) != 1 {
+		t.Errorf("repos used %d semaphores, want one shared", len(sems))
+	}
+	if maxSeen > sharedLimit {
+		t.Errorf("%d workers active across repos at once, want at most the shared %d", maxSeen, sharedLimit)
+	}
+
+	var want RunStats
+	for _, s := range perRepo {
+		want.Total += s.Total
+		want.Processed += s.Processed
+		want.Inserted += s.Inserted
+		want.Filtered += s.Filtered
+		want.Errors += s.Errors
+		want.ExcludedAuthors += s.ExcludedAuthors
+	}
+	if got := Aggregate(stats); !reflect.DeepEqual(got, want) {
+		t.Errorf("Aggregate = %+v, want the per-repo sums %+v", got, want)
+	}
+}
+
+func TestRunBatchRepoDelay(t *testing.T) {
+	const delay = 40 * time.Millisecond
+	prev := runRepo
+	t.Cleanup(func() { runRepo = prev })
+	tests := []struct {
+		name  string
+		delay time.Duration
+		repos int
+	}{
+		{"no delay", 0, 3},
+		{"between repos", delay, 3},
+		{"single repo", delay, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				starts []time.Time
+			)
+			runRepo = func(ctx context.Context, owner, repo string, opts Options) (RunStats, error) {
+				mu.Lock()
+				starts = append(starts, time.Now())
+				mu.Unlock()
+				return RunStats{Owner: owner, Repo: repo}, nil
+			}
+			var repos []RepoRef
+			for i := range tt.repos {
+				repos = append(repos, RepoRef{Owner: "octo", Repo: fmt.Sprint("r", i)})
+			}
+			begin := time.Now()
+			if _, err := RunBatch(context.Background(), repos, BatchOptions{RepoDelay: tt.delay, RepoConcurrency: 1}); err != nil {
+				t.Fatal(err)
+			}
+			elapsed := time.Since(begin)
+
+			if len(starts) != tt.repos {
+				t.Fatalf("ran %d repos, want %d", len(starts), tt.repos)
+			}
+			for i := 1; i < len(starts); i++ {
+				if gap := starts[i].Sub(starts[i-1]); gap < tt.delay {
+					t.Errorf("repo %d started %v after the previous one, want at least %v", i, gap, tt.delay)
+				}
+			}
+			// No delay is slept after the last repo.
+			if want := time.Duration(tt.repos-1)*tt.delay + delay/2; elapsed > want {
+				t.Errorf("batch took %v, want under %v", elapsed, want)
+			}
+		})
+	}
+}
+
+func TestRunBatchRepoDelayHonorsCancel(t *testing.T) {
+	prev := runRepo
+	t.Cleanup(func() { runRepo = prev })
+	ctx, cancel := context.WithCancel(context.Background())
+	runRepo = func(_ context.Context, owner, repo string, _ Options) (RunStats, error) {
+		cancel()
+		return RunStats{Owner: owner, Repo: repo}, nil
+	}
+	repos := []RepoRef{{Owner: "octo", Repo: "a"}, {Owner: "octo", Repo: "b"}}
+	begin := time.Now()
+	_, err := RunBatch(ctx, repos, BatchOptions{RepoDelay: time.Minute, RepoConcurrency: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("cancelled batch took %v; the delay should stop on cancel", elapsed)
+	}
+}
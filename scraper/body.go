@@ -0,0 +1,34 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BodyStats summarizes a PR description for template-compliance checks.
+type BodyStats struct {
+	Words            int
+	ChecklistTotal   int
+	ChecklistChecked int
+}
+
+// checkboxRe matches a markdown task-list item such as "- [ ]" or "* [x]".
+var checkboxRe = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]`)
+
+// ParseBody counts words and markdown checklist items in a PR body. An
+// empty body yields zeros.
+func ParseBody(body string) BodyStats {
+	var s BodyStats
+	s.Words = len(strings.Fields(body))
+	for _, line := range strings.Split(body, "\n") {
+		m := checkboxRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		s.ChecklistTotal++
+		if m[1] != " " {
+			s.ChecklistChecked++
+		}
+	}
+	return s
+}
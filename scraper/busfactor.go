@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"sort"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// busFactorCoverage is the share of merged PRs BusFactor.AuthorsFor80
+// counts authors up to.
+const busFactorCoverage = 0.8
+
+// BusFactor is a crude bus-factor proxy: how concentrated merged PRs are
+// among their authors.
+type BusFactor struct {
+	// MergedPRs counts merged PRs with a known author; PRs by deleted
+	// accounts are left out.
+	MergedPRs int    `json:"merged_prs"`
+	TopAuthor string `json:"top_author,omitempty"`
+	// TopShare is TopAuthor's fraction of MergedPRs, 0 without any.
+	TopShare float64 `json:"top_share"`
+	// AuthorsFor80 is the fewest authors who together account for at
+	// least 80% of MergedPRs.
+	AuthorsFor80 int `json:"authors_for_80"`
+}
+
+// BusFactorOf computes the proxy over rows, considering merged ones only.
+// Ties between authors with equal counts go to the lexically first login.
+func BusFactorOf(rows []types.PRRow) BusFactor {
+	counts := make(map[string]int)
+	var bf BusFactor
+	for _, r := range rows {
+		if r.Status != "merged" || r.Author == "" {
+			continue
+		}
+		counts[r.Author]++
+		bf.MergedPRs++
+	}
+	if bf.MergedPRs == 0 {
+		return bf
+	}
+
+	authors := make([]string, 0, len(counts))
+	for a := range counts {
+		authors = append(authors, a)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if counts[authors[i]] != counts[authors[j]] {
+			return counts[authors[i]] > counts[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+
+	bf.TopAuthor = authors[0]
+	bf.TopShare = float64(counts[authors[0]]) / float64(bf.MergedPRs)
+	covered := 0
+	for _, a := range authors {
+		covered += counts[a]
+		bf.AuthorsFor80++
+		if float64(covered) >= busFactorCoverage*float64(bf.MergedPRs) {
+			break
+		}
+	}
+	return bf
+}
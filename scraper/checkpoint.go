@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"context"
+	"time"
+
+	"github.com/dickeyy/github-scraper/db"
+	"github.com/rs/zerolog/log"
+)
+
+// checkpointEvery limits how often a moving checkpoint is written.
+const checkpointEvery = time.Second
+
+// checkpointer tracks how far a run has got through its PRs, newest first,
+// and stores it with db.SaveCheckpoint. PRs finish out of order, so the
+// checkpoint is the oldest PR of the unbroken run of finished PRs from the
+// newest one; a failed PR holds it back so a resumed run retries it.
+type checkpointer struct {
+	owner, repo, runID string
+	numbers            []int
+	index              map[int]int
+	finished           []bool
+	// next is the index of the newest PR not yet finished.
+	next     int
+	saved    int
+	lastSave time.Time
+}
+
+func newCheckpointer(owner, repo, runID string, numbers []int) *checkpointer {
+	index := make(map[int]int, len(numbers))
+	for i, n := range numbers {
+		index[n] = i
+	}
+	return &checkpointer{owner: owner, repo: repo, runID: runID, numbers: numbers, index: index, finished: make([]bool, len(numbers))}
+}
+
+// finish marks PR number done and saves the checkpoint if it moved and the
+// last save is old enough.
+func (c *checkpointer) finish(ctx context.Context, number int) {
+	i, ok := c.index[number]
+	if !ok {
+		return
+	}
+	c.finished[i] = true
+	for c.next < len(c.numbers) && c.finished[c.next] {
+		c.next++
+	}
+	if time.Since(c.lastSave) >= checkpointEvery {
+		c.flush(ctx)
+	}
+}
+
+// flush saves the checkpoint if it moved since the last save.
+func (c *checkpointer) flush(ctx context.Context) {
+	if c.next == 0 || c.numbers[c.next-1] == c.saved {
+		return
+	}
+	number := c.numbers[c.next-1]
+	if err := db.SaveCheckpoint(ctx, c.owner, c.repo, number, c.runID); err != nil {
+		log.Warn().Err(err).Str("owner", c.owner).Str("repo", c.repo).Msg("failed to save checkpoint")
+		return
+	}
+	c.saved, c.lastSave = number, time.Now()
+}
+
+// clear removes the checkpoint of a run that got through all its PRs.
+func (c *checkpointer) clear(ctx context.Context) {
+	if err := db.ClearCheckpoint(ctx, c.owner, c.repo); err != nil {
+		log.Warn().Err(err).Str("owner", c.owner).Str("repo", c.repo).Msg("failed to clear checkpoint")
+	}
+}
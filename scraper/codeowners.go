@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Unowned is the owner PRs touching no owned file are reported under.
+const Unowned = "(unowned)"
+
+// CodeownersRule maps files matching a CODEOWNERS pattern to its owners.
+// A rule without owners explicitly leaves its files unowned.
+type CodeownersRule struct {
+	Pattern string
+	Owners  []string
+	re      *regexp.Regexp
+}
+
+// Codeowners is a parsed CODEOWNERS file.
+type Codeowners []CodeownersRule
+
+// ParseCodeowners parses the text of a CODEOWNERS file. Blank lines and
+// comments are skipped, as are patterns that can't be compiled (GitHub
+// ignores invalid lines too).
+func ParseCodeowners(text string) Codeowners {
+	var rules Codeowners
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := codeownersRegexp(fields[0])
+		if err != nil {
+			continue
+		}
+		rules = append(rules, CodeownersRule{Pattern: fields[0], Owners: fields[1:], re: re})
+	}
+	return rules
+}
+
+// codeownersRegexp translates a CODEOWNERS pattern, which follows
+// .gitignore rules with a few exceptions, into a regexp over repo-relative
+// paths:
+//   - a pattern with a slash at the start or in the middle is anchored at
+//     the repo root; otherwise it matches at any depth,
+//   - * and ? match within one path segment, ** across segments,
+//   - a pattern naming a directory covers everything below it, but one
+//     ending in a wildcard segment (docs/*) only matches direct children,
+//   - a trailing slash only matches below the directory.
+func codeownersRegexp(pattern string) (*regexp.Regexp, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	last := p[strings.LastIndex(p, "/")+1:]
+	switch {
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.ContainsAny(last, "*?"):
+		b.WriteString("$")
+	default:
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(b.String())
+}
+
+// Owners returns the owners of path, repo-relative without a leading
+// slash. The last matching rule wins; nil means unowned.
+func (c Codeowners) Owners(path string) []string {
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].re.MatchString(path) {
+			return c[i].Owners
+		}
+	}
+	return nil
+}
+
+// PROwners returns the distinct owners of any of paths, sorted, or
+// []string{Unowned} when none of them is owned.
+func (c Codeowners) PROwners(paths []string) []string {
+	set := make(map[string]bool)
+	for _, p := range paths {
+		for _, o := range c.Owners(p) {
+			set[strings.ToLower(o)] = true
+		}
+	}
+	if len(set) == 0 {
+		return []string{Unowned}
+	}
+	owners := make([]string, 0, len(set))
+	for o := range set {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// WriteOwnershipReport writes ownership as CSV with an owner,prs header,
+// most PRs first and ties by owner. A PR with several owners counts once
+// for each, so the counts can sum to more than the PRs scraped.
+func WriteOwnershipReport(w io.Writer, ownership map[string]int) error {
+	owners := make([]string, 0, len(ownership))
+	for o := range ownership {
+		owners = append(owners, o)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		if ownership[owners[i]] != ownership[owners[j]] {
+			return ownership[owners[i]] > ownership[owners[j]]
+		}
+		return owners[i] < owners[j]
+	})
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"owner", "prs"})
+	for _, o := range owners {
+		cw.Write([]string{o, strconv.Itoa(ownership[o])})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,85 @@
+package scraper
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const testCodeowners = `# Default owners
+*                       @acme/core
+
+# Docs, but not the API reference below them
+/docs/                  @acme/docs   # trailing comment
+/docs/api/              @acme/api
+*.md                    @acme/writers
+build/logs/             @acme/ops
+/apps/*                 @acme/apps
+**/testdata/**          @acme/qa
+/vendor/
+[invalid pattern
+`
+
+func TestParseCodeowners(t *testing.T) {
+	c := ParseCodeowners(testCodeowners)
+	var patterns []string
+	for _, r := range c {
+		patterns = append(patterns, r.Pattern)
+	}
+	want := []string{"*", "/docs/", "/docs/api/", "*.md", "build/logs/", "/apps/*", "**/testdata/**", "/vendor/", "[invalid"}
+	if !reflect.DeepEqual(patterns, want) {
+		t.Errorf("patterns = %q, want %q", patterns, want)
+	}
+	if got := c[1].Owners; !reflect.DeepEqual(got, []string{"@acme/docs"}) {
+		t.Errorf("/docs/ owners = %q; the trailing comment should be dropped", got)
+	}
+	if len(c[7].Owners) != 0 {
+		t.Errorf("/vendor/ owners = %q, want none", c[7].Owners)
+	}
+}
+
+func TestCodeownersPrecedence(t *testing.T) {
+	c := ParseCodeowners(testCodeowners)
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"main.go", []string{"@acme/core"}},
+		{"docs/guide.txt", []string{"@acme/docs"}},
+		{"docs/api/v1.yaml", []string{"@acme/api"}},
+		{"nested/docs/guide.txt", []string{"@acme/core"}},
+		// The later *.md rule beats both docs rules.
+		{"docs/api/README.md", []string{"@acme/writers"}},
+		// A slash in the middle anchors the pattern at the root.
+		{"build/logs/out.txt", []string{"@acme/ops"}},
+		{"src/build/logs/out.txt", []string{"@acme/core"}},
+		{"apps/web", []string{"@acme/apps"}},
+		// /apps/* only covers direct children.
+		{"apps/web/main.go", []string{"@acme/core"}},
+		{"pkg/x/testdata/golden.txt", []string{"@acme/qa"}},
+		// A rule without owners leaves its files unowned.
+		{"vendor/lib/lib.go", []string{}},
+	}
+	for _, tt := range tests {
+		if got := c.Owners(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Owners(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if got := c.PROwners([]string{"main.go", "docs/guide.txt", "README.md"}); !reflect.DeepEqual(got, []string{"@acme/core", "@acme/docs", "@acme/writers"}) {
+		t.Errorf("PROwners = %q", got)
+	}
+	if got := c.PROwners([]string{"vendor/lib/lib.go"}); !reflect.DeepEqual(got, []string{Unowned}) {
+		t.Errorf("PROwners of an unowned file = %q", got)
+	}
+}
+
+func TestWriteOwnershipReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteOwnershipReport(&buf, map[string]int{"@b": 2, "@a": 2, "(unowned)": 5}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "owner,prs\n(unowned),5\n@a,2\n@b,2\n"; buf.String() != want {
+		t.Errorf("report = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,33 @@
+package scraper
+
+import "github.com/dickeyy/github-scraper/services"
+
+// Commit sources for -commit-source.
+const (
+	// CommitSourcePR counts the commits on the PR as its author pushed them.
+	CommitSourcePR = "pr"
+	// CommitSourceMerged counts the commits the merge added to the base
+	// branch: one for a squash, the rebased commits for a rebase, and the
+	// PR's commits plus the merge commit for a merge commit.
+	CommitSourceMerged = "merged"
+)
+
+// commitCount derives a PR's commit count for source. With CommitSourceMerged
+// unmerged PRs have added nothing to the base branch and count zero.
+func commitCount(info services.CommitInfo, merged bool, source string) int {
+	if source != CommitSourceMerged {
+		return info.PRCommits
+	}
+	if !merged || info.MergeParents == 0 {
+		return 0
+	}
+	if info.MergeParents > 1 {
+		return info.PRCommits + 1
+	}
+	// Single-parent merges are rebases when the merge commit kept the head
+	// commit's author date, otherwise squashes.
+	if info.MergeAuthoredAt.Equal(info.HeadAuthoredAt) {
+		return info.PRCommits
+	}
+	return 1
+}
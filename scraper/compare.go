@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dickeyy/github-scraper/sinks"
+)
+
+// RepoSummary condenses one repo's run for cross-repo comparison. It
+// covers every PR processed without error, filtered or not.
+type RepoSummary struct {
+	Owner          string  `json:"owner"`
+	Repo           string  `json:"repo"`
+	PRs            int     `json:"prs"`
+	MedianComments float64 `json:"median_comments"`
+	// BotRatio is bot comments over all comments; 0 without comments.
+	BotRatio float64 `json:"bot_ratio"`
+	// MedianMergeDays is the median time from creation to merge of merged
+	// PRs; nil when none merged.
+	MedianMergeDays *float64 `json:"median_merge_days,omitempty"`
+}
+
+// Comparison metrics accepted by SortSummaries.
+const (
+	CompareByPRs       = "prs"
+	CompareByComments  = "comments"
+	CompareByBotRatio  = "bot-ratio"
+	CompareByMergeDays = "merge-days"
+)
+
+// summaryCollector gathers the per-PR values a RepoSummary is built from.
+// Only the consumer loop uses it, so it needs no locking.
+type summaryCollector struct {
+	comments  []float64
+	mergeDays []float64
+	total     int
+	bots      int
+}
+
+func (c *summaryCollector) add(commentCount, botComments int, status string, openDays float64) {
+	c.comments = append(c.comments, float64(commentCount))
+	c.total += commentCount
+	c.bots += botComments
+	if status == "merged" {
+		c.mergeDays = append(c.mergeDays, openDays)
+	}
+}
+
+func (c *summaryCollector) summary(owner, repo string) RepoSummary {
+	s := RepoSummary{Owner: owner, Repo: repo, PRs: len(c.comments), MedianComments: sinks.Median(c.comments)}
+	if c.total > 0 {
+		s.BotRatio = float64(c.bots) / float64(c.total)
+	}
+	if len(c.mergeDays) > 0 {
+		m := sinks.Median(c.mergeDays)
+		s.MedianMergeDays = &m
+	}
+	return s
+}
+
+// SortSummaries orders summaries by metric, highest first, breaking ties
+// by owner/repo. Repos without merged PRs sort last by merge-days.
+func SortSummaries(summaries []RepoSummary, metric string) error {
+	var key func(RepoSummary) (float64, bool)
+	switch metric {
+	case CompareByPRs:
+		key = func(s RepoSummary) (float64, bool) { return float64(s.PRs), true }
+	case CompareByComments:
+		key = func(s RepoSummary) (float64, bool) { return s.MedianComments, true }
+	case CompareByBotRatio:
+		key = func(s RepoSummary) (float64, bool) { return s.BotRatio, true }
+	case CompareByMergeDays:
+		key = func(s RepoSummary) (float64, bool) {
+			if s.MedianMergeDays == nil {
+				return 0, false
+			}
+			return *s.MedianMergeDays, true
+		}
+	default:
+		return fmt.Errorf("unknown comparison metric %q; expected %s, %s, %s, or %s", metric, CompareByPRs, CompareByComments, CompareByBotRatio, CompareByMergeDays)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, aok := key(summaries[i])
+		b, bok := key(summaries[j])
+		if aok != bok {
+			return aok
+		}
+		if a != b {
+			return a > b
+		}
+		return summaries[i].Owner+"/"+summaries[i].Repo < summaries[j].Owner+"/"+summaries[j].Repo
+	})
+	return nil
+}
+
+// RenderComparison writes summaries side by side as an aligned table, in
+// the order given.
+func RenderComparison(w io.Writer, summaries []RepoSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"REPO", "PRS", "MEDIAN COMMENTS", "BOT RATIO", "MEDIAN DAYS TO MERGE"}, "\t"))
+	for _, s := range summaries {
+		mergeDays := "-"
+		if s.MedianMergeDays != nil {
+			mergeDays = fmt.Sprintf("%.1f", *s.MedianMergeDays)
+		}
+		fmt.Fprintf(tw, "%s/%s\t%d\t%.1f\t%.2f\t%s\n", s.Owner, s.Repo, s.PRs, s.MedianComments, s.BotRatio, mergeDays)
+	}
+	return tw.Flush()
+}
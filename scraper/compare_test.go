@@ -0,0 +1,48 @@
+package scraper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderComparisonGolden(t *testing.T) {
+	var a, b summaryCollector
+	for _, pr := range []struct {
+		comments, bots int
+		status         string
+		days           float64
+	}{
+		{4, 1, "merged", 2},
+		{2, 0, "merged", 6},
+		{9, 3, "open", 30},
+	} {
+		a.add(pr.comments, pr.bots, pr.status, pr.days)
+	}
+	b.add(1, 0, "closed", 1)
+
+	summaries := []RepoSummary{b.summary("octo", "small"), a.summary("octo", "big")}
+	if err := SortSummaries(summaries, CompareByMergeDays); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := RenderComparison(&buf, summaries); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "comparison.golden")
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("RenderComparison output differs from %s:\n%s\nwant:\n%s", golden, buf.Bytes(), want)
+	}
+}
+
+func TestSortSummariesRejectsUnknownMetric(t *testing.T) {
+	if err := SortSummaries(nil, "stars"); err == nil {
+		t.Error("SortSummaries accepted an unknown metric")
+	}
+}
@@ -0,0 +1,122 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Overrides are per-repo (or batch-wide) changes to Options. Unset fields
+// inherit from the layer below: repo entries over the config's defaults,
+// and defaults over the command-line flags.
+type Overrides struct {
+	// BotLogins replaces the inherited extra bot logins when set.
+	BotLogins    []string `json:"bot_logins,omitempty"`
+	MinComments  *int     `json:"min_comments,omitempty"`
+	IncludeFiles *bool    `json:"include_files,omitempty"`
+	Strict       *bool    `json:"strict,omitempty"`
+	ValidateRows *bool    `json:"validate_rows,omitempty"`
+}
+
+// Apply layers o over base and validates the result.
+func (o Overrides) Apply(base Options) (Options, error) {
+	opts := base
+	if o.BotLogins != nil {
+		opts.Comments.BotLogins = o.BotLogins
+	}
+	if o.MinComments != nil {
+		opts.MinComments = *o.MinComments
+	}
+	if o.IncludeFiles != nil {
+		opts.IncludeFiles = *o.IncludeFiles
+	}
+	if o.Strict != nil {
+		opts.Strict = *o.Strict
+	}
+	if o.ValidateRows != nil {
+		opts.ValidateRows = *o.ValidateRows
+	}
+	return opts, opts.validate()
+}
+
+// validate checks options that can come from a config file.
+func (o Options) validate() error {
+	if o.MinComments < 0 {
+		return fmt.Errorf("min_comments must not be negative, got %d", o.MinComments)
+	}
+	for _, l := range o.Comments.BotLogins {
+		if strings.TrimSpace(l) == "" {
+			return fmt.Errorf("bot_logins must not contain empty logins")
+		}
+	}
+	return nil
+}
+
+// BatchConfig is the JSON file accepted by -config:
+//
+//	{
+//	  "defaults": {"bot_logins": ["ci-bot"], "min_comments": 1},
+//	  "repos": [
+//	    {"repo": "owner/name", "include_files": true},
+//	    {"repo": "owner/other", "bot_logins": []}
+//	  ]
+//	}
+type BatchConfig struct {
+	Defaults Overrides         `json:"defaults"`
+	Repos    []RepoConfigEntry `json:"repos"`
+}
+
+// RepoConfigEntry is one repo in a BatchConfig with its overrides.
+type RepoConfigEntry struct {
+	Repo string `json:"repo"`
+	Overrides
+}
+
+// LoadBatchConfig reads a batch config file and returns its repos with
+// overrides resolved against the config's defaults. Flag values are layered
+// underneath when the batch runs.
+func LoadBatchConfig(path string) ([]RepoRef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg BatchConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	repos := make([]RepoRef, 0, len(cfg.Repos))
+	for i, e := range cfg.Repos {
+		owner, repo, ok := strings.Cut(e.Repo, "/")
+		if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("%s: repos[%d]: expected owner/repo, got %q", path, i, e.Repo)
+		}
+		repos = append(repos, RepoRef{Owner: owner, Repo: repo, Overrides: mergeOverrides(cfg.Defaults, e.Overrides)})
+	}
+	return repos, nil
+}
+
+// mergeOverrides layers top over base, field by field.
+func mergeOverrides(base, top Overrides) Overrides {
+	out := base
+	if top.BotLogins != nil {
+		out.BotLogins = top.BotLogins
+	}
+	if top.MinComments != nil {
+		out.MinComments = top.MinComments
+	}
+	if top.IncludeFiles != nil {
+		out.IncludeFiles = top.IncludeFiles
+	}
+	if top.Strict != nil {
+		out.Strict = top.Strict
+	}
+	if top.ValidateRows != nil {
+		out.ValidateRows = top.ValidateRows
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"context"
+	"time"
+
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/rs/zerolog/log"
+)
+
+// emptyRetries and emptyRetryDelay bound how long Options.RetryOnEmpty
+// waits for an enumeration that came back empty; the delay doubles after
+// each attempt.
+const emptyRetries = 3
+
+var emptyRetryDelay = 5 * time.Second
+
+// retryEmptyEnumeration is called after enumerate returned no PRs. When the
+// repo's PR count (services.CountPRs in Run) says there should be some, it
+// retries enumerate until it returns PRs or the retries run out, and then
+// accepts the empty result. The count is a best-effort check; if it fails,
+// the empty result stands.
+func retryEmptyEnumeration(ctx context.Context, owner, repo string, count func() (int, error), enumerate func() ([]services.PRLite, string, error)) ([]services.PRLite, string, error) {
+	want, err := count()
+	if err != nil {
+		log.Warn().Err(err).Str("owner", owner).Str("repo", repo).Msg("failed to count PRs after an empty enumeration; accepting it")
+		return nil, "", nil
+	}
+	if want == 0 {
+		return nil, "", nil
+	}
+
+	var cursor string
+	delay := emptyRetryDelay
+	for attempt := 1; attempt <= emptyRetries; attempt++ {
+		log.Warn().Str("owner", owner).Str("repo", repo).Int("total_count", want).Int("attempt", attempt).Dur("sleep_for", delay).Msg("enumeration returned no PRs but the repo has some; retrying")
+		select {
+		case <-ctx.Done():
+			return nil, cursor, ctx.Err()
+		case <-time.After(delay):
+		}
+		var lites []services.PRLite
+		lites, cursor, err = enumerate()
+		if err != nil || len(lites) > 0 {
+			return lites, cursor, err
+		}
+		delay *= 2
+	}
+	log.Warn().Str("owner", owner).Str("repo", repo).Int("total_count", want).Int("retries", emptyRetries).Msg("enumeration still empty after retries; continuing without PRs")
+	return nil, cursor, nil
+}
@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/services"
+)
+
+func TestRetryEmptyEnumeration(t *testing.T) {
+	defer func(d time.Duration) { emptyRetryDelay = d }(emptyRetryDelay)
+	emptyRetryDelay = time.Millisecond
+	ctx := context.Background()
+
+	t.Run("retries until PRs show up", func(t *testing.T) {
+		calls := 0
+		enumerate := func() ([]services.PRLite, string, error) {
+			calls++
+			if calls < 2 {
+				return nil, "", nil
+			}
+			return []services.PRLite{{Number: 1}, {Number: 2}}, "cursor", nil
+		}
+		lites, cursor, err := retryEmptyEnumeration(ctx, "o", "r", func() (int, error) { return 2, nil }, enumerate)
+		if err != nil || len(lites) != 2 || cursor != "cursor" {
+			t.Fatalf("got %d lites, cursor %q, err %v", len(lites), cursor, err)
+		}
+		if calls != 2 {
+			t.Errorf("enumerated %d times, want 2", calls)
+		}
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		calls := 0
+		enumerate := func() ([]services.PRLite, string, error) {
+			calls++
+			return nil, "", nil
+		}
+		lites, _, err := retryEmptyEnumeration(ctx, "o", "r", func() (int, error) { return 5, nil }, enumerate)
+		if err != nil || len(lites) != 0 {
+			t.Fatalf("got %d lites, err %v", len(lites), err)
+		}
+		if calls != emptyRetries {
+			t.Errorf("enumerated %d times, want %d", calls, emptyRetries)
+		}
+	})
+
+	for name, count := range map[string]func() (int, error){
+		"repo really has no PRs": func() (int, error) { return 0, nil },
+		"count fails":            func() (int, error) { return 0, errors.New("boom") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			enumerate := func() ([]services.PRLite, string, error) {
+				t.Fatal("enumerated again")
+				return nil, "", nil
+			}
+			if lites, _, err := retryEmptyEnumeration(ctx, "o", "r", count, enumerate); err != nil || len(lites) != 0 {
+				t.Errorf("got %d lites, err %v; want the empty result", len(lites), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/dickeyy/github-scraper/services"
+)
+
+// Error classes reported in RunStats.ErrorsByClass.
+const (
+	ErrClassRateLimit = "rate_limit"
+	ErrClassNotFound  = "not_found"
+	ErrClassDB        = "db"
+	ErrClassTimeout   = "timeout"
+	ErrClassBudget    = "budget"
+	ErrClassOther     = "other"
+)
+
+// sinkError marks a failure to store a row, as opposed to fetching it.
+type sinkError struct{ err error }
+
+func (e *sinkError) Error() string { return "write row: " + e.err.Error() }
+
+func (e *sinkError) Unwrap() error { return e.err }
+
+// classifyError buckets a PR error by cause so a run's summary shows whether
+// it was hurt by rate limits, missing data, storage, or timeouts.
+func classifyError(err error) string {
+	var se *sinkError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &se):
+		return ErrClassDB
+	case errors.Is(err, services.ErrBudgetExhausted):
+		return ErrClassBudget
+	case services.IsRateLimit(err):
+		return ErrClassRateLimit
+	case services.IsNotFound(err):
+		return ErrClassNotFound
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrClassTimeout
+	default:
+		return ErrClassOther
+	}
+}
@@ -0,0 +1,60 @@
+package scraper
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/dickeyy/github-scraper/services"
+)
+
+// filterLites drops enumerated PRs that the options exclude before any
+// per-PR work is dispatched. It returns the kept PRs in their original
+// order, how many were skipped, and how many of those were skipped for an
+// author on Options.ExcludeAuthors. Without sizes (REST listings lack diff
+// stats) the lines-changed range is left for after row building.
+func filterLites(lites []services.PRLite, opts Options, sizes bool) ([]services.PRLite, int, int) {
+	kept := lites[:0:0]
+	excluded := 0
+	for _, l := range lites {
+		if opts.ResumeFromNumber > 0 && l.Number > opts.ResumeFromNumber {
+			continue
+		}
+		if opts.SincePRNumber > 0 && l.Number <= opts.SincePRNumber {
+			continue
+		}
+		if l.CreatedAt.Before(opts.Since) || (!opts.Until.IsZero() && !l.CreatedAt.Before(opts.Until)) {
+			continue
+		}
+		if len(opts.BaseRefs) > 0 && !slices.Contains(opts.BaseRefs, l.BaseRef) {
+			continue
+		}
+		if opts.MergedToDefault && l.State != "MERGED" {
+			continue
+		}
+		// The denylist wins over the allowlist.
+		if authorListed(l.Author, opts.ExcludeAuthors) {
+			excluded++
+			continue
+		}
+		if len(opts.Authors) > 0 && !authorListed(l.Author, opts.Authors) {
+			continue
+		}
+		if sizes && !linesInRange(l.Additions+l.Deletions, opts.MinLinesChanged, opts.MaxLinesChanged) {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return kept, len(lites) - len(kept), excluded
+}
+
+// authorListed reports whether author is one of logins, compared
+// case-insensitively.
+func authorListed(author string, logins []string) bool {
+	return slices.ContainsFunc(logins, func(l string) bool { return strings.EqualFold(l, author) })
+}
+
+// linesInRange reports whether lines is within [min, max]; a bound of 0
+// is unset.
+func linesInRange(lines, min, max int) bool {
+	return lines >= min && (max <= 0 || lines <= max)
+}
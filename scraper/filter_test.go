@@ -0,0 +1,28 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dickeyy/github-scraper/services"
+)
+
+func TestFilterLitesAuthors(t *testing.T) {
+	lites := []services.PRLite{
+		{Number: 4, Author: "alice"},
+		{Number: 3, Author: "Renovate-Bot"},
+		{Number: 2, Author: "bob"},
+		{Number: 1, Author: "carol"},
+	}
+	opts := Options{Authors: []string{"ALICE", "renovate-bot", "carol"}, ExcludeAuthors: []string{"renovate-bot", "Carol"}}
+	kept, skipped, excluded := filterLites(lites, opts, false)
+	var numbers []int
+	for _, l := range kept {
+		numbers = append(numbers, l.Number)
+	}
+	// bob is not allowed; renovate-bot and carol are on both lists, where
+	// the denylist wins.
+	if !reflect.DeepEqual(numbers, []int{4}) || skipped != 3 || excluded != 2 {
+		t.Errorf("kept %v, skipped %d, excluded %d; want [4], 3 and 2", numbers, skipped, excluded)
+	}
+}
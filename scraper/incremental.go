@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dickeyy/github-scraper/db"
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/rs/zerolog/log"
+)
+
+// incrementalBreakdowns builds comment breakdowns from the counts stored by
+// earlier runs plus the comments created since. Each stored PR only counts
+// comments created after the run that wrote it; PRs with nothing usable
+// stored, including rows stored before comments were split into issue and
+// review comments, are counted in full if the scan covers their whole life
+// and left to per-PR counting otherwise. With nothing stored at all it is the plain
+// repo-wide preload.
+func incrementalBreakdowns(ctx context.Context, owner, rowOwner, repo string, prs map[int]services.PRRef, copts services.CommentOptions, botBreakdown bool) (map[int]services.CommentsBreakdown, error) {
+	stored, err := db.StoredCommentCounts(ctx, rowOwner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	base, refs, since := incrementalCutoff(stored, prs, botBreakdown)
+	if len(base) == 0 {
+		log.Info().Str("owner", owner).Str("repo", repo).Msg("no stored comment counts; scanning all comments")
+		return services.GetRepoCommentsBreakdown(ctx, owner, repo, prs, copts)
+	}
+
+	copts.Since = since
+	log.Info().Str("owner", owner).Str("repo", repo).Int("stored", len(base)).Time("since", since).Msg("scanning comments since stored counts")
+	deltas, err := services.GetRepoCommentsBreakdown(ctx, owner, repo, refs, copts)
+	if err != nil && !errors.Is(err, services.ErrCommentsTruncated) {
+		return nil, err
+	}
+
+	out := make(map[int]services.CommentsBreakdown, len(refs))
+	for number := range refs {
+		b := base[number]
+		b.Merge(deltas[number])
+		out[number] = b
+	}
+	return out, err
+}
+
+// incrementalCutoff splits prs by what is stored for them. base holds the
+// usable stored counts; refs the PRs to scan for, each stored one counting
+// only comments created after its run; since is the oldest such run, where
+// the scan starts. With nothing usable stored, base is empty and refs is
+// nil.
+func incrementalCutoff(stored map[int]db.StoredComments, prs map[int]services.PRRef, botBreakdown bool) (base map[int]services.CommentsBreakdown, refs map[int]services.PRRef, since time.Time) {
+	base = make(map[int]services.CommentsBreakdown)
+	refs = make(map[int]services.PRRef, len(prs))
+	for number, ref := range prs {
+		s, ok := stored[number]
+		if !ok || s.Truncated || s.Issue+s.Review != s.Total || (botBreakdown && s.Bot > 0 && s.Bots == nil) {
+			continue
+		}
+		at, perr := time.Parse(runIDLayout, s.RunID)
+		if perr != nil {
+			continue
+		}
+		ref.CountSince = at
+		refs[number] = ref
+		base[number] = services.CommentsBreakdown{
+			IssueComments:     s.Issue,
+			ReviewComments:    s.Review,
+			TotalComments:     s.Total,
+			BotComments:       s.Bot,
+			AuthorComments:    s.Author,
+			FirstDayComments:  s.FirstDay,
+			FirstWeekComments: s.FirstWeek,
+			BotsByLogin:       s.Bots,
+		}
+		if since.IsZero() || at.Before(since) {
+			since = at
+		}
+	}
+	if len(base) == 0 {
+		return base, nil, time.Time{}
+	}
+	// PRs opened since the oldest stored run have all their comments in
+	// the scan; older ones without stored counts fall back to per-PR calls.
+	for number, ref := range prs {
+		if _, ok := refs[number]; !ok && !ref.CreatedAt.Before(since) {
+			refs[number] = ref
+		}
+	}
+	return base, refs, since
+}
+
+// dropUpdatedSince removes the breakdowns of PRs updated at or after cutoff,
+// or at an unknown time, so they are counted per PR, and reports how many
+// PRs that is.
+func dropUpdatedSince(breakdowns map[int]services.CommentsBreakdown, prs map[int]services.PRRef, cutoff time.Time) int {
+	n := 0
+	for number, ref := range prs {
+		if ref.UpdatedAt.IsZero() || !ref.UpdatedAt.Before(cutoff) {
+			delete(breakdowns, number)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,111 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/db"
+	"github.com/dickeyy/github-scraper/services"
+)
+
+func TestDropUpdatedSince(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prs := map[int]services.PRRef{
+		1: {UpdatedAt: cutoff.Add(-time.Minute)},
+		2: {UpdatedAt: cutoff},
+		3: {UpdatedAt: cutoff.Add(time.Minute)},
+		4: {},
+	}
+	breakdowns := map[int]services.CommentsBreakdown{1: {TotalComments: 1}, 2: {TotalComments: 2}, 3: {TotalComments: 3}}
+	if n := dropUpdatedSince(breakdowns, prs, cutoff); n != 3 {
+		t.Errorf("dropUpdatedSince = %d, want 3", n)
+	}
+	if _, ok := breakdowns[1]; !ok || len(breakdowns) != 1 {
+		t.Errorf("kept %v, want only #1, last updated before the cutoff", breakdowns)
+	}
+}
+
+func TestIncrementalCutoff(t *testing.T) {
+	older := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newer := older.Add(48 * time.Hour)
+	run := func(at time.Time) string { return at.Format(runIDLayout) }
+	counts := func(at time.Time) db.StoredComments {
+		return db.StoredComments{RunID: run(at), Total: 3, Issue: 2, Review: 1}
+	}
+	prs := map[int]services.PRRef{
+		1: {CreatedAt: older.Add(-time.Hour)},
+		2: {CreatedAt: older.Add(-time.Hour)},
+		3: {CreatedAt: older.Add(time.Hour)},  // opened after the oldest run
+		4: {CreatedAt: older.Add(-time.Hour)}, // never stored, older than the scan
+		5: {CreatedAt: older.Add(-time.Hour)},
+		6: {CreatedAt: older.Add(-time.Hour)},
+		7: {CreatedAt: older.Add(-time.Hour)},
+		8: {CreatedAt: older.Add(-time.Hour)},
+	}
+	truncated := counts(older)
+	truncated.Truncated = true
+	unsplit := counts(older)
+	unsplit.Issue, unsplit.Review = 0, 0
+	badRun := counts(older)
+	badRun.RunID = "manual"
+	noBots := counts(older)
+	noBots.Bot = 1
+	stored := map[int]db.StoredComments{
+		1: counts(older),
+		2: counts(newer),
+		5: truncated,
+		6: unsplit,
+		7: badRun,
+		8: noBots,
+	}
+
+	tests := []struct {
+		name         string
+		stored       map[int]db.StoredComments
+		botBreakdown bool
+		wantSince    time.Time
+		// wantRefs maps the PRs to scan to their CountSince.
+		wantRefs map[int]time.Time
+	}{
+		{
+			name: "nothing stored",
+		},
+		{
+			name:      "stored runs",
+			stored:    stored,
+			wantSince: older,
+			wantRefs:  map[int]time.Time{1: older, 2: newer, 3: {}, 8: older},
+		},
+		{
+			name:         "bot breakdown needs stored bots",
+			stored:       stored,
+			botBreakdown: true,
+			wantSince:    older,
+			wantRefs:     map[int]time.Time{1: older, 2: newer, 3: {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, refs, since := incrementalCutoff(tt.stored, prs, tt.botBreakdown)
+			if !since.Equal(tt.wantSince) {
+				t.Errorf("since = %v, want %v", since, tt.wantSince)
+			}
+			if len(refs) != len(tt.wantRefs) {
+				t.Errorf("refs = %v, want PRs %v", refs, tt.wantRefs)
+			}
+			for number, at := range tt.wantRefs {
+				ref, ok := refs[number]
+				if !ok {
+					t.Errorf("#%d not scanned", number)
+					continue
+				}
+				if !ref.CountSince.Equal(at) {
+					t.Errorf("#%d counts since %v, want %v", number, ref.CountSince, at)
+				}
+				if _, ok := tt.stored[number]; ok && base[number].TotalComments != 3 {
+					t.Errorf("#%d base = %+v, want the stored counts", number, base[number])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// RunMetrics is what WriteMetricsFile reports about a finished run.
+type RunMetrics struct {
+	Stats       RunStats
+	Duration    time.Duration
+	APIRequests int64
+	Success     bool
+	FinishedAt  time.Time
+}
+
+// FormatMetrics renders m in the Prometheus text exposition format used by
+// node_exporter's textfile collector. Every metric describes the last run,
+// so all are gauges.
+func FormatMetrics(m RunMetrics) []byte {
+	var b bytes.Buffer
+	gauge := func(name, help string, v float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+	}
+	success := 0.0
+	if m.Success {
+		success = 1
+	}
+	gauge("github_scraper_last_run_success", "Whether the last run finished without error.", success)
+	gauge("github_scraper_last_run_timestamp_seconds", "Unix time the last run finished.", float64(m.FinishedAt.Unix()))
+	gauge("github_scraper_last_run_duration_seconds", "Duration of the last run.", m.Duration.Seconds())
+	gauge("github_scraper_last_run_prs", "PRs enumerated for processing in the last run.", float64(m.Stats.Total))
+	gauge("github_scraper_last_run_prs_processed", "PRs processed without error in the last run.", float64(m.Stats.Processed))
+	gauge("github_scraper_last_run_prs_inserted", "Rows written to the output in the last run.", float64(m.Stats.Inserted))
+	gauge("github_scraper_last_run_prs_filtered", "Rows dropped by filters in the last run.", float64(m.Stats.Filtered))
+	gauge("github_scraper_last_run_api_requests", "GitHub API requests sent in the last run, including retries.", float64(m.APIRequests))
+	exhausted := 0.0
+	if m.Stats.BudgetExhausted {
+		exhausted = 1
+	}
+	gauge("github_scraper_last_run_budget_exhausted", "Whether the last run stopped early because -max-requests ran out.", exhausted)
+
+	const errName = "github_scraper_last_run_errors"
+	fmt.Fprintf(&b, "# HELP %s PRs that failed in the last run, by error class.\n# TYPE %s gauge\n", errName, errName)
+	classes := []string{ErrClassRateLimit, ErrClassNotFound, ErrClassDB, ErrClassTimeout, ErrClassBudget, ErrClassOther}
+	for class := range m.Stats.ErrorsByClass {
+		if !slices.Contains(classes, class) {
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		fmt.Fprintf(&b, "%s{class=%q} %d\n", errName, class, m.Stats.ErrorsByClass[class])
+	}
+	return b.Bytes()
+}
+
+// WriteMetricsFile writes m to path atomically, so the collector never
+// reads a partial file.
+func WriteMetricsFile(path string, m RunMetrics) error {
+	return writeFileAtomic(path, FormatMetrics(m))
+}
+
+// writeFileAtomic writes data to a temp file in path's directory and
+// renames it over path, so readers see the old or the new file, never a
+// partial one.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"slices"
+	"strings"
+)
+
+// PR origins stored in the origin column.
+const (
+	// OriginHuman is a PR a person opened from a branch of the repo itself.
+	OriginHuman = "human"
+	// OriginBot is a PR opened by a bot account, from anywhere.
+	OriginBot = "bot"
+	// OriginForkExternal is a PR a person opened from a fork.
+	OriginForkExternal = "fork-external"
+	// OriginAutomation is a PR opened under a person's account from a
+	// branch named like a tool's, e.g. a release or dependency bot using a
+	// personal token.
+	OriginAutomation = "automation"
+)
+
+// automationRefPrefixes are head branch prefixes of common tools that
+// open PRs under personal tokens.
+var automationRefPrefixes = []string{
+	"dependabot/",
+	"renovate/",
+	"snyk-",
+	"release-please--",
+	"changeset-release/",
+	"create-pull-request/",
+	"github-actions/",
+	"automation/",
+	"auto-update/",
+	"backport-",
+	"imgbot/",
+	"whitesource/",
+	"mergify/",
+	"pre-commit-ci-update-config",
+}
+
+// classifyOrigin derives a PR's origin from its author, the author's
+// account type, whether it came from a fork, and its head branch. A bot
+// account wins over everything else, even when it opens the PR from a fork;
+// botLogins are treated as bots too (matched case-insensitively). A fork's
+// branch names are its owner's business, so automation is only inferred
+// from branches in the repo itself. Deleted accounts count as people.
+func classifyOrigin(author, authorType string, crossRepo bool, headRef string, botLogins []string) string {
+	isBot := authorType == "Bot" || strings.HasSuffix(author, "[bot]") ||
+		(author != "" && slices.ContainsFunc(botLogins, func(l string) bool { return strings.EqualFold(l, author) }))
+	switch {
+	case isBot:
+		return OriginBot
+	case crossRepo:
+		return OriginForkExternal
+	case automationRef(headRef):
+		return OriginAutomation
+	}
+	return OriginHuman
+}
+
+// automationRef reports whether ref is named like a tool's branch.
+func automationRef(ref string) bool {
+	ref = strings.ToLower(ref)
+	for _, p := range automationRefPrefixes {
+		if strings.HasPrefix(ref, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseOwnerRenames parses "old=new" pairs separated by commas into a map
+// keyed by the lowercased old owner. Empty input yields a nil map.
+func ParseOwnerRenames(s string) (map[string]string, error) {
+	var renames map[string]string
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("owner rename %q: expected old=new", pair)
+		}
+		if renames == nil {
+			renames = make(map[string]string)
+		}
+		renames[strings.ToLower(from)] = to
+	}
+	return renames, nil
+}
+
+// storedOwner returns the owner rows are stored under: owner's entry in
+// renames, matched case-insensitively like GitHub logins, or owner itself.
+func storedOwner(owner string, renames map[string]string) string {
+	if to, ok := renames[strings.ToLower(owner)]; ok {
+		return to
+	}
+	return owner
+}
@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// ParseTimePrecision maps a -time-precision value to the unit timestamps are
+// truncated to.
+func ParseTimePrecision(s string) (time.Duration, error) {
+	switch s {
+	case "micro":
+		return time.Microsecond, nil
+	case "second":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("unknown time precision %q; expected micro or second", s)
+	}
+}
+
+// truncateTimes truncates every timestamp stored with a row to precision.
+// A zero precision leaves them untouched.
+func truncateTimes(row *types.PRRow, precision time.Duration) {
+	if precision <= 0 {
+		return
+	}
+	row.CreatedAt = row.CreatedAt.Truncate(precision)
+	// The pointed-to times may be shared with enumeration data.
+	for _, t := range []**time.Time{&row.ClosedAt, &row.MergedAt} {
+		if *t != nil {
+			truncated := (*t).Truncate(precision)
+			*t = &truncated
+		}
+	}
+	for i := range row.Deployments {
+		row.Deployments[i].CreatedAt = row.Deployments[i].CreatedAt.Truncate(precision)
+	}
+}
@@ -0,0 +1,53 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// Probe runs the complete pipeline, with every enrichment opts enables, for
+// the repo's newest PR and returns the built row without storing it. Row
+// filters other than the PR selection ones are ignored so the row is always
+// returned.
+func Probe(ctx context.Context, owner, repo string, opts Options) (types.PRRow, error) {
+	var c captureSink
+	opts.Sink = &c
+	opts.Limit = 1
+	opts.Concurrency = 1
+	opts.AdaptiveConcurrency = false
+	opts.MinComments = 0
+	opts.FailFast = true
+	opts.DiffReport = false
+	opts.CommentAuthors = false
+
+	stats, err := Run(ctx, owner, repo, opts)
+	if err != nil {
+		return types.PRRow{}, err
+	}
+	if len(c.rows) == 0 {
+		if stats.Total == 0 {
+			return types.PRRow{}, errors.New("no PRs to probe")
+		}
+		return types.PRRow{}, fmt.Errorf("probe of %s/%s produced no row", owner, repo)
+	}
+	return c.rows[0], nil
+}
+
+// captureSink keeps written rows in memory.
+type captureSink struct {
+	mu   sync.Mutex
+	rows []types.PRRow
+}
+
+func (c *captureSink) Write(_ context.Context, row types.PRRow) error {
+	c.mu.Lock()
+	c.rows = append(c.rows, row)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *captureSink) Close() error { return nil }
@@ -0,0 +1,324 @@
+package scraper
+
+import (
+	"context"
+	"time"
+
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/dickeyy/github-scraper/sinks"
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog/log"
+)
+
+// repoJobs holds what Run's workers share while building a repo's rows:
+// the run's settings and everything loaded for the repo up front.
+type repoJobs struct {
+	owner, rowOwner, repo string
+	opts                  Options
+	now                   time.Time
+	// restFallback means PRs were enumerated over REST and lack what the
+	// bulk GraphQL query returns, so each is fetched in full.
+	restFallback bool
+
+	store      *liteStore
+	breakdowns map[int]services.CommentsBreakdown
+	prRefs     map[int]services.PRRef
+	protection map[string]services.BranchProtection
+	codeowners Codeowners
+	tally      *authorTally
+
+	sink     sinks.Sink
+	buffered sinks.Buffered
+}
+
+// reviewTiming is what the review response latency is computed from.
+type reviewTiming struct {
+	requested   *time.Time
+	submissions []time.Time
+}
+
+// process builds, checks and writes the row of one PR.
+func (r *repoJobs) process(ctx context.Context, j job) result {
+	fail := func(err error) result { return result{number: j.number, err: err} }
+
+	lite, err := r.store.get(j.number)
+	if err != nil {
+		return fail(err)
+	}
+	breakdown, err := r.commentsBreakdown(ctx, j.number)
+	if err != nil {
+		return fail(err)
+	}
+
+	var (
+		row     types.PRRow
+		commits *services.CommitInfo
+		timing  reviewTiming
+	)
+	if r.restFallback {
+		row, commits, timing, err = r.restRow(ctx, j.number, breakdown)
+	} else {
+		row, commits, timing, err = r.liteRow(ctx, lite, breakdown)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if r.tally != nil && r.tally.add(breakdown.ByLogin) {
+		log.Warn().Str("owner", r.owner).Str("repo", r.repo).Int("max_comment_authors", r.opts.MaxCommentAuthors).Msg("comment author aggregation truncated; new authors are no longer tracked")
+	}
+	if r.opts.BotBreakdown {
+		setBotBreakdown(&row, breakdown)
+	}
+	if p, ok := r.protection[lite.BaseRef]; ok {
+		setProtection(&row, p)
+	}
+	if r.opts.IncludeTimeline && r.opts.IncludeReviewers {
+		row.ReviewResponseLatency = reviewResponseLatency(timing.requested, timing.submissions)
+	}
+
+	row.LastRunID = r.opts.RunID
+	truncateTimes(&row, r.opts.TimePrecision)
+
+	if commits != nil {
+		setCommitCount(&row, *commits, r.opts.CommitSource)
+	}
+	if r.opts.IncludeBody {
+		r.setBody(&row, lite)
+	}
+	var owners []string
+	if r.opts.IncludeFiles {
+		if owners, err = r.addFiles(ctx, &row, j.number); err != nil {
+			return fail(err)
+		}
+	}
+
+	if diff, ok := commentDivergence(row, r.opts.CommentDivergence); ok {
+		log.Warn().Str("owner", r.owner).Str("repo", r.repo).Int("number", row.ID).Int("comment_count", row.CommentCount).Int("github_comment_count", *row.GitHubCommentCount).Int("diff", diff).Msg("computed comment count diverges from GitHub's")
+	}
+
+	if r.opts.ValidateRows {
+		if verr := row.Validate(); verr != nil {
+			return fail(verr)
+		}
+	}
+
+	if row.CommentCount < r.opts.MinComments {
+		return result{number: j.number, row: row, filtered: true}
+	}
+	// Already applied to enumerated sizes unless they were unknown.
+	if r.restFallback && !linesInRange(row.LinesChanged, r.opts.MinLinesChanged, r.opts.MaxLinesChanged) {
+		return result{number: j.number, row: row, filtered: true}
+	}
+
+	ins, acc := false, false
+	if r.sink != nil {
+		if err := r.sink.Write(ctx, row); err != nil {
+			return fail(&sinkError{err: err})
+		}
+		ins, acc = r.buffered == nil, r.buffered != nil
+	}
+	return result{number: j.number, row: row, inserted: ins, accepted: acc, owners: owners}
+}
+
+// commentsBreakdown returns the PR's comment counts from the repo-level
+// preload, or counts them per PR when the preload does not cover it.
+func (r *repoJobs) commentsBreakdown(ctx context.Context, number int) (services.CommentsBreakdown, error) {
+	if b, ok := r.breakdowns[number]; ok {
+		return b, nil
+	}
+	return services.GetPRCommentsBreakdown(ctx, r.owner, r.repo, number, r.prRefs[number], r.opts.Comments)
+}
+
+// restRow fetches a PR enumerated over REST in full, since REST list results
+// lack diff stats, and fetches each requested enrichment separately.
+func (r *repoJobs) restRow(ctx context.Context, number int, breakdown services.CommentsBreakdown) (types.PRRow, *services.CommitInfo, reviewTiming, error) {
+	var timing reviewTiming
+	full, err := services.GetPRWithBackoff(ctx, r.owner, r.repo, number)
+	if err != nil {
+		return types.PRRow{}, nil, timing, err
+	}
+	row, err := buildPRRow(full, r.rowOwner, r.repo, number, breakdown, r.now, r.opts.Strict)
+	if err != nil {
+		return row, nil, timing, err
+	}
+	row.Origin = classifyOrigin(full.GetUser().GetLogin(), full.GetUser().GetType(), services.IsCrossRepository(full), full.GetHead().GetRef(), r.opts.Comments.BotLogins)
+
+	var commits *services.CommitInfo
+	if r.opts.IncludeCommits {
+		info, err := services.GetCommitInfo(ctx, r.owner, r.repo, full)
+		if err != nil {
+			return row, nil, timing, err
+		}
+		commits = &info
+	}
+	if r.opts.IncludeReviewers {
+		if err := r.addReviewers(ctx, &row, number, &timing); err != nil {
+			return row, nil, timing, err
+		}
+	}
+	if r.opts.IncludeTimeline || r.opts.IncludeAutoMerge {
+		if err := r.addTimeline(ctx, &row, number, full, &timing); err != nil {
+			return row, nil, timing, err
+		}
+	}
+	if r.opts.IncludeReviewCounts {
+		counts, err := services.GetPRReviewCounts(ctx, r.owner, r.repo, number)
+		if err != nil {
+			return row, nil, timing, err
+		}
+		setReviewCounts(&row, counts)
+	}
+	if r.opts.IncludeDeployments {
+		if err := r.addDeployments(ctx, &row); err != nil {
+			return row, nil, timing, err
+		}
+	}
+	return row, commits, timing, nil
+}
+
+// liteRow builds the row of a PR enumerated over GraphQL, whose bulk query
+// already returned the requested enrichments, and fetches what it cut off.
+func (r *repoJobs) liteRow(ctx context.Context, lite services.PRLite, breakdown services.CommentsBreakdown) (types.PRRow, *services.CommitInfo, reviewTiming, error) {
+	timing := reviewTiming{requested: lite.FirstReviewRequestAt, submissions: lite.ReviewSubmissions}
+	row, err := buildLiteRow(lite, r.rowOwner, r.repo, breakdown, r.now, r.opts.Strict)
+	if err != nil {
+		return row, nil, timing, err
+	}
+	row.Origin = classifyOrigin(lite.Author, lite.AuthorType, lite.CrossRepository, lite.HeadRef, r.opts.Comments.BotLogins)
+	row.Deployments = lite.Deployments
+	row.Reviewers = lite.Reviewers
+	row.ReviewRequestEvents = lite.ReviewRequestEvents
+	if lite.ReviewCounts != nil {
+		setReviewCounts(&row, *lite.ReviewCounts)
+	}
+	// Only the first 100 reviews come with the bulk query.
+	if lite.ReviewsTruncated {
+		if err := r.addReviewers(ctx, &row, lite.Number, &timing); err != nil {
+			return row, nil, timing, err
+		}
+	}
+	if lite.ResolvedThreads != nil {
+		if err := r.addReviewThreads(ctx, &row, lite); err != nil {
+			return row, nil, timing, err
+		}
+	}
+	return row, lite.Commits, timing, nil
+}
+
+// addReviewers lists the PR's reviews to store its reviewers and when they
+// reviewed.
+func (r *repoJobs) addReviewers(ctx context.Context, row *types.PRRow, number int, timing *reviewTiming) error {
+	reviews, err := services.GetPRReviews(ctx, r.owner, r.repo, number)
+	if err != nil {
+		return err
+	}
+	row.Reviewers = services.ReviewersOf(reviews, row.Author)
+	timing.submissions = services.ReviewSubmissions(reviews, row.Author)
+	return nil
+}
+
+// addTimeline reads review-request churn and auto-merge from the PR's
+// timeline, as requested.
+func (r *repoJobs) addTimeline(ctx context.Context, row *types.PRRow, number int, full *github.PullRequest, timing *reviewTiming) error {
+	events, err := services.GetPRTimeline(ctx, r.owner, r.repo, number)
+	if err != nil {
+		return err
+	}
+	if r.opts.IncludeTimeline {
+		n := services.CountReviewRequestEvents(events)
+		row.ReviewRequestEvents = &n
+		timing.requested = services.FirstReviewRequest(events)
+	}
+	if r.opts.IncludeAutoMerge {
+		autoMerged := services.AutoMerged(full.GetMerged(), events)
+		row.AutoMerged = &autoMerged
+		row.AutoMergeEnabledBy = full.GetAutoMerge().GetEnabledBy().GetLogin()
+	}
+	return nil
+}
+
+// addDeployments stores the deployments of the PR's merge commit; unmerged
+// PRs get none.
+func (r *repoJobs) addDeployments(ctx context.Context, row *types.PRRow) error {
+	row.Deployments = []types.Deployment{}
+	if row.MergeCommitSHA == "" {
+		return nil
+	}
+	deps, err := services.GetDeployments(ctx, r.owner, r.repo, row.MergeCommitSHA)
+	if err != nil {
+		return err
+	}
+	row.Deployments = deps
+	return nil
+}
+
+// addReviewThreads stores the resolved and unresolved thread counts,
+// paging through the threads past the first page the bulk query returned.
+func (r *repoJobs) addReviewThreads(ctx context.Context, row *types.PRRow, lite services.PRLite) error {
+	resolved, unresolved := *lite.ResolvedThreads, *lite.UnresolvedThreads
+	if lite.ReviewThreadsCursor != "" {
+		res, unres, err := services.CountReviewThreads(ctx, r.owner, r.repo, lite.Number, lite.ReviewThreadsCursor)
+		if err != nil {
+			return err
+		}
+		resolved, unresolved = resolved+res, unresolved+unres
+	}
+	row.ResolvedThreads, row.UnresolvedThreads = &resolved, &unresolved
+	return nil
+}
+
+// setBody stores the description's word and checklist counts and, with
+// StoreBodies, the (possibly redacted) title and description.
+func (r *repoJobs) setBody(row *types.PRRow, lite services.PRLite) {
+	bs := ParseBody(lite.Body)
+	row.BodyWordCount, row.ChecklistTotal, row.ChecklistChecked = &bs.Words, &bs.ChecklistTotal, &bs.ChecklistChecked
+	if !r.opts.StoreBodies {
+		return
+	}
+	row.Title, row.Body = lite.Title, lite.Body
+	if r.opts.Redactor != nil {
+		row.Title, row.Body = r.opts.Redactor.Redact(row.Title), r.opts.Redactor.Redact(row.Body)
+	}
+}
+
+// addFiles lists the PR's changed files to count them by status and
+// extension, and returns the CODEOWNERS owners of the PR when asked for.
+func (r *repoJobs) addFiles(ctx context.Context, row *types.PRRow, number int) ([]string, error) {
+	files, err := services.GetPRFiles(ctx, r.owner, r.repo, number)
+	if err != nil {
+		return nil, err
+	}
+	counts := services.CountFileStatuses(files)
+	row.FilesAdded, row.FilesModified, row.FilesRemoved = &counts.Added, &counts.Modified, &counts.Removed
+	row.FileTypes = services.CountFileTypes(files, services.MaxFileTypes)
+	if !r.opts.Codeowners {
+		return nil, nil
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.GetFilename()
+	}
+	return r.codeowners.PROwners(paths), nil
+}
+
+// setBotBreakdown stores the PR's bot comments by login, as an empty map
+// when it has none.
+func setBotBreakdown(row *types.PRRow, breakdown services.CommentsBreakdown) {
+	row.BotCommentBreakdown = breakdown.BotsByLogin
+	if row.BotCommentBreakdown == nil {
+		row.BotCommentBreakdown = map[string]int{}
+	}
+}
+
+// setCommitCount stores the PR's commit count for source, CommitSourcePR
+// when unset.
+func setCommitCount(row *types.PRRow, commits services.CommitInfo, source string) {
+	if source == "" {
+		source = CommitSourcePR
+	}
+	n := commitCount(commits, row.Status == "merged", source)
+	row.CommitCount = &n
+	row.CommitSource = source
+}
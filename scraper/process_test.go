@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// rowSink keeps the rows written to it, or fails them all with err.
+type rowSink struct {
+	rows []types.PRRow
+	err  error
+}
+
+func (s *rowSink) Write(_ context.Context, row types.PRRow) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.rows = append(s.rows, row)
+	return nil
+}
+
+func (s *rowSink) Close() error { return nil }
+
+func TestRepoJobsProcess(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lite := services.PRLite{
+		Number: 7, State: "OPEN", CreatedAt: created, UpdatedAt: created, Author: "alice", Additions: 3, Deletions: 1,
+		Body:    "Fixes it\n\n- [x] tests\n- [ ] docs",
+		Commits: &services.CommitInfo{PRCommits: 2},
+	}
+	breakdown := services.CommentsBreakdown{IssueComments: 2, TotalComments: 2}
+	refused := errors.New("refused")
+
+	tests := []struct {
+		name    string
+		opts    Options
+		sinkErr error
+		check   func(t *testing.T, res result, rows []types.PRRow)
+	}{
+		{"stored with enrichments", Options{RunID: "run-1", BotBreakdown: true, IncludeBody: true}, nil, func(t *testing.T, res result, rows []types.PRRow) {
+			if res.err != nil || !res.inserted || len(rows) != 1 {
+				t.Fatalf("result %+v with %d rows written, want one inserted row", res, len(rows))
+			}
+			row := rows[0]
+			if row.LastRunID != "run-1" || row.CommentCount != 2 {
+				t.Errorf("LastRunID %q, CommentCount %d; want run-1 and 2", row.LastRunID, row.CommentCount)
+			}
+			if row.BotCommentBreakdown == nil || len(row.BotCommentBreakdown) != 0 {
+				t.Errorf("BotCommentBreakdown = %v, want an empty map", row.BotCommentBreakdown)
+			}
+			if row.CommitCount == nil || *row.CommitCount != 2 || row.CommitSource != CommitSourcePR {
+				t.Errorf("CommitCount %v from %q, want 2 from pr", row.CommitCount, row.CommitSource)
+			}
+			if row.ChecklistTotal == nil || *row.ChecklistTotal != 2 || *row.ChecklistChecked != 1 {
+				t.Errorf("checklist %v/%v, want 1/2", row.ChecklistChecked, row.ChecklistTotal)
+			}
+			if row.Body != "" {
+				t.Errorf("Body = %q without StoreBodies, want it left out", row.Body)
+			}
+		}},
+		{"filtered by comments", Options{MinComments: 3}, nil, func(t *testing.T, res result, rows []types.PRRow) {
+			if !res.filtered || len(rows) != 0 {
+				t.Errorf("result %+v with %d rows written, want filtered and nothing written", res, len(rows))
+			}
+		}},
+		{"sink error", Options{}, refused, func(t *testing.T, res result, rows []types.PRRow) {
+			var serr *sinkError
+			if !errors.As(res.err, &serr) || !errors.Is(res.err, refused) {
+				t.Errorf("err = %v, want the sink's error as a *sinkError", res.err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newLiteStore([]services.PRLite{lite}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			sink := &rowSink{err: tt.sinkErr}
+			r := &repoJobs{
+				owner: "octo", rowOwner: "octo", repo: "demo",
+				opts:       tt.opts,
+				now:        created.Add(time.Hour),
+				store:      store,
+				breakdowns: map[int]services.CommentsBreakdown{7: breakdown},
+				sink:       sink,
+			}
+			tt.check(t, r.process(context.Background(), job{number: 7}), sink.rows)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressInterval is how often Run logs progress and StreamProgress
+// writes events.
+const ProgressInterval = 5 * time.Second
+
+// Run phases reported in ProgressSnapshot.Phase, in order. PhaseDone is
+// only set by StreamProgress's final event.
+const (
+	PhaseEnumerating      = "enumerating"
+	PhaseScanningComments = "scanning-comments"
+	PhaseProcessing       = "processing"
+	PhaseDone             = "done"
+)
+
+// Progress counts PRs as Run works through them, for callers that drive
+// their own progress display. It is safe for concurrent use; poll it with
+// Snapshot. One Progress may be shared by several runs (e.g. a batch), in
+// which case it sums them.
+type Progress struct {
+	mu   sync.Mutex
+	snap ProgressSnapshot
+}
+
+// ProgressSnapshot is a consistent copy of a Progress's counters.
+type ProgressSnapshot struct {
+	// Total is the number of PRs queued so far, after filters and limits.
+	Total     int64 `json:"total"`
+	Processed int64 `json:"processed"`
+	Inserted  int64 `json:"inserted"`
+	Filtered  int64 `json:"filtered"`
+	Errors    int64 `json:"errors"`
+	// Phase is the phase the most recently advanced run is in; empty
+	// before any run starts.
+	Phase string `json:"phase"`
+}
+
+// Remaining is the number of queued PRs not yet processed or failed.
+func (s ProgressSnapshot) Remaining() int64 {
+	return max(s.Total-s.Processed-s.Errors, 0)
+}
+
+// Snapshot returns the current counters, all read at the same instant.
+func (p *Progress) Snapshot() ProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snap
+}
+
+func (p *Progress) setPhase(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snap.Phase = phase
+}
+
+func (p *Progress) addTotal(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snap.Total += int64(n)
+}
+
+// record counts one finished PR.
+func (p *Progress) record(res result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if res.err != nil {
+		p.snap.Errors++
+		return
+	}
+	p.snap.Processed++
+	if res.filtered {
+		p.snap.Filtered++
+	}
+	if res.inserted {
+		p.snap.Inserted++
+	}
+}
+
+// settle counts rows a buffered sink stored or failed to store after
+// record counted them as processed.
+func (p *Progress) settle(stored, failed int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snap.Inserted += stored
+	p.snap.Processed -= failed
+	p.snap.Errors += failed
+}
+
+// StreamProgress writes p's snapshot to w as a JSON line every interval
+// until ctx is done, then writes a last one in PhaseDone. It is meant for
+// wrapper UIs reading progress from a pipe.
+func StreamProgress(ctx context.Context, w io.Writer, p *Progress, interval time.Duration) error {
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.setPhase(PhaseDone)
+			return enc.Encode(p.Snapshot())
+		case <-ticker.C:
+			if err := enc.Encode(p.Snapshot()); err != nil {
+				return err
+			}
+		}
+	}
+}
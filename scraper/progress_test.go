@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressSnapshotsAreConsistent(t *testing.T) {
+	const workers, perWorker = 8, 500
+	p := &Progress{}
+	p.addTotal(workers * perWorker)
+
+	stop := make(chan struct{})
+	polled := make(chan error, 1)
+	go func() {
+		var last ProgressSnapshot
+		for {
+			s := p.Snapshot()
+			// Every result is counted as inserted, filtered or failed in
+			// one step, so the counters always add up.
+			switch {
+			case s.Inserted+s.Filtered != s.Processed:
+				polled <- errors.New("inserted + filtered != processed")
+				return
+			case s.Processed < last.Processed || s.Errors < last.Errors:
+				polled <- errors.New("counters went backwards")
+				return
+			case s.Processed+s.Errors > s.Total:
+				polled <- errors.New("more results than queued PRs")
+				return
+			}
+			last = s
+			select {
+			case <-stop:
+				polled <- nil
+				return
+			default:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				switch (w + i) % 3 {
+				case 0:
+					p.record(result{inserted: true})
+				case 1:
+					p.record(result{filtered: true})
+				default:
+					p.record(result{err: errors.New("boom")})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	if err := <-polled; err != nil {
+		t.Fatal(err)
+	}
+
+	s := p.Snapshot()
+	if s.Processed+s.Errors != workers*perWorker || s.Remaining() != 0 {
+		t.Errorf("final snapshot %+v does not account for every PR", s)
+	}
+}
+
+func TestProgressSettle(t *testing.T) {
+	p := &Progress{}
+	p.addTotal(3)
+	for range 3 {
+		p.record(result{accepted: true})
+	}
+	p.settle(2, 1)
+	want := ProgressSnapshot{Total: 3, Processed: 2, Inserted: 2, Errors: 1}
+	if s := p.Snapshot(); s != want {
+		t.Errorf("after settle %+v, want %+v", s, want)
+	}
+}
+
+func TestStreamProgressToPipe(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	p := &Progress{}
+	p.setPhase(PhaseProcessing)
+	p.addTotal(3)
+	p.record(result{inserted: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- StreamProgress(ctx, w, p, interval)
+		w.Close()
+	}()
+
+	lines := bufio.NewScanner(r)
+	var events []map[string]any
+	for len(events) < 3 && lines.Scan() {
+		var ev map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &ev); err != nil {
+			t.Fatalf("event %q is not a JSON object: %v", lines.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	// One event per interval: three take at least three intervals.
+	if elapsed := time.Since(start); elapsed < 3*interval {
+		t.Errorf("3 events arrived within %v, want one per %v", elapsed, interval)
+	}
+	cancel()
+	for lines.Scan() {
+		var ev map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if err := <-streamed; err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"total": 3.0, "processed": 1.0, "inserted": 1.0, "filtered": 0.0, "errors": 0.0, "phase": PhaseProcessing}
+	if !reflect.DeepEqual(events[0], want) {
+		t.Errorf("event = %v, want %v", events[0], want)
+	}
+	last := events[len(events)-1]
+	if last["phase"] != PhaseDone || slices.ContainsFunc(events[:len(events)-1], func(ev map[string]any) bool { return ev["phase"] == PhaseDone }) {
+		t.Errorf("phases %v, want only the final event in %q", phases(events), PhaseDone)
+	}
+}
+
+func phases(events []map[string]any) []any {
+	var out []any
+	for _, ev := range events {
+		out = append(out, ev["phase"])
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/rs/zerolog/log"
+)
+
+// loadProtection fetches the protection rule of each distinct base branch
+// among lites. Branches missing from the result are unknown: reading rules
+// needs admin or maintain access, and when the token lacks it the rest are
+// not queried, since the answer would be the same.
+func loadProtection(ctx context.Context, owner, repo string, lites []services.PRLite) (map[string]services.BranchProtection, error) {
+	rules := make(map[string]services.BranchProtection)
+	seen := make(map[string]bool)
+	for _, l := range lites {
+		if seen[l.BaseRef] || l.BaseRef == "" {
+			continue
+		}
+		seen[l.BaseRef] = true
+		p, err := services.GetBranchProtection(ctx, owner, repo, l.BaseRef)
+		if err != nil {
+			if errors.Is(err, services.ErrBudgetExhausted) {
+				log.Warn().Str("owner", owner).Str("repo", repo).Msg("request budget exhausted while reading branch protection; leaving the rest unknown")
+				return rules, nil
+			}
+			if services.IsPermissionDenied(err) {
+				log.Warn().Err(err).Str("owner", owner).Str("repo", repo).Msg("token cannot read branch protection rules; leaving protection columns empty")
+				return map[string]services.BranchProtection{}, nil
+			}
+			return nil, err
+		}
+		if p != nil {
+			rules[l.BaseRef] = *p
+		}
+	}
+	return rules, nil
+}
+
+// setProtection fills row's protection columns from p.
+func setProtection(row *types.PRRow, p services.BranchProtection) {
+	row.BaseProtected = &p.Protected
+	if p.Protected {
+		row.RequiresApprovingReviews = &p.RequiresApprovingReviews
+		row.RequiredApprovingReviews = &p.RequiredApprovingReviewCount
+	}
+}
@@ -0,0 +1,25 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/dickeyy/github-scraper/services"
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestSetProtection(t *testing.T) {
+	var protected, unprotected types.PRRow
+	setProtection(&protected, services.BranchProtection{Protected: true, RequiresApprovingReviews: true, RequiredApprovingReviewCount: 2})
+	setProtection(&unprotected, services.BranchProtection{})
+
+	if protected.BaseProtected == nil || !*protected.BaseProtected ||
+		protected.RequiresApprovingReviews == nil || !*protected.RequiresApprovingReviews ||
+		protected.RequiredApprovingReviews == nil || *protected.RequiredApprovingReviews != 2 {
+		t.Errorf("protected branch: %v %v %v", protected.BaseProtected, protected.RequiresApprovingReviews, protected.RequiredApprovingReviews)
+	}
+	// No rule: known to be unprotected, with no approval requirements.
+	if unprotected.BaseProtected == nil || *unprotected.BaseProtected ||
+		unprotected.RequiresApprovingReviews != nil || unprotected.RequiredApprovingReviews != nil {
+		t.Errorf("unprotected branch: %v %v %v", unprotected.BaseProtected, unprotected.RequiresApprovingReviews, unprotected.RequiredApprovingReviews)
+	}
+}
@@ -0,0 +1,27 @@
+package scraper
+
+import "time"
+
+// reviewResponseLatency returns the seconds from the first review request
+// to the first review submitted at or after it. It is nil when no review
+// was ever requested or none followed the request; reviews submitted
+// before anyone was asked don't count as responses.
+func reviewResponseLatency(requested *time.Time, submissions []time.Time) *int {
+	if requested == nil {
+		return nil
+	}
+	var first *time.Time
+	for _, s := range submissions {
+		if s.Before(*requested) {
+			continue
+		}
+		if first == nil || s.Before(*first) {
+			first = &s
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	secs := int(first.Sub(*requested) / time.Second)
+	return &secs
+}
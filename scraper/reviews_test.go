@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReviewResponseLatency(t *testing.T) {
+	requested := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		requested   *time.Time
+		submissions []time.Time
+		want        *int
+	}{
+		{"never requested", nil, []time.Time{requested}, nil},
+		{"never reviewed", &requested, nil, nil},
+		{"only reviewed before the request", &requested, []time.Time{requested.Add(-time.Hour)}, nil},
+		{"earliest later review", &requested, []time.Time{requested.Add(-time.Hour), requested.Add(3 * time.Hour), requested.Add(90 * time.Second)}, intPtr(90)},
+		{"reviewed at the request", &requested, []time.Time{requested}, intPtr(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reviewResponseLatency(tt.requested, tt.submissions)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Errorf("latency = %v, want %v", fmtIntPtr(got), fmtIntPtr(tt.want))
+			case *got != *tt.want:
+				t.Errorf("latency = %d, want %d", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(n int) *int { return &n }
+
+func fmtIntPtr(p *int) any {
+	if p == nil {
+		return "nil"
+	}
+	return *p
+}
@@ -2,12 +2,16 @@ package scraper
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"github.com/dickeyy/github-scraper/db"
 	"github.com/dickeyy/github-scraper/services"
+	"github.com/dickeyy/github-scraper/sinks"
 	"github.com/dickeyy/github-scraper/types"
 	"github.com/google/go-github/v74/github"
 	"github.com/rs/zerolog/log"
@@ -19,98 +23,561 @@ type result struct {
 	number   int
 	row      types.PRRow
 	inserted bool
+	// accepted is set instead of inserted when a buffered sink took the
+	// row; it is only stored once the sink is flushed.
+	accepted bool
+	filtered bool
 	err      error
+	// owners are the PR's CODEOWNERS owners with Options.Codeowners.
+	owners []string
+}
+
+// Options configures a scrape run.
+type Options struct {
+	// Sink receives built rows. When nil, rows are upserted into Postgres
+	// if db.Init was called and otherwise discarded. Run never closes it.
+	Sink sinks.Sink
+	// TimePrecision truncates stored timestamps to this unit; 0 keeps them
+	// as GitHub reports them.
+	TimePrecision time.Duration
+	// RunID is stored on every row as last_run_id. IDs must sort
+	// chronologically; see NewRunID.
+	RunID string
+	// DiffReport compares the stored rows against the previous run once the
+	// scrape finishes and returns the result in RunStats.Diff. It requires
+	// db.Init and a RunID.
+	DiffReport bool
+	// Concurrency is the number of workers for detail fetch + insert. With
+	// AdaptiveConcurrency it is the upper bound on active workers.
+	Concurrency int
+	// AdaptiveConcurrency scales active workers with the remaining rate budget.
+	AdaptiveConcurrency bool
+	// sharedWorkers, set by RunBatch, bounds the active workers of every
+	// repo in the batch together, so adaptive concurrency scales them down
+	// as one when the rate budget they share drains.
+	sharedWorkers *semaphore
+	// Comments controls comment classification, e.g. extra bot logins.
+	Comments services.CommentOptions
+	// Authors, when set, limits the run to PRs by these logins.
+	// ExcludeAuthors drops PRs by these logins and takes precedence.
+	Authors        []string
+	ExcludeAuthors []string
+	// RESTPages limits REST enumeration (used when GraphQL is unavailable)
+	// to a page range, for debugging that path.
+	RESTPages services.PageRange
+	// BaseRefs, when set, limits the run to PRs targeting one of these base
+	// branches.
+	BaseRefs []string
+	// MergedToDefault limits the run to merged PRs targeting the repo's
+	// default branch, looked up per repo; it replaces BaseRefs.
+	MergedToDefault bool
+	// Summarize computes RunStats.Summary for cross-repo comparison.
+	Summarize bool
+	// BusFactor computes RunStats.BusFactor over the run's merged PRs.
+	BusFactor bool
+	// Progress, when set, is updated as the run changes phase and PRs
+	// finish so the caller can poll it; it may be shared across concurrent
+	// runs.
+	Progress *Progress
+	// StartCursor resumes GraphQL enumeration after a RunStats.EndCursor of
+	// an earlier run of the same repo; see services.EnumerateOptions. It
+	// is ignored if enumeration falls back to REST.
+	StartCursor string
+	// Limit, when positive, processes only the newest Limit PRs left after
+	// filtering. Limited runs skip the repo-wide comment preload.
+	Limit int
+	// IncrementalComments replaces the comment preload with a scan of
+	// comments created since each PR's stored row was written, added to
+	// the stored counts. It needs Postgres.
+	IncrementalComments bool
+	// SpillThreshold, when positive, moves the enumerated PRs to a temporary
+	// file while they are processed if there are more than this many.
+	SpillThreshold int
+	// RetryOnEmpty retries a GraphQL enumeration that returned no PRs when
+	// the repo's PR count says it has some.
+	RetryOnEmpty bool
+	// ResumeFromNumber, when positive, skips PRs numbered above it so a
+	// manually restarted newest-first scrape picks up where it stopped.
+	ResumeFromNumber int
+	// Checkpoint keeps a checkpoint in Postgres while the run goes through
+	// its PRs, and resumes below the checkpoint an interrupted run left
+	// behind unless IgnoreCheckpoint is set. It is off for limited runs,
+	// and requires a sink that stores rows as they arrive.
+	Checkpoint       bool
+	IgnoreCheckpoint bool
+	// Since and Until, when set, limit the run to PRs created at or after
+	// Since and before Until. Enumeration stops once it passes Since.
+	Since time.Time
+	Until time.Time
+	// Incremental processes only PRs created since the newest PR stored
+	// for the repo, stopping enumeration there. Requires Postgres.
+	Incremental bool
+	// SincePRNumber, when positive, keeps only PRs numbered above it and
+	// stops GraphQL enumeration once it is reached.
+	SincePRNumber int
+	// FailFast aborts the run on the first PR error.
+	FailFast bool
+	// OwnerRenames maps lowercased old owner names to the owner rows are
+	// stored under, consolidating history across org or user renames.
+	OwnerRenames map[string]string
+	// CanonicalRepoCase stores rows under GitHub's casing of owner/repo
+	// rather than the casing the caller used.
+	CanonicalRepoCase bool
+	// MinComments drops rows with fewer comments before they are stored.
+	MinComments int
+	// MinLinesChanged and MaxLinesChanged, when positive, limit the run to
+	// PRs with additions plus deletions in that range.
+	MinLinesChanged int
+	MaxLinesChanged int
+	// Strict fails a PR whose data has unexpected nulls (e.g. a deleted
+	// author) instead of storing defaults.
+	Strict bool
+	// BotRatioThreshold warns (fails under Strict) when bot comments make
+	// up more than this share of a repo's comments; 0 disables the check.
+	BotRatioThreshold float64
+	// CommentDivergence is how far our computed comment count may differ
+	// from GitHub's totalCommentsCount before a warning is logged.
+	CommentDivergence int
+	// ValidateRows checks each row's invariants before it is stored and
+	// fails the PR if any are violated.
+	ValidateRows bool
+	// IncludeBody fetches PR descriptions to derive body statistics.
+	IncludeBody bool
+	// StoreBodies stores PR titles and descriptions (requires IncludeBody),
+	// passed through Redactor when it is set.
+	StoreBodies bool
+	Redactor    *Redactor
+	// IncludeChecks fetches the CI check contexts of each PR's head commit.
+	IncludeChecks bool
+	// IncludeCommits stores each PR's commit count, counted according to
+	// CommitSource.
+	IncludeCommits bool
+	// CommitSource is CommitSourcePR (the default) or CommitSourceMerged.
+	CommitSource string
+	// CommentAuthors aggregates comment counts per commenter over the run
+	// into RunStats.CommentAuthors, tracking at most MaxCommentAuthors
+	// distinct authors (0 for no limit).
+	CommentAuthors    bool
+	MaxCommentAuthors int
+	// BotBreakdown stores each PR's bot comments tallied by bot login.
+	BotBreakdown bool
+	// IncludeReviewers stores who reviewed each PR.
+	IncludeReviewers bool
+	// IncludeTimeline stores review-request churn from each PR's timeline.
+	IncludeTimeline bool
+	// IncludeReviewThreads stores resolved and unresolved review thread
+	// counts. Review threads are GraphQL-only, so they stay unset when
+	// enumeration falls back to REST.
+	IncludeReviewThreads bool
+	// IncludeReviewCounts stores how many reviews of each PR approved,
+	// requested changes, or only commented.
+	IncludeReviewCounts bool
+	// IncludeAutoMerge stores whether each PR was merged by auto-merge and
+	// who enabled pending auto-merge.
+	IncludeAutoMerge bool
+	// IncludeDeployments stores the deployments of each PR's merge commit.
+	IncludeDeployments bool
+	// Codeowners attributes each PR to the CODEOWNERS owners of the files
+	// it changed, counted in RunStats.Ownership. It needs IncludeFiles.
+	Codeowners bool
+	// IncludeProtection stores the protection rule of each PR's base
+	// branch, with one extra query per distinct base branch.
+	IncludeProtection bool
+	// IncludeFiles fetches each PR's changed files (one or more extra REST
+	// requests per PR) to tally them by status and extension.
+	IncludeFiles bool
+}
+
+// RunStats summarizes the outcome of a Run.
+type RunStats struct {
+	Owner     string `json:"owner"`
+	Repo      string `json:"repo"`
+	Total     int    `json:"total"`
+	Processed int64  `json:"processed"`
+	Inserted  int64  `json:"inserted"`
+	Filtered  int64  `json:"filtered"`
+	Errors    int64  `json:"errors"`
+	// ExcludedAuthors counts PRs skipped before processing because their
+	// author is on Options.ExcludeAuthors.
+	ExcludedAuthors int `json:"excluded_authors,omitempty"`
+	// ErrorsByClass splits Errors by cause (ErrClassRateLimit etc.).
+	ErrorsByClass map[string]int64 `json:"errors_by_class,omitempty"`
+	// Ownership counts PRs per CODEOWNERS owner with Options.Codeowners;
+	// PRs touching no owned file count under Unowned.
+	Ownership map[string]int `json:"ownership,omitempty"`
+	// BudgetExhausted is set when the request budget ran out during the
+	// run, so PRs are missing or, for the enumeration, entirely absent.
+	BudgetExhausted bool `json:"budget_exhausted,omitempty"`
+	// CommentAuthors counts comments per commenter with
+	// Options.CommentAuthors; CommentAuthorsTruncated is set when authors
+	// beyond Options.MaxCommentAuthors were dropped.
+	CommentAuthors          map[string]int `json:"comment_authors,omitempty"`
+	CommentAuthorsTruncated bool           `json:"comment_authors_truncated,omitempty"`
+	// EndCursor is the GraphQL cursor after the last enumerated PR, for
+	// Options.StartCursor; empty when REST enumeration was used.
+	EndCursor string `json:"end_cursor,omitempty"`
+	// Summary is set with Options.Summarize.
+	Summary *RepoSummary `json:"summary,omitempty"`
+	// BusFactor is set with Options.BusFactor.
+	BusFactor *BusFactor `json:"bus_factor,omitempty"`
+	// Diff is set with Options.DiffReport.
+	Diff *db.RunDiff `json:"diff,omitempty"`
+}
+
+// runIDLayout formats run IDs, which are the run's start time.
+const runIDLayout = "20060102T150405.000Z"
+
+// NewRunID returns a run ID for the current time; IDs sort chronologically.
+func NewRunID(now time.Time) string {
+	return now.UTC().Format(runIDLayout)
 }
 
 // Run orchestrates fetching PR numbers, concurrently retrieving details, building rows,
-// inserting into Postgres, and logging periodic progress.
-func Run(ctx context.Context, owner, repo string, concurrency int) error {
+// inserting into Postgres, and logging periodic progress. The returned stats
+// reflect whatever was processed, even when an error is returned.
+func Run(ctx context.Context, owner, repo string, opts Options) (RunStats, error) {
+	stats := RunStats{Owner: owner, Repo: repo}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// One "now" for the whole run keeps derived durations consistent.
+	now := time.Now()
+
+	concurrency := opts.Concurrency
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	opts.Comments.ByLogin = opts.CommentAuthors
+
+	// GitHub redirects renamed and transferred repos and GraphQL answers
+	// under the new identity, so rows are stored under it too; otherwise a
+	// re-scrape by the new name would duplicate them.
+	meta, err := services.GetRepoMetadata(ctx, owner, repo)
+	if err != nil {
+		log.Warn().Err(err).Str("owner", owner).Str("repo", repo).Msg("failed to fetch canonical repo name; using names as given")
+	} else if canonOwner, canonRepo, renamed := canonicalRepo(owner, repo, meta, opts.CanonicalRepoCase); canonOwner != owner || canonRepo != repo {
+		if renamed {
+			log.Warn().Str("owner", owner).Str("repo", repo).Str("canonical", meta.NameWithOwner).Msg("repository was renamed or transferred; storing rows under its current name")
+		} else {
+			log.Info().Str("owner", owner).Str("repo", repo).Str("canonical_owner", canonOwner).Str("canonical_repo", canonRepo).Msg("normalized repo casing")
+		}
+		owner, repo = canonOwner, canonRepo
+		stats.Owner, stats.Repo = owner, repo
+	}
+	if opts.MergedToDefault {
+		if err != nil {
+			return stats, fmt.Errorf("merged-to-default: resolving default branch: %w", err)
+		}
+		if meta.DefaultBranch == "" {
+			log.Info().Str("owner", owner).Str("repo", repo).Msg("repository has no default branch; nothing merged to it")
+			return stats, nil
+		}
+		opts.BaseRefs = []string{meta.DefaultBranch}
+		log.Info().Str("owner", owner).Str("repo", repo).Str("default_branch", meta.DefaultBranch).Msg("limiting to PRs merged to the default branch")
+	}
+	// Rows may be consolidated under another owner; API calls keep using
+	// the real one.
+	rowOwner := storedOwner(owner, opts.OwnerRenames)
+	if rowOwner != owner {
+		log.Info().Str("owner", owner).Str("repo", repo).Str("stored_owner", rowOwner).Msg("storing rows under renamed owner")
+	}
+	if err == nil && db.Pool != nil {
+		if rerr := db.UpsertRepo(ctx, db.RepoRow{Owner: rowOwner, Name: repo, License: meta.License, Topics: meta.Topics, TotalIssues: meta.TotalIssues, OpenIssues: meta.OpenIssues}); rerr != nil {
+			log.Warn().Err(rerr).Str("owner", owner).Str("repo", repo).Msg("failed to store repo metadata")
+		}
+	}
+
+	// The previous run must be read before this run's upserts replace it.
+	previousRunID := ""
+	if opts.DiffReport {
+		if previousRunID, err = db.LatestRunID(ctx, rowOwner, repo); err != nil {
+			return stats, fmt.Errorf("diff report: %w", err)
+		}
+	}
+
+	// Incremental runs stop at the newest stored PR; the PRs created at
+	// that instant are fetched again in case only some were stored.
+	var createdAfter time.Time
+	if opts.Incremental {
+		if createdAfter, err = db.LatestCreatedAt(ctx, rowOwner, repo); err != nil {
+			return stats, fmt.Errorf("incremental: %w", err)
+		}
+		if createdAfter.IsZero() {
+			log.Info().Str("owner", owner).Str("repo", repo).Msg("no stored PRs; scraping all PRs")
+		} else {
+			log.Info().Str("owner", owner).Str("repo", repo).Time("created_after", createdAfter).Msg("scraping only PRs newer than the stored ones")
+		}
+	}
+	if opts.Since.After(createdAfter) {
+		createdAfter = opts.Since
+	}
+
+	setPhase := func(phase string) {
+		if opts.Progress != nil {
+			opts.Progress.setPhase(phase)
+		}
+	}
 
 	// Fetch PR minimal details via GraphQL in bulk
-	lites, err := services.GetAllPRsGraphQL(ctx, owner, repo)
+	setPhase(PhaseEnumerating)
+	enumerate := func() ([]services.PRLite, string, error) {
+		return services.GetAllPRsGraphQL(ctx, owner, repo, services.EnumerateOptions{IncludeBody: opts.IncludeBody, IncludeChecks: opts.IncludeChecks, IncludeCommits: opts.IncludeCommits, IncludeDeployments: opts.IncludeDeployments, IncludeReviewers: opts.IncludeReviewers, IncludeTimeline: opts.IncludeTimeline, IncludeReviewThreads: opts.IncludeReviewThreads, IncludeReviewCounts: opts.IncludeReviewCounts, IncludeAutoMerge: opts.IncludeAutoMerge, SincePRNumber: opts.SincePRNumber, CreatedAfter: createdAfter, StartCursor: opts.StartCursor})
+	}
+	lites, endCursor, err := enumerate()
+	// A cursor or bound can legitimately leave nothing to enumerate.
+	if err == nil && len(lites) == 0 && opts.RetryOnEmpty && opts.StartCursor == "" && opts.SincePRNumber <= 0 && createdAfter.IsZero() {
+		count := func() (int, error) { return services.CountPRs(ctx, owner, repo) }
+		lites, endCursor, err = retryEmptyEnumeration(ctx, owner, repo, count, enumerate)
+	}
+	stats.EndCursor = endCursor
+	if errors.Is(err, services.ErrBudgetExhausted) {
+		log.Warn().Str("owner", owner).Str("repo", repo).Int64("requests", services.APIRequests()).Msg("request budget exhausted before PRs were enumerated; skipping repo")
+		stats.BudgetExhausted = true
+		return stats, nil
+	}
+	restFallback := false
 	if err != nil {
-		return err
+		if !services.IsGraphQLUnavailable(err) {
+			return stats, err
+		}
+		// Some proxied/Enterprise setups block GraphQL while REST works.
+		log.Warn().Err(err).Str("owner", owner).Str("repo", repo).Msg("GraphQL API unavailable; falling back to REST enumeration and per-PR detail fetches")
+		prs, rerr := services.GetAllPRs(ctx, owner, repo, opts.RESTPages)
+		if rerr != nil {
+			return stats, rerr
+		}
+		lites = services.LitesFromREST(prs)
+		if !createdAfter.IsZero() {
+			lites = slices.DeleteFunc(lites, func(l services.PRLite) bool { return l.CreatedAt.Before(createdAfter) })
+		}
+		restFallback = true
+	}
+
+	checkpoint := opts.Checkpoint && db.Pool != nil && opts.Limit <= 0
+	if checkpoint && !opts.IgnoreCheckpoint {
+		cp, cerr := db.LoadCheckpoint(ctx, rowOwner, repo)
+		if cerr != nil {
+			return stats, fmt.Errorf("loading checkpoint: %w", cerr)
+		}
+		// A checkpoint at #1 resumes nothing, so the run starts over.
+		if cp > 0 && (opts.ResumeFromNumber <= 0 || cp-1 < opts.ResumeFromNumber) {
+			log.Info().Str("owner", owner).Str("repo", repo).Int("checkpoint", cp).Msg("resuming below checkpoint of an interrupted run; pass -force to start over")
+			opts.ResumeFromNumber = cp - 1
+		}
+	}
+
+	lites, skipped, excludedAuthors := filterLites(lites, opts, !restFallback)
+	stats.ExcludedAuthors = excludedAuthors
+	if skipped > 0 {
+		log.Info().Str("owner", owner).Str("repo", repo).Int("skipped", skipped).Int("excluded_authors", excludedAuthors).Int("kept", len(lites)).Msg("skipped PRs excluded by filters")
+	}
+	if opts.Limit > 0 && len(lites) > opts.Limit {
+		lites = lites[:opts.Limit]
 	}
 
 	jobNumbers := make([]int, 0, len(lites))
-	liteMap := make(map[int]services.PRLite, len(lites))
 	for _, pr := range lites {
 		jobNumbers = append(jobNumbers, pr.Number)
-		liteMap[pr.Number] = pr
 	}
 	total := len(jobNumbers)
+	stats.Total = total
 	log.Info().Str("owner", owner).Str("repo", repo).Int("total_prs", total).Msg("ready to process PRs")
 	if total == 0 {
-		return nil
+		if checkpoint {
+			// Nothing was left below the checkpoint.
+			if cerr := db.ClearCheckpoint(ctx, rowOwner, repo); cerr != nil {
+				log.Warn().Err(cerr).Str("owner", owner).Str("repo", repo).Msg("failed to clear checkpoint")
+			}
+		}
+		if opts.Summarize {
+			stats.Summary = &RepoSummary{Owner: owner, Repo: repo}
+		}
+		return stats, nil
+	}
+
+	var cp *checkpointer
+	if checkpoint {
+		cp = newCheckpointer(rowOwner, repo, opts.RunID, jobNumbers)
 	}
 
 	jobs := make(chan job)
 	results := make(chan result)
-	var processed atomic.Int64
-	var inserted atomic.Int64
-	var errs atomic.Int64
+	// progress counts this run alone; opts.Progress may be shared.
+	progress := &Progress{}
+	progress.addTotal(total)
+	if opts.Progress != nil {
+		opts.Progress.addTotal(total)
+	}
+	record := func(res result) {
+		progress.record(res)
+		if opts.Progress != nil {
+			opts.Progress.record(res)
+		}
+	}
+	// A buffered sink only accepts rows in Write; settle flushes the repo's
+	// rows and counts them as inserted, or as errors if they failed.
+	var buffered sinks.Buffered
+	var accepted int64
+	settle := func(ctx context.Context) {
+		if buffered == nil {
+			return
+		}
+		failed := buffered.Flush(ctx, rowOwner, repo)
+		for _, f := range failed {
+			log.Error().Int("number", f.Number).Str("error_class", ErrClassDB).Err(f.Err).Msg("failed to store PR")
+		}
+		if len(failed) > 0 {
+			if stats.ErrorsByClass == nil {
+				stats.ErrorsByClass = make(map[string]int64)
+			}
+			stats.ErrorsByClass[ErrClassDB] += int64(len(failed))
+		}
+		stored := accepted - int64(len(failed))
+		progress.settle(stored, int64(len(failed)))
+		if opts.Progress != nil {
+			opts.Progress.settle(stored, int64(len(failed)))
+		}
+		accepted = 0
+	}
+	setStats := func() {
+		s := progress.Snapshot()
+		stats.Processed, stats.Inserted, stats.Filtered, stats.Errors = s.Processed, s.Inserted, s.Filtered, s.Errors
+	}
+	// Comment totals over all built rows, for the bot-ratio check.
+	var totalComments, botComments int
+	var summary *summaryCollector
+	if opts.Summarize {
+		summary = &summaryCollector{}
+	}
+	// busRows keeps just the fields BusFactorOf reads, not whole rows.
+	var busRows []types.PRRow
 
 	// Preload repo-level comments breakdown to reduce API calls
-	prSet := make(map[int]struct{}, len(jobNumbers))
-	for _, n := range jobNumbers {
-		prSet[n] = struct{}{}
+	prRefs := make(map[int]services.PRRef, len(lites))
+	for _, pr := range lites {
+		prRefs[pr.Number] = services.PRRef{Author: pr.Author, CreatedAt: pr.CreatedAt, UpdatedAt: pr.UpdatedAt}
 	}
-	log.Info().Str("owner", owner).Str("repo", repo).Int("total", total).Msg("preloading repo-level comment breakdowns")
-	repoBreakdowns, err := services.GetRepoCommentsBreakdown(ctx, owner, repo, prSet)
+	// The preload scans every comment in the repo, which only pays off
+	// when most PRs are processed.
+	var repoBreakdowns map[int]services.CommentsBreakdown
+	if opts.Limit <= 0 {
+		log.Info().Str("owner", owner).Str("repo", repo).Int("total", total).Msg("preloading repo-level comment breakdowns")
+		setPhase(PhaseScanningComments)
+		if opts.IncrementalComments {
+			repoBreakdowns, err = incrementalBreakdowns(ctx, owner, rowOwner, repo, prRefs, opts.Comments, opts.BotBreakdown)
+		} else {
+			repoBreakdowns, err = services.GetRepoCommentsBreakdown(ctx, owner, repo, prRefs, opts.Comments)
+		}
+		// A truncated scan is complete for PRs untouched since its cutoff;
+		// the rest are counted per PR.
+		var truncated *services.CommentsTruncatedError
+		if errors.As(err, &truncated) {
+			dropped := dropUpdatedSince(repoBreakdowns, prRefs, truncated.Cutoff)
+			log.Warn().Err(err).Time("cutoff", truncated.Cutoff).Int("per_pr", dropped).Msg("repo-level comment scan truncated; counting PRs updated since the cutoff per PR")
+			err = nil
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to preload repo-level comment breakdowns; falling back to per-PR calls")
+		} else {
+			log.Info().Str("owner", owner).Str("repo", repo).Int("covered", len(repoBreakdowns)).Int("total", total).Msg("repo-level comment breakdowns loaded")
+		}
+	}
+
+	var protection map[string]services.BranchProtection
+	if opts.IncludeProtection {
+		if protection, err = loadProtection(ctx, owner, repo, lites); err != nil {
+			return stats, err
+		}
+	}
+
+	var codeowners Codeowners
+	if opts.Codeowners {
+		text, cerr := services.GetCodeowners(ctx, owner, repo)
+		if cerr != nil {
+			return stats, fmt.Errorf("CODEOWNERS: %w", cerr)
+		}
+		if text == "" {
+			log.Info().Str("owner", owner).Str("repo", repo).Msg("repo has no CODEOWNERS; every PR counts as unowned")
+		}
+		codeowners = ParseCodeowners(text)
+		stats.Ownership = make(map[string]int)
+	}
+
+	// Workers look PRs up in the store from here on, so lites can go.
+	store, err := newLiteStore(lites, opts.SpillThreshold)
 	if err != nil {
-		log.Warn().Err(err).Msg("failed to preload repo-level comment breakdowns; falling back to per-PR calls")
-	} else {
-		log.Info().Str("owner", owner).Str("repo", repo).Int("covered", len(repoBreakdowns)).Int("total", total).Msg("repo-level comment breakdowns loaded")
+		return stats, fmt.Errorf("storing enumerated PRs: %w", err)
+	}
+	defer func() {
+		if cerr := store.Close(); cerr != nil {
+			log.Warn().Err(cerr).Msg("failed to remove PR spill file")
+		}
+	}()
+	if store.spilled() {
+		log.Info().Str("owner", owner).Str("repo", repo).Int("prs", len(lites)).Str("path", store.f.Name()).Msg("spilled enumerated PRs to disk")
+	}
+	lites = nil
+
+	var tally *authorTally
+	if opts.CommentAuthors {
+		tally = newAuthorTally(opts.MaxCommentAuthors)
+	}
+
+	sink := opts.Sink
+	if sink == nil && db.Pool != nil {
+		sink = sinks.Postgres{}
+	}
+	buffered, _ = sink.(sinks.Buffered)
+
+	jobsRun := &repoJobs{
+		owner: owner, rowOwner: rowOwner, repo: repo,
+		opts:         opts,
+		now:          now,
+		restFallback: restFallback,
+		store:        store,
+		breakdowns:   repoBreakdowns,
+		prRefs:       prRefs,
+		protection:   protection,
+		codeowners:   codeowners,
+		tally:        tally,
+		sink:         sink,
+		buffered:     buffered,
+	}
+
+	// With adaptive concurrency all workers are started, but only as many as
+	// the semaphore allows process a job at once. In a batch the semaphore
+	// and its controller belong to RunBatch.
+	var sem *semaphore
+	ownSem := false
+	switch {
+	case opts.AdaptiveConcurrency && opts.sharedWorkers != nil:
+		sem = opts.sharedWorkers
+	case opts.AdaptiveConcurrency:
+		sem, ownSem = newSemaphore(concurrency), true
 	}
 
-	// Workers
+	setPhase(PhaseProcessing)
+	// Workers stop once the context is cancelled, even mid-send, so an
+	// early return from the consumer below never strands them.
+	var workers sync.WaitGroup
 	for w := 0; w < concurrency; w++ {
+		workers.Add(1)
 		go func() {
+			defer workers.Done()
 			for j := range jobs {
-				// We no longer need full PR REST call; using lite + comments
-
-				// Get breakdown from preloaded map if available, else compute per-PR
-				breakdown, ok := repoBreakdowns[j.number]
-				if !ok {
-					var berr error
-					breakdown, berr = services.GetPRCommentsBreakdown(ctx, owner, repo, j.number)
-					if berr != nil {
-						results <- result{number: j.number, err: berr}
-						continue
+				if sem != nil {
+					if err := sem.Acquire(ctx); err != nil {
+						return
 					}
 				}
-
-				// Build row using GraphQL lites for lines changed & createdAt
-				lite := liteMap[j.number]
-				createdAt := lite.CreatedAt
-				additions := lite.Additions
-				deletions := lite.Deletions
-				linesChanged := additions + deletions
-
-				row := types.PRRow{
-					ID:           j.number,
-					Repo:         repo,
-					Owner:        owner,
-					CommentCount: breakdown.TotalComments,
-					BotComments:  breakdown.BotComments,
-					LinesChanged: linesChanged,
-					Status:       strings.ToLower(lite.State),
-					CreatedAt:    createdAt,
+				res := jobsRun.process(ctx, j)
+				if sem != nil {
+					sem.Release()
 				}
-
-				ins := false
-				if db.Pool != nil {
-					if err := db.InsertPRRow(ctx, row); err != nil {
-						results <- result{number: j.number, err: err}
-						continue
-					}
-					ins = true
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return
 				}
-
-				results <- result{number: j.number, row: row, inserted: ins}
 			}
 		}()
 	}
@@ -129,9 +596,12 @@ func Run(ctx context.Context, owner, repo string, concurrency int) error {
 	}()
 
 	done := make(chan struct{})
+	if ownSem {
+		go runAdaptiveController(ctx, done, sem, concurrency, 2*time.Second)
+	}
 	// Periodic progress logger
-	go func(totalJobs int) {
-		ticker := time.NewTicker(5 * time.Second)
+	go func() {
+		ticker := time.NewTicker(ProgressInterval)
 		defer ticker.Stop()
 		for {
 			select {
@@ -140,60 +610,229 @@ func Run(ctx context.Context, owner, repo string, concurrency int) error {
 			case <-done:
 				return
 			case <-ticker.C:
-				p := processed.Load()
-				i := inserted.Load()
-				e := errs.Load()
-				remaining := int64(totalJobs) - p - e
-				if remaining < 0 {
-					remaining = 0
-				}
+				s := progress.Snapshot()
 				log.Info().
 					Str("owner", owner).
 					Str("repo", repo).
-					Int("total", totalJobs).
-					Int64("processed", p).
-					Int64("inserted", i).
-					Int64("errors", e).
-					Int64("remaining", remaining).
+					Int64("total", s.Total).
+					Int64("processed", s.Processed).
+					Int64("inserted", s.Inserted).
+					Int64("filtered", s.Filtered).
+					Int64("errors", s.Errors).
+					Int64("remaining", s.Remaining()).
 					Msg("PR processing progress")
 			}
 		}
-	}(total)
+	}()
 
 	// Consume results
 	for i := 0; i < total; i++ {
 		select {
 		case <-ctx.Done():
 			close(done)
-			return ctx.Err()
+			settle(context.WithoutCancel(ctx))
+			setStats()
+			if cp != nil {
+				cp.flush(context.WithoutCancel(ctx))
+			}
+			return stats, ctx.Err()
 		case res := <-results:
+			record(res)
+			if res.accepted {
+				accepted++
+			}
+			if cp != nil && res.err == nil {
+				cp.finish(ctx, res.number)
+			}
 			if res.err != nil {
-				errs.Add(1)
-				log.Error().Int("number", res.number).Err(res.err).Msg("failed to process PR")
+				class := classifyError(res.err)
+				if stats.ErrorsByClass == nil {
+					stats.ErrorsByClass = make(map[string]int64)
+				}
+				stats.ErrorsByClass[class]++
+				// Once the budget is gone, every PR still needing a request
+				// fails at once without cost; the rest are still stored.
+				if class == ErrClassBudget {
+					if !stats.BudgetExhausted {
+						log.Warn().Str("owner", owner).Str("repo", repo).Int64("requests", services.APIRequests()).Msg("request budget exhausted; storing only PRs that need no further requests")
+					}
+					stats.BudgetExhausted = true
+					continue
+				}
+				log.Error().Int("number", res.number).Str("error_class", class).Err(res.err).Msg("failed to process PR")
+				if opts.FailFast {
+					// Stop dispatching; in-flight PRs abort (upserts are
+					// atomic, so nothing is half-written) before returning.
+					cancel()
+					workers.Wait()
+					close(done)
+					settle(context.WithoutCancel(ctx))
+					setStats()
+					if cp != nil {
+						cp.flush(context.WithoutCancel(ctx))
+					}
+					return stats, fmt.Errorf("fail-fast: PR #%d: %w", res.number, res.err)
+				}
 				continue
 			}
-			processed.Add(1)
-			if res.inserted {
-				inserted.Add(1)
+			totalComments += res.row.CommentCount
+			botComments += res.row.BotComments
+			if summary != nil {
+				summary.add(res.row.CommentCount, res.row.BotComments, res.row.Status, res.row.OpenDuration)
+			}
+			if !res.filtered {
+				for _, o := range res.owners {
+					stats.Ownership[o]++
+				}
+			}
+			if opts.BusFactor && res.row.Status == "merged" {
+				busRows = append(busRows, types.PRRow{Author: res.row.Author, Status: res.row.Status})
 			}
 		}
 	}
 
 	close(done)
+	settle(ctx)
+	setStats()
+	// PRs skipped for the budget still need a run, so their checkpoint
+	// stays.
+	if cp != nil {
+		if stats.BudgetExhausted {
+			cp.flush(ctx)
+		} else {
+			cp.clear(ctx)
+		}
+	}
 
 	log.Info().
 		Str("owner", owner).
 		Str("repo", repo).
 		Int("total", total).
-		Int64("processed", processed.Load()).
-		Int64("inserted", inserted.Load()).
-		Int64("errors", errs.Load()).
+		Int64("processed", stats.Processed).
+		Int64("inserted", stats.Inserted).
+		Int64("filtered", stats.Filtered).
+		Int64("errors", stats.Errors).
+		Int("excluded_authors", stats.ExcludedAuthors).
+		Interface("errors_by_class", stats.ErrorsByClass).
 		Msg("completed PR processing")
 
-	return nil
+	if summary != nil {
+		s := summary.summary(owner, repo)
+		stats.Summary = &s
+	}
+
+	if opts.BusFactor {
+		bf := BusFactorOf(busRows)
+		stats.BusFactor = &bf
+		log.Info().Str("owner", owner).Str("repo", repo).Int("merged_prs", bf.MergedPRs).Str("top_author", bf.TopAuthor).Float64("top_share", bf.TopShare).Int("authors_for_80", bf.AuthorsFor80).Msg("bus factor")
+	}
+
+	if tally != nil {
+		stats.CommentAuthors, stats.CommentAuthorsTruncated = tally.snapshot()
+		log.Info().Str("owner", owner).Str("repo", repo).Int("authors", len(stats.CommentAuthors)).Strs("top", topAuthors(stats.CommentAuthors, 10)).Bool("truncated", stats.CommentAuthorsTruncated).Msg("comment authors aggregated")
+	}
+
+	if opts.DiffReport {
+		diff, err := db.DiffSinceRun(ctx, rowOwner, repo, previousRunID)
+		if err != nil {
+			return stats, fmt.Errorf("diff report: %w", err)
+		}
+		stats.Diff = &diff
+		log.Info().Str("owner", owner).Str("repo", repo).Str("previous_run_id", previousRunID).Int("new", len(diff.New)).Int("state_changed", len(diff.StateChanged)).Int("new_comments", len(diff.NewComments)).Msg("changes since previous run")
+	}
+
+	if ratio, high := botRatioExceeded(botComments, totalComments, opts.BotRatioThreshold); high {
+		// Usually a misconfigured -bot-logins or a runaway bot.
+		log.Warn().Str("owner", owner).Str("repo", repo).Int("bot_comments", botComments).Int("comments", totalComments).Float64("bot_ratio", ratio).Float64("threshold", opts.BotRatioThreshold).Msg("bot comment ratio is suspiciously high")
+		if opts.Strict {
+			return stats, fmt.Errorf("strict: %s/%s bot comment ratio %.2f exceeds %.2f", owner, repo, ratio, opts.BotRatioThreshold)
+		}
+	}
+
+	return stats, nil
+}
+
+// botRatioExceeded returns the share of bot comments and whether it is above
+// threshold. A zero threshold or no comments never exceeds.
+func botRatioExceeded(bot, total int, threshold float64) (float64, bool) {
+	if threshold <= 0 || total == 0 {
+		return 0, false
+	}
+	ratio := float64(bot) / float64(total)
+	return ratio, ratio > threshold
 }
 
-func buildPRRow(full *github.PullRequest, owner, repo string, number int, commentCount int, botComments int) types.PRRow {
+// canonicalRepo returns the identity rows for owner/repo are stored under:
+// GitHub's current name when the repo was renamed or transferred, and with
+// matchCase also GitHub's casing of an otherwise identical name.
+func canonicalRepo(owner, repo string, meta services.RepoMetadata, matchCase bool) (string, string, bool) {
+	if meta.Owner == "" || meta.Name == "" {
+		return owner, repo, false
+	}
+	if !strings.EqualFold(meta.Owner, owner) || !strings.EqualFold(meta.Name, repo) {
+		return meta.Owner, meta.Name, true
+	}
+	if matchCase {
+		return meta.Owner, meta.Name, false
+	}
+	return owner, repo, false
+}
+
+// buildLiteRow builds a row from GraphQL enumeration data. In strict mode
+// missing values that would otherwise be defaulted are reported as errors.
+func buildLiteRow(lite services.PRLite, owner, repo string, breakdown services.CommentsBreakdown, now time.Time, strict bool) (types.PRRow, error) {
+	if strict {
+		if err := checkRequired(lite.Number, lite.Author, lite.CreatedAt, lite.State); err != nil {
+			return types.PRRow{}, err
+		}
+	}
+
+	return types.PRRow{
+		ID:                 lite.Number,
+		NodeID:             lite.NodeID,
+		Repo:               repo,
+		Owner:              owner,
+		Author:             lite.Author,
+		CommentCount:       breakdown.TotalComments,
+		IssueComments:      breakdown.IssueComments,
+		ReviewComments:     breakdown.ReviewComments,
+		GitHubCommentCount: lite.TotalCommentsCount,
+		BotComments:        breakdown.BotComments,
+		AuthorComments:     breakdown.AuthorComments,
+		CommentsFirstDay:   breakdown.FirstDayComments,
+		CommentsFirstWeek:  breakdown.FirstWeekComments,
+		CommentsTruncated:  breakdown.Truncated,
+		CommentSentiment:   breakdown.Sentiment(),
+		LinesChanged:       lite.Additions + lite.Deletions,
+		Additions:          lite.Additions,
+		Deletions:          lite.Deletions,
+		StatsTruncated:     statsTruncated(lite.ChangedFiles),
+		Status:             strings.ToLower(lite.State),
+		State:              strings.ToLower(lite.State),
+		CreatedAt:          lite.CreatedAt,
+		ClosedAt:           lite.ClosedAt,
+		MergedAt:           lite.MergedAt,
+		OpenDuration:       openDurationDays(lite.CreatedAt, lite.ClosedAt, now),
+		MergeCommitSHA:     lite.MergeCommitSHA,
+		BaseRef:            lite.BaseRef,
+		Mergeable:          mergeableState(lite.Mergeable),
+		BaseSHA:            lite.BaseSHA,
+		HeadSHA:            lite.HeadSHA,
+		Checks:             lite.Checks,
+		Labels:             lite.Labels,
+		AutoMerged:         lite.AutoMerged,
+		AutoMergeEnabledBy: lite.AutoMergeEnabledBy,
+	}, nil
+}
+
+// buildPRRow builds a row from a full REST PR, used when GraphQL is
+// unavailable. In strict mode missing values are reported as errors.
+func buildPRRow(full *github.PullRequest, owner, repo string, number int, breakdown services.CommentsBreakdown, now time.Time, strict bool) (types.PRRow, error) {
+	if strict {
+		if err := checkRequired(number, full.GetUser().GetLogin(), full.GetCreatedAt().Time, full.GetState()); err != nil {
+			return types.PRRow{}, err
+		}
+	}
 
 	additions := 0
 	if full.Additions != nil {
@@ -210,13 +849,140 @@ func buildPRRow(full *github.PullRequest, owner, repo string, number int, commen
 		createdAt = full.CreatedAt.Time
 	}
 
-	return types.PRRow{
-		ID:           number,
-		Repo:         repo,
-		Owner:        owner,
-		CommentCount: commentCount,
-		BotComments:  botComments,
-		LinesChanged: linesChanged,
-		CreatedAt:    createdAt,
+	// REST reports a test-merge SHA for open PRs; only keep it once merged.
+	mergeCommitSHA := ""
+	status := full.GetState()
+	if full.GetMerged() {
+		mergeCommitSHA = full.GetMergeCommitSHA()
+		status = "merged"
+	}
+
+	row := types.PRRow{
+		ID:                number,
+		NodeID:            full.GetNodeID(),
+		Repo:              repo,
+		Owner:             owner,
+		Author:            full.GetUser().GetLogin(),
+		CommentCount:      breakdown.TotalComments,
+		IssueComments:     breakdown.IssueComments,
+		ReviewComments:    breakdown.ReviewComments,
+		BotComments:       breakdown.BotComments,
+		AuthorComments:    breakdown.AuthorComments,
+		CommentsFirstDay:  breakdown.FirstDayComments,
+		CommentsFirstWeek: breakdown.FirstWeekComments,
+		CommentsTruncated: breakdown.Truncated,
+		CommentSentiment:  breakdown.Sentiment(),
+		LinesChanged:      linesChanged,
+		Additions:         additions,
+		Deletions:         deletions,
+		StatsTruncated:    statsTruncated(full.GetChangedFiles()),
+		Status:            status,
+		State:             status,
+		CreatedAt:         createdAt,
+		ClosedAt:          full.ClosedAt.GetTime(),
+		MergedAt:          full.MergedAt.GetTime(),
+		OpenDuration:      openDurationDays(createdAt, full.ClosedAt.GetTime(), now),
+		MergeCommitSHA:    mergeCommitSHA,
+		BaseRef:           full.GetBase().GetRef(),
+		Mergeable:         restMergeable(full.Mergeable),
+		BaseSHA:           full.GetBase().GetSHA(),
+		HeadSHA:           full.GetHead().GetSHA(),
+		Labels:            services.LabelNames(full.Labels),
+	}
+	return row, nil
+}
+
+// setReviewCounts stores review counts by state on row.
+func setReviewCounts(row *types.PRRow, c services.ReviewCounts) {
+	row.ApprovedReviews = &c.Approved
+	row.ChangesRequestedReviews = &c.ChangesRequested
+	row.CommentedReviews = &c.Commented
+}
+
+// Mergeability values, as GitHub's GraphQL MergeableState enum.
+const (
+	MergeableYes         = "MERGEABLE"
+	MergeableConflicting = "CONFLICTING"
+	MergeableUnknown     = "UNKNOWN"
+)
+
+// mergeableState normalizes a GraphQL mergeable value. GitHub computes
+// mergeability in the background, so it is often UNKNOWN on new PRs;
+// anything unreported is UNKNOWN too.
+func mergeableState(s string) string {
+	if s == "" {
+		return MergeableUnknown
+	}
+	return s
+}
+
+// restMergeable maps REST's mergeable flag, null while GitHub is still
+// computing it, to the GraphQL values.
+func restMergeable(m *bool) string {
+	switch {
+	case m == nil:
+		return MergeableUnknown
+	case *m:
+		return MergeableYes
+	default:
+		return MergeableConflicting
+	}
+}
+
+// checkRequired reports fields that GitHub returned empty or null but that
+// every PR should have.
+func checkRequired(number int, author string, createdAt time.Time, state string) error {
+	var missing []string
+	if author == "" {
+		missing = append(missing, "author")
+	}
+	if createdAt.IsZero() {
+		missing = append(missing, "created_at")
+	}
+	if state == "" {
+		missing = append(missing, "state")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("strict: PR #%d has null/empty %s", number, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// openDurationDays returns how long a PR was open, in days: until closedAt
+// (merged PRs are closed too) or until now for PRs that are still open.
+func openDurationDays(createdAt time.Time, closedAt *time.Time, now time.Time) float64 {
+	end := now
+	if closedAt != nil {
+		end = *closedAt
+	}
+	d := end.Sub(createdAt)
+	if d < 0 {
+		return 0
+	}
+	return d.Hours() / 24
+}
+
+// commentDivergence returns how far the computed comment count is from
+// GitHub's, and whether that exceeds threshold. Rows without a GitHub count
+// never diverge.
+func commentDivergence(row types.PRRow, threshold int) (int, bool) {
+	if row.GitHubCommentCount == nil {
+		return 0, false
+	}
+	diff := row.CommentCount - *row.GitHubCommentCount
+	if diff < 0 {
+		diff = -diff
 	}
+	return diff, diff > threshold
+}
+
+// maxDiffFiles is the number of files beyond which GitHub stops computing a
+// PR's diff: file listings stop at 3000 entries and additions/deletions only
+// cover the part of the diff GitHub rendered, understating lines_changed.
+const maxDiffFiles = 3000
+
+// statsTruncated reports whether a PR is large enough that GitHub's diff
+// stats for it can't be trusted.
+func statsTruncated(changedFiles int) bool {
+	return changedFiles >= maxDiffFiles
 }
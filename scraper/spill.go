@@ -0,0 +1,109 @@
+package scraper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dickeyy/github-scraper/services"
+)
+
+// liteStore holds the enumerated PRs that workers look up by number. Small
+// sets stay in memory; larger ones are spilled to a temporary file of JSON
+// lines, keeping only each PR's byte range in memory.
+type liteStore struct {
+	mem map[int]services.PRLite
+
+	f     *os.File
+	spans map[int]spillSpan
+	// path is the spill file's name while it still needs removing.
+	path string
+}
+
+type spillSpan struct {
+	off int64
+	n   int
+}
+
+// newLiteStore stores lites, spilling them to disk when spillAbove is
+// positive and there are more than that many.
+func newLiteStore(lites []services.PRLite, spillAbove int) (*liteStore, error) {
+	if spillAbove <= 0 || len(lites) <= spillAbove {
+		mem := make(map[int]services.PRLite, len(lites))
+		for _, l := range lites {
+			mem[l.Number] = l
+		}
+		return &liteStore{mem: mem}, nil
+	}
+
+	f, err := os.CreateTemp("", "github-scraper-lites-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	s := &liteStore{f: f, spans: make(map[int]spillSpan, len(lites))}
+	// Unlinked right away, the file goes with the process however it
+	// ends. Systems that cannot remove open files get it removed by Close.
+	if os.Remove(f.Name()) != nil {
+		s.path = f.Name()
+	}
+	w := bufio.NewWriter(f)
+	var off int64
+	for _, l := range lites {
+		line, err := json.Marshal(l)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		line = append(line, '\n')
+		if _, err := w.Write(line); err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.spans[l.Number] = spillSpan{off: off, n: len(line)}
+		off += int64(len(line))
+	}
+	if err := w.Flush(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// spilled reports whether the store is backed by a file.
+func (s *liteStore) spilled() bool { return s.f != nil }
+
+// get returns the PR numbered n. It is safe for concurrent use.
+func (s *liteStore) get(n int) (services.PRLite, error) {
+	if !s.spilled() {
+		return s.mem[n], nil
+	}
+	span, ok := s.spans[n]
+	if !ok {
+		return services.PRLite{}, fmt.Errorf("PR %d not in spill file", n)
+	}
+	buf := make([]byte, span.n)
+	if _, err := s.f.ReadAt(buf, span.off); err != nil {
+		return services.PRLite{}, fmt.Errorf("reading PR %d from spill file: %w", n, err)
+	}
+	var l services.PRLite
+	if err := json.Unmarshal(buf, &l); err != nil {
+		return services.PRLite{}, fmt.Errorf("decoding PR %d from spill file: %w", n, err)
+	}
+	return l, nil
+}
+
+// Close closes the spill file, if any, and removes it if it could not be
+// removed when created.
+func (s *liteStore) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	cerr := s.f.Close()
+	if s.path != "" {
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+	}
+	return cerr
+}
@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/services"
+)
+
+func TestLiteStoreSpillRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	merged := created.Add(time.Hour)
+	count := 3
+	lites := []services.PRLite{
+		{Number: 1, NodeID: "PR_a", State: "MERGED", CreatedAt: created, MergedAt: &merged, TotalCommentsCount: &count, Author: "alice", Labels: []string{"bug"}},
+		{Number: 2, NodeID: "PR_b", State: "OPEN", CreatedAt: created, Title: "Fix the thing", Body: "multi\nline\n"},
+		{Number: 5, NodeID: "PR_c", State: "CLOSED", CreatedAt: created, Labels: []string{}},
+	}
+
+	s, err := newLiteStore(lites, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.spilled() {
+		t.Fatal("3 PRs over a threshold of 2 were not spilled")
+	}
+	if _, err := os.Stat(s.f.Name()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("spill file %s still has a name while open: %v", s.f.Name(), err)
+	}
+	// Read back out of order, as workers do.
+	for _, i := range []int{2, 0, 1} {
+		got, err := s.get(lites[i].Number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, lites[i]) {
+			t.Errorf("get(%d) = %+v, want %+v", lites[i].Number, got, lites[i])
+		}
+	}
+	if _, err := s.get(3); err == nil {
+		t.Error("get of a PR never stored succeeded")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+
+	mem, err := newLiteStore(lites, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	if mem.spilled() {
+		t.Error("3 PRs at a threshold of 3 were spilled")
+	}
+	if got, _ := mem.get(2); !reflect.DeepEqual(got, lites[1]) {
+		t.Errorf("in-memory get(2) = %+v, want %+v", got, lites[1])
+	}
+}
@@ -0,0 +1,77 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchState records which repos of a batch completed, so a restarted
+// batch can skip them. It is safe for concurrent use.
+type batchState struct {
+	path string
+
+	mu   sync.Mutex
+	file batchStateFile
+}
+
+type batchStateFile struct {
+	Completed []string  `json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// loadBatchState reads the state at path; a missing file is an empty
+// state.
+func loadBatchState(path string) (*batchState, error) {
+	s := &batchState{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.file); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// done reports whether r completed in an earlier run. Repos compare
+// case-insensitively, like GitHub names.
+func (s *batchState) done(r RepoRef) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.file.Completed {
+		if strings.EqualFold(c, r.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records r and rewrites the state file atomically.
+func (s *batchState) complete(r RepoRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Completed = append(s.file.Completed, r.String())
+	s.file.UpdatedAt = time.Now().UTC()
+	b, err := json.MarshalIndent(s.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, append(b, '\n'))
+}
+
+// clear removes the state file once the whole batch has succeeded, so the
+// next run starts over.
+func (s *batchState) clear() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
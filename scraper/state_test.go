@@ -0,0 +1,57 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatchStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := loadBatchState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.done(RepoRef{Owner: "octo", Repo: "one"}) {
+		t.Fatal("a missing state file has completed repos")
+	}
+	if err := s.complete(RepoRef{Owner: "octo", Repo: "one"}); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := loadBatchState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.done(RepoRef{Owner: "Octo", Repo: "ONE"}) {
+		t.Error("completed repo not recognized after reload")
+	}
+	if again.done(RepoRef{Owner: "octo", Repo: "two"}) {
+		t.Error("repo never completed counts as done")
+	}
+}
+
+func TestRunBatchSkipsCompletedRepos(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	seed := `{"completed": ["octo/one", "octo/two"], "updated_at": "2024-01-01T00:00:00Z"}`
+	if err := os.WriteFile(path, []byte(seed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// No GitHub client is set up, so running any repo would fail.
+	repos := []RepoRef{{Owner: "octo", Repo: "one"}, {Owner: "OCTO", Repo: "Two"}}
+	stats, err := RunBatch(context.Background(), repos, BatchOptions{StateFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range stats {
+		if s.Owner != repos[i].Owner || s.Repo != repos[i].Repo || s.Total != 0 || s.Processed != 0 {
+			t.Errorf("stats[%d] = %+v, want an empty entry for the skipped repo", i, s)
+		}
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("state file still exists after a fully completed batch: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestAutoMergeFromGraphQL(t *testing.T) {
+	node := func(number int, state, autoMergeRequest string, enabled, disabled int) string {
+		return fmt.Sprintf(`{"number": %d, "state": %q, "createdAt": "2024-01-01T00:00:00Z", "updatedAt": "2024-01-02T00:00:00Z",
+			"labels": {"nodes": []}, "autoMergeRequest": %s,
+			"autoMergeEnabled": {"totalCount": %d}, "autoMergeDisabled": {"totalCount": %d}}`, number, state, autoMergeRequest, enabled, disabled)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data": {"rateLimit": {"cost": 1, "remaining": 4999}, "repository": {"pullRequests": {"totalCount": 3,
+			"pageInfo": {"hasNextPage": false, "endCursor": null}, "nodes": [%s, %s, %s]}}}}`,
+			node(3, "OPEN", `{"enabledBy": {"login": "alice"}}`, 1, 0),
+			node(2, "MERGED", "null", 2, 1),
+			node(1, "MERGED", "null", 1, 1))
+	})
+	testGitHub(t, mux)
+
+	prs, _, err := GetAllPRsGraphQL(context.Background(), "octo", "demo", EnumerateOptions{IncludeAutoMerge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]struct {
+		merged    bool
+		enabledBy string
+	}{
+		3: {false, "alice"}, // pending
+		2: {true, ""},       // re-enabled after turning it off
+		1: {false, ""},      // turned off, then merged by hand
+	}
+	for _, pr := range prs {
+		w := want[pr.Number]
+		if pr.AutoMerged == nil || *pr.AutoMerged != w.merged || pr.AutoMergeEnabledBy != w.enabledBy {
+			t.Errorf("#%d: AutoMerged = %v, AutoMergeEnabledBy = %q, want %v and %q", pr.Number, pr.AutoMerged, pr.AutoMergeEnabledBy, w.merged, w.enabledBy)
+		}
+	}
+}
+
+func TestAutoMergedFromTimeline(t *testing.T) {
+	events := func(names ...string) []*github.Timeline {
+		var out []*github.Timeline
+		for _, n := range names {
+			out = append(out, &github.Timeline{Event: github.Ptr(n)})
+		}
+		return out
+	}
+	tests := []struct {
+		name   string
+		merged bool
+		events []*github.Timeline
+		want   bool
+	}{
+		{"auto-merged", true, events("labeled", "auto_merge_enabled", "merged"), true},
+		{"auto-squashed", true, events("auto_squash_enabled", "merged"), true},
+		{"merged by hand", true, events("merged"), false},
+		{"turned off before merging", true, events("auto_merge_enabled", "auto_merge_disabled", "merged"), false},
+		{"pending", false, events("auto_rebase_enabled"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AutoMerged(tt.merged, tt.events); got != tt.want {
+				t.Errorf("AutoMerged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
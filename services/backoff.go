@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog"
+)
+
+// rateLimitFallback is how long withBackoff waits on a primary rate limit
+// whose reset has already passed.
+var rateLimitFallback = 5 * time.Second
+
+// serverErrorBackoff is how long withBackoff waits on a 5xx response that
+// sent no Retry-After.
+var serverErrorBackoff = 3 * time.Second
+
+// withBackoff calls call until it succeeds, waiting out primary rate limits
+// (until the reset), secondary "abuse" limits (for their Retry-After, or
+// 10 seconds), 429s (for their Retry-After) and 5xx responses (their
+// Retry-After, or 3 seconds). After 6 server errors in a row it gives up
+// with a *RetriesExhaustedError. Any other error, ErrBudgetExhausted
+// included, is returned as is, as is ctx's once it is done. logger carries the fields that
+// identify the request and what describes it in log messages, e.g.
+// "listing PR files".
+func withBackoff(ctx context.Context, logger zerolog.Logger, what string, call func() (*github.Response, error)) error {
+	for serverErrors := 0; ; {
+		resp, err := call()
+		recordRate(resp)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrBudgetExhausted) {
+			return err
+		}
+
+		var sleepFor time.Duration
+		switch e := err.(type) {
+		case *github.RateLimitError:
+			resetAt := e.Rate.Reset.Time
+			sleepFor = time.Until(resetAt) + time.Second
+			if sleepFor < 0 {
+				sleepFor = rateLimitFallback
+			}
+			logger.Warn().Time("reset_at", resetAt).Dur("sleep_for", sleepFor).Msgf("rate limit reached while %s; sleeping", what)
+		case *github.AbuseRateLimitError:
+			sleepFor = 10 * time.Second
+			if e.RetryAfter != nil {
+				sleepFor = *e.RetryAfter
+			}
+			logger.Warn().Dur("sleep_for", sleepFor).Msgf("abuse detection triggered while %s; backing off", what)
+		default:
+			if resp == nil || resp.Response == nil {
+				return err
+			}
+			wait, ok := responseRetryAfter(resp)
+			switch {
+			case resp.StatusCode == http.StatusTooManyRequests && ok:
+				sleepFor = wait
+				logger.Warn().Dur("sleep_for", sleepFor).Msgf("too many requests while %s; backing off", what)
+			case resp.StatusCode >= 500:
+				if serverErrors++; serverErrors >= 6 {
+					return &RetriesExhaustedError{Attempts: serverErrors, Err: err}
+				}
+				sleepFor = serverErrorBackoff
+				if ok {
+					sleepFor = wait
+				}
+				logger.Warn().Int("status", resp.Response.StatusCode).Dur("sleep_for", sleepFor).Msgf("server error while %s; retrying", what)
+			default:
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleepFor):
+		}
+	}
+}
+
+// queryWithRetry runs a GraphQL query, retrying rate limits, 5xx responses
+// and network errors up to 6 attempts. Rate limits wait as long as GitHub
+// asked, when it said; everything else backs off exponentially. Other
+// errors are returned as is; a *RetriesExhaustedError wraps the last
+// transient one.
+func queryWithRetry(ctx context.Context, logger zerolog.Logger, what string, q interface{}, vars map[string]interface{}) error {
+	for attempt := 1; ; attempt++ {
+		err := GitHubGraphQLClient.Query(ctx, q, vars)
+		if err == nil {
+			return nil
+		}
+		var statusErr *StatusError
+		isStatus := errors.As(err, &statusErr)
+		transient := isNetworkError(err) || (isStatus && (statusErr.RateLimited || statusErr.StatusCode >= 500))
+		if !transient {
+			return err
+		}
+		if attempt >= 6 {
+			return &RetriesExhaustedError{Attempts: attempt, Err: err}
+		}
+		sleepFor := retryBackoff(attempt)
+		if isStatus && statusErr.RetryAfter > 0 {
+			sleepFor = statusErr.RetryAfter
+		}
+		logger.Warn().Int("attempt", attempt).Dur("sleep_for", sleepFor).Msgf("GraphQL transient error while %s; backing off", what)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleepFor):
+		}
+	}
+}
+
+// retryBackoff is the wait before retry attempt+1 when GitHub gave no
+// hint: exponential from 500ms, capped at 10s, plus a little jitter.
+func retryBackoff(attempt int) time.Duration {
+	base := time.Duration(500*(1<<uint(attempt-1))) * time.Millisecond
+	if base > 10*time.Second {
+		base = 10 * time.Second
+	}
+	return base + time.Duration(int64(time.Millisecond)*int64(100*attempt))
+}
+
+// retryAfter reports how long resp asks the client to wait: its
+// Retry-After header, in seconds or as an HTTP date, or, once the rate
+// limit is spent, the time until x-ratelimit-reset.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if secs, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(secs, 0))+time.Second, 0), true
+		}
+	}
+	return 0, false
+}
+
+// responseRetryAfter is retryAfter for a REST response, which may be nil.
+func responseRetryAfter(resp *github.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	return retryAfter(resp.Response)
+}
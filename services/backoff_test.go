@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog"
+)
+
+// roundTripFunc is an http.RoundTripper answering with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestWithBackoffRetriesRateLimit(t *testing.T) {
+	prev := rateLimitFallback
+	rateLimitFallback = time.Millisecond
+	t.Cleanup(func() { rateLimitFallback = prev })
+
+	calls := 0
+	client := github.NewClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		resp := &http.Response{Request: r, Header: http.Header{"Content-Type": {"application/json"}}}
+		if calls == 1 {
+			// Spent, with a reset that has already passed.
+			resp.StatusCode = http.StatusForbidden
+			resp.Header.Set("X-RateLimit-Limit", "5000")
+			resp.Header.Set("X-RateLimit-Remaining", "0")
+			resp.Header.Set("X-RateLimit-Reset", "1")
+			resp.Body = io.NopCloser(strings.NewReader(`{"message":"API rate limit exceeded"}`))
+			return resp, nil
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(strings.NewReader(`{"name":"widgets"}`))
+		return resp, nil
+	})})
+
+	var repo *github.Repository
+	var firstErr error
+	err := withBackoff(context.Background(), zerolog.Nop(), "fetching repo", func() (*github.Response, error) {
+		r, resp, err := client.Repositories.Get(context.Background(), "acme", "widgets")
+		if firstErr == nil {
+			firstErr = err
+		}
+		repo = r
+		return resp, err
+	})
+	if err != nil {
+		t.Fatalf("withBackoff = %v, want nil", err)
+	}
+	if _, ok := firstErr.(*github.RateLimitError); !ok {
+		t.Fatalf("first attempt failed with %T (%v), want *github.RateLimitError", firstErr, firstErr)
+	}
+	if calls != 2 || repo.GetName() != "widgets" {
+		t.Errorf("%d requests, repo %q; want 2 and widgets", calls, repo.GetName())
+	}
+}
+
+func TestWithBackoffReturnsOtherErrors(t *testing.T) {
+	calls := 0
+	client := github.NewClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{Request: r, StatusCode: http.StatusNotFound, Header: http.Header{},
+			Body: io.NopCloser(strings.NewReader(`{"message":"Not Found"}`))}, nil
+	})})
+	err := withBackoff(context.Background(), zerolog.Nop(), "fetching repo", func() (*github.Response, error) {
+		_, resp, err := client.Repositories.Get(context.Background(), "acme", "widgets")
+		return resp, err
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("withBackoff = %v after %d requests, want the 404 after 1", err, calls)
+	}
+}
+
+func TestWithBackoffGivesUp(t *testing.T) {
+	prev := serverErrorBackoff
+	serverErrorBackoff = time.Millisecond
+	t.Cleanup(func() { serverErrorBackoff = prev })
+
+	status := func(code int) func(*http.Request) (*http.Response, error) {
+		return func(r *http.Request) (*http.Response, error) {
+			return &http.Response{Request: r, StatusCode: code, Header: http.Header{},
+				Body: io.NopCloser(strings.NewReader(`{"message":"nope"}`))}, nil
+		}
+	}
+	tests := []struct {
+		name      string
+		roundTrip func(*http.Request) (*http.Response, error)
+		wantCalls int
+		check     func(error) bool
+	}{
+		{"budget exhausted", func(*http.Request) (*http.Response, error) { return nil, ErrBudgetExhausted }, 1,
+			func(err error) bool { return errors.Is(err, ErrBudgetExhausted) }},
+		{"server errors", status(http.StatusBadGateway), 6,
+			func(err error) bool {
+				var exhausted *RetriesExhaustedError
+				return errors.As(err, &exhausted) && exhausted.Attempts == 6
+			}},
+		{"unprocessable", status(http.StatusUnprocessableEntity), 1,
+			func(err error) bool {
+				var respErr *github.ErrorResponse
+				return errors.As(err, &respErr)
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := github.NewClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				return tt.roundTrip(r)
+			})})
+			err := withBackoff(context.Background(), zerolog.Nop(), "fetching repo", func() (*github.Response, error) {
+				_, resp, err := client.Repositories.Get(context.Background(), "acme", "widgets")
+				return resp, err
+			})
+			if !tt.check(err) || calls != tt.wantCalls {
+				t.Errorf("withBackoff = %v after %d requests, want %d", err, calls, tt.wantCalls)
+			}
+		})
+	}
+}
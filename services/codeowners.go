@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog/log"
+)
+
+// codeownersPaths are where GitHub looks for CODEOWNERS, in its order of
+// precedence.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// GetCodeowners returns the text of the CODEOWNERS file on the repo's
+// default branch, or "" when the repo has none.
+func GetCodeowners(ctx context.Context, owner, repo string) (string, error) {
+	if GitHubClient == nil {
+		return "", errors.New("GitHub client not initialized")
+	}
+
+	for _, path := range codeownersPaths {
+		var file *github.RepositoryContent
+		err := withBackoff(ctx, log.With().Str("owner", owner).Str("repo", repo).Str("path", path).Logger(), "fetching CODEOWNERS", func() (*github.Response, error) {
+			f, _, resp, err := GitHubClient.Repositories.GetContents(ctx, owner, repo, path, nil)
+			file = f
+			return resp, err
+		})
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		if file == nil {
+			continue
+		}
+		text, err := file.GetContent()
+		if err != nil {
+			return "", err
+		}
+		log.Debug().Str("owner", owner).Str("repo", repo).Str("path", path).Msg("fetched CODEOWNERS")
+		return text, nil
+	}
+	return "", nil
+}
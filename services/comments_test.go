@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestRepoCommentsBreakdownPageCap(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	comment := func(number int, at time.Time) string {
+		return fmt.Sprintf(`{"id":%d,"user":{"login":"bob","type":"User"},"created_at":%q,"issue_url":"https://api.github.com/repos/acme/widgets/issues/%d"}`,
+			at.Unix(), at.Format(time.RFC3339), number)
+	}
+	testGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("sort") != "created" || q.Get("direction") != "asc" {
+			t.Errorf("%s listed without sort=created&direction=asc", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path + "?page=" + q.Get("page") {
+		case "/api/v3/repos/acme/widgets/issues/comments?page=1":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/acme/widgets/issues/comments?page=2>; rel="next"`, "http://"+r.Host))
+			fmt.Fprintf(w, "[%s,%s]", comment(1, created.Add(time.Hour)), comment(2, created.Add(2*time.Hour)))
+		case "/api/v3/repos/acme/widgets/issues/comments?page=2":
+			// What GitHub answers past its page limit.
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message":"In order to keep the API fast for everyone, pagination is limited for this resource."}`)
+		case "/api/v3/repos/acme/widgets/pulls/comments?page=1":
+			fmt.Fprint(w, "[]")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	prs := map[int]PRRef{
+		1: {Author: "alice", CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+		2: {Author: "alice", CreatedAt: created, UpdatedAt: created.Add(3 * time.Hour)},
+	}
+	breakdowns, err := GetRepoCommentsBreakdown(context.Background(), "acme", "widgets", prs, CommentOptions{})
+	var truncated *CommentsTruncatedError
+	if !errors.As(err, &truncated) || !errors.Is(err, ErrCommentsTruncated) {
+		t.Fatalf("GetRepoCommentsBreakdown error = %v, want a *CommentsTruncatedError", err)
+	}
+	if want := created.Add(2 * time.Hour); !truncated.Cutoff.Equal(want) || truncated.Page != 2 {
+		t.Errorf("truncated at page %d with cutoff %s, want page 2 and %s", truncated.Page, truncated.Cutoff, want)
+	}
+	if breakdowns[1].IssueComments != 1 || breakdowns[2].IssueComments != 1 {
+		t.Errorf("breakdowns gathered before the cap were lost: %+v", breakdowns)
+	}
+}
+
+func TestRepoCommentsBreakdownCountSince(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	since := created.Add(2 * time.Hour)
+	issue := func(number int, at time.Time) string {
+		return fmt.Sprintf(`{"id":%d,"user":{"login":"bob","type":"User"},"created_at":%q,"issue_url":"https://api.github.com/repos/acme/widgets/issues/%d"}`,
+			at.Unix(), at.Format(time.RFC3339), number)
+	}
+	review := func(number int, at time.Time) string {
+		return fmt.Sprintf(`{"id":%d,"user":{"login":"bob","type":"User"},"created_at":%q,"pull_request_url":"https://api.github.com/repos/acme/widgets/pulls/%d"}`,
+			at.Unix(), at.Format(time.RFC3339), number)
+	}
+	testGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("since"); got != since.Format(time.RFC3339) {
+			t.Errorf("%s listed with since=%q, want %s", r.URL.Path, got, since.Format(time.RFC3339))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v3/repos/acme/widgets/issues/comments":
+			// Edited after since, but created before: already counted.
+			fmt.Fprintf(w, "[%s,%s,%s]", issue(1, created.Add(time.Hour)), issue(1, created.Add(3*time.Hour)), issue(2, created.Add(time.Hour)))
+		case "/api/v3/repos/acme/widgets/pulls/comments":
+			fmt.Fprintf(w, "[%s,%s]", review(1, created.Add(4*time.Hour)), review(2, created.Add(90*time.Minute)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	prs := map[int]PRRef{
+		1: {Author: "alice", CreatedAt: created, CountSince: since}, // stored by an earlier run
+		2: {Author: "alice", CreatedAt: created.Add(30 * time.Minute)},
+	}
+	breakdowns, err := GetRepoCommentsBreakdown(context.Background(), "acme", "widgets", prs, CommentOptions{Since: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		number        int
+		issue, review int
+	}{
+		{1, 1, 1},
+		{2, 1, 1},
+	}
+	for _, tt := range tests {
+		b := breakdowns[tt.number]
+		if b.IssueComments != tt.issue || b.ReviewComments != tt.review {
+			t.Errorf("#%d counted %d issue and %d review comments, want %d and %d", tt.number, b.IssueComments, b.ReviewComments, tt.issue, tt.review)
+		}
+	}
+}
+
+func TestRepoCommentsBreakdownReturnsListingErrors(t *testing.T) {
+	requests := 0
+	testGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Not Found"}`)
+	}))
+	_, err := GetRepoCommentsBreakdown(context.Background(), "acme", "widgets", nil, CommentOptions{})
+	var respErr *github.ErrorResponse
+	if !errors.As(err, &respErr) || requests != 1 {
+		t.Errorf("GetRepoCommentsBreakdown = %v after %d requests, want the 404 after 1", err, requests)
+	}
+}
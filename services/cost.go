@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	githubv4 "github.com/shurcooL/githubv4"
+)
+
+// CostEstimate is the projected GraphQL point cost of enumerating a repo's
+// PRs with a given set of optional fields.
+type CostEstimate struct {
+	Owner       string
+	Repo        string
+	PRs         int
+	Pages       int
+	CostPerPage int
+}
+
+// Total is the estimated cost of the whole enumeration.
+func (e CostEstimate) Total() int { return e.Pages * e.CostPerPage }
+
+// EstimateCost asks GitHub for the cost of one enumeration page (a dry run
+// that spends no points on the page itself) and projects it across the
+// repo's PR count. Per-PR REST requests are not included.
+func EstimateCost(ctx context.Context, owner, repo string, eopts EnumerateOptions) (CostEstimate, error) {
+	if GitHubGraphQLClient == nil {
+		return CostEstimate{}, errors.New("GitHub GraphQL client not initialized")
+	}
+
+	var page prPageQuery
+	logger := log.With().Str("owner", owner).Str("repo", repo).Logger()
+	if err := queryWithRetry(ctx, logger, "estimating cost", &page, prPageVars(owner, repo, eopts, true)); err != nil {
+		return CostEstimate{}, err
+	}
+
+	prs, err := CountPRs(ctx, owner, repo)
+	if err != nil {
+		return CostEstimate{}, err
+	}
+	return CostEstimate{
+		Owner:       owner,
+		Repo:        repo,
+		PRs:         prs,
+		Pages:       (prs + prPageSize - 1) / prPageSize,
+		CostPerPage: page.RateLimit.Cost,
+	}, nil
+}
+
+// CountPRs returns the number of PRs in a repo, in any state, with a single
+// cheap GraphQL query.
+func CountPRs(ctx context.Context, owner, repo string) (int, error) {
+	if GitHubGraphQLClient == nil {
+		return 0, errors.New("GitHub GraphQL client not initialized")
+	}
+
+	var count struct {
+		Repository struct {
+			PullRequests struct {
+				TotalCount int
+			}
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(repo),
+	}
+	if err := queryWithRetry(ctx, log.With().Str("owner", owner).Str("repo", repo).Logger(), "counting PRs", &count, vars); err != nil {
+		return 0, err
+	}
+	return count.Repository.PullRequests.TotalCount, nil
+}
+
+// WriteCostEstimates prints estimates as a table with a total row when there
+// is more than one.
+func WriteCostEstimates(w io.Writer, estimates []CostEstimate) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tPRS\tPAGES\tCOST/PAGE\tEST. COST")
+	sum := 0
+	for _, e := range estimates {
+		fmt.Fprintf(tw, "%s/%s\t%d\t%d\t%d\t%d\n", e.Owner, e.Repo, e.PRs, e.Pages, e.CostPerPage, e.Total())
+		sum += e.Total()
+	}
+	if len(estimates) > 1 {
+		fmt.Fprintf(tw, "total\t\t\t\t%d\n", sum)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestInitGitHubBaseURL(t *testing.T) {
+	prev, prevGraphQL := GitHubClient, GitHubGraphQLClient
+	t.Cleanup(func() { GitHubClient, GitHubGraphQLClient = prev, prevGraphQL })
+	t.Setenv("GITHUB_TOKENS", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	ctx := context.Background()
+
+	tests := []struct {
+		name, base, upload   string
+		wantBase, wantUpload string
+	}{
+		{"github.com", "", "", "https://api.github.com/", "https://uploads.github.com/"},
+		{"enterprise", "https://github.example.com/", "", "https://github.example.com/api/v3/", "https://github.example.com/api/uploads/"},
+		{"enterprise with api path", "https://github.example.com/api/v3", "", "https://github.example.com/api/v3/", "https://github.example.com/api/uploads/"},
+		{"separate upload host", "https://github.example.com/", "https://uploads.example.com/", "https://github.example.com/api/v3/", "https://uploads.example.com/api/uploads/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_BASE_URL", tt.base)
+			t.Setenv("GITHUB_UPLOAD_URL", tt.upload)
+			if err := InitGitHub(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if got := GitHubClient.BaseURL.String(); got != tt.wantBase {
+				t.Errorf("BaseURL = %s, want %s", got, tt.wantBase)
+			}
+			if got := GitHubClient.UploadURL.String(); got != tt.wantUpload {
+				t.Errorf("UploadURL = %s, want %s", got, tt.wantUpload)
+			}
+		})
+	}
+
+	t.Setenv("GITHUB_BASE_URL", "github.example.com")
+	if err := InitGitHub(ctx); err == nil {
+		t.Error("InitGitHub accepted a base URL without a scheme")
+	}
+}
+
+func TestEnterpriseEndpoints(t *testing.T) {
+	var paths []string
+	srv := testGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/graphql":
+			fmt.Fprint(w, `{"data":{"repository":{"pullRequests":{"totalCount":7}}}}`)
+		case "/api/v3/repos/acme/widgets":
+			fmt.Fprint(w, `{"name":"widgets"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	if got, want := GitHubClient.BaseURL.String(), srv.URL+"/api/v3/"; got != want {
+		t.Errorf("BaseURL = %s, want %s", got, want)
+	}
+	ctx := context.Background()
+	if _, _, err := GitHubClient.Repositories.Get(ctx, "acme", "widgets"); err != nil {
+		t.Fatalf("REST request: %v", err)
+	}
+	if n, err := CountPRs(ctx, "acme", "widgets"); err != nil || n != 7 {
+		t.Fatalf("CountPRs = %d, %v; want 7 from /api/graphql", n, err)
+	}
+	if want := []string{"/api/v3/repos/acme/widgets", "/api/graphql"}; !slices.Equal(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// ErrRepoNotFound is returned (wrapped) when GitHub reports that a repository
+// does not exist or is not visible to the token.
+var ErrRepoNotFound = errors.New("repository not found")
+
+// ErrCommentsTruncated is returned (wrapped) when GitHub refuses to
+// paginate a comment listing any further, so not every comment was seen.
+var ErrCommentsTruncated = errors.New("comment pagination limit reached")
+
+// CommentsTruncatedError is returned by GetRepoCommentsBreakdown when GitHub
+// stopped paginating a repo-wide comment listing at Page. Listings run
+// oldest first, so every comment created up to Cutoff was seen; PRs updated
+// since may be missing some.
+type CommentsTruncatedError struct {
+	Listing string
+	Page    int
+	Cutoff  time.Time
+}
+
+func (e *CommentsTruncatedError) Error() string {
+	return fmt.Sprintf("%s page %d: %v", e.Listing, e.Page, ErrCommentsTruncated)
+}
+
+func (e *CommentsTruncatedError) Is(target error) bool { return target == ErrCommentsTruncated }
+
+// paginationLimited reports whether a failed list request was GitHub
+// refusing a page past its pagination limit for the resource. It answers
+// 422 there rather than an empty page; the first page is never limited.
+func paginationLimited(resp *github.Response, page int) bool {
+	return page > 1 && resp != nil && resp.Response != nil && resp.StatusCode == http.StatusUnprocessableEntity
+}
+
+// RetriesExhaustedError is returned when a request kept failing with
+// transient errors until its retry budget ran out. Err is the last failure.
+type RetriesExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetriesExhaustedError) Unwrap() error { return e.Err }
+
+// StatusError is a GraphQL response GitHub refused: a non-2xx status, or a
+// 200 whose only errors are RATE_LIMITED. The GraphQL client would
+// otherwise report either as a bare string.
+type StatusError struct {
+	StatusCode int
+	// RateLimited is set for primary and secondary rate limits.
+	RateLimited bool
+	// RetryAfter is how long GitHub asked us to wait, from Retry-After or,
+	// with the budget spent, x-ratelimit-reset; zero if it gave no hint.
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.RateLimited {
+		return fmt.Sprintf("GraphQL rate limit exceeded (status %d, retry after %s): %s", e.StatusCode, e.RetryAfter, e.Body)
+	}
+	return fmt.Sprintf("non-200 OK status code: %d %s body: %q", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// IsRateLimit reports whether err is a GitHub primary or secondary rate
+// limit, from REST or GraphQL.
+func IsRateLimit(err error) bool {
+	var rlErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var statusErr *StatusError
+	return errors.As(err, &rlErr) || errors.As(err, &abuseErr) || (errors.As(err, &statusErr) && statusErr.RateLimited)
+}
+
+// IsPermissionDenied reports whether err means the token may not read the
+// requested data, e.g. branch protection rules without admin access.
+func IsPermissionDenied(err error) bool {
+	if err == nil || IsRateLimit(err) {
+		return false
+	}
+	var respErr *github.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusForbidden {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusForbidden && !statusErr.RateLimited
+}
+
+// IsNotFound reports whether err means the requested repository or PR does
+// not exist.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrRepoNotFound) {
+		return true
+	}
+	var respErr *github.ErrorResponse
+	return errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestIsPermissionDenied(t *testing.T) {
+	forbidden := &http.Response{StatusCode: http.StatusForbidden}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"REST 403", &github.ErrorResponse{Response: forbidden, Message: "Must have admin rights to Repository."}, true},
+		{"GraphQL FORBIDDEN", fmt.Errorf("reading: %w", &StatusError{StatusCode: http.StatusForbidden}), true},
+		{"GraphQL secondary rate limit", &StatusError{StatusCode: http.StatusForbidden, RateLimited: true}, false},
+		{"REST rate limit", &github.RateLimitError{Response: forbidden}, false},
+		{"not found", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}, false},
+		{"message only", errors.New("Resource not accessible by integration"), false},
+	}
+	for _, tt := range tests {
+		if got := IsPermissionDenied(tt.err); got != tt.want {
+			t.Errorf("%s: IsPermissionDenied = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// etagCacheDir, when set, enables conditional requests for contexts marked
+// with withConditional.
+var etagCacheDir string
+
+var conditionalRequests, conditionalHits atomic.Int64
+
+// SetETagCache stores cacheable REST responses in dir so later runs can
+// revalidate them with If-None-Match/If-Modified-Since. A 304 Not Modified
+// does not count against the rate limit and is served from the cache.
+// Cached pages are private data and are served back as GitHub's answers,
+// so dir is created readable by the current user only, and an existing
+// dir owned by someone else is refused.
+func SetETagCache(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !ownedByCurrentUser(fi) {
+		return fmt.Errorf("%s is not owned by the current user", dir)
+	}
+	etagCacheDir = dir
+	return nil
+}
+
+// ConditionalHits returns how many requests were answered 304 and served
+// from the ETag cache.
+func ConditionalHits() int64 { return conditionalHits.Load() }
+
+// ConditionalRequests returns how many requests were sent with a cached
+// ETag or Last-Modified, answered 304 or not.
+func ConditionalRequests() int64 { return conditionalRequests.Load() }
+
+type conditionalKey struct{}
+
+// withConditional marks requests made with ctx as cacheable. Only
+// endpoints whose pages are stable between runs are worth it.
+func withConditional(ctx context.Context) context.Context {
+	return context.WithValue(ctx, conditionalKey{}, true)
+}
+
+// etagEntry is a cached response. Link is kept because pagination reads it.
+type etagEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Link         string `json:"link,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// conditionalTransport revalidates marked GET requests against the ETag
+// cache. Entries are keyed by URL only, so a cache directory should not be
+// shared between tokens that see different data.
+type conditionalTransport struct {
+	base http.RoundTripper
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if etagCacheDir == "" || req.Method != http.MethodGet || req.Context().Value(conditionalKey{}) == nil {
+		return t.base.RoundTrip(req)
+	}
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	path := filepath.Join(etagCacheDir, hex.EncodeToString(sum[:])+".json")
+
+	cached, _ := readETagEntry(path)
+	if cached != nil {
+		conditionalRequests.Add(1)
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		conditionalHits.Add(1)
+		log.Debug().Str("path", req.URL.Path).Str("query", req.URL.RawQuery).Msg("304 Not Modified; served from ETag cache")
+		resp.Body.Close()
+		// Keep the live rate-limit headers but the cached page's links.
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header = resp.Header.Clone()
+		resp.Header.Set("Link", cached.Link)
+		resp.Header.Set("Content-Type", cached.ContentType)
+		resp.Body = io.NopCloser(bytes.NewReader(cached.Body))
+		resp.ContentLength = int64(len(cached.Body))
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""):
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		entry := etagEntry{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Link:         resp.Header.Get("Link"),
+			ContentType:  resp.Header.Get("Content-Type"),
+			Body:         body,
+		}
+		// A failed write only costs a full fetch next time.
+		_ = writeETagEntry(path, entry)
+	}
+	return resp, nil
+}
+
+func readETagEntry(path string) (*etagEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e etagEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// writeETagEntry replaces path atomically, as concurrent workers may read
+// it.
+func writeETagEntry(path string, e etagEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package services
+
+import "os"
+
+// ownedByCurrentUser reports whether fi belongs to the process's user.
+// Other systems have no owner in os.FileInfo; their per-user cache
+// directories are already private.
+func ownedByCurrentUser(fi os.FileInfo) bool { return true }
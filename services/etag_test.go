@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestSetETagCache(t *testing.T) {
+	prev := etagCacheDir
+	t.Cleanup(func() { etagCacheDir = prev })
+
+	dir := filepath.Join(t.TempDir(), "etags")
+	if err := SetETagCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if etagCacheDir != dir {
+		t.Errorf("cache dir = %s, want %s", etagCacheDir, dir)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		t.Errorf("cache dir created with mode %v, want it private to the user", fi.Mode().Perm())
+	}
+}
+
+func TestETagCacheServesNotModified(t *testing.T) {
+	prev := etagCacheDir
+	t.Cleanup(func() { etagCacheDir = prev })
+	if err := SetETagCache(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Like GitHub, the server charges the rate limit for full responses
+	// only; a 304 leaves it as it was.
+	var (
+		mu          sync.Mutex
+		remaining   = 5000
+		revalidated int
+	)
+	serve := func(etag, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				revalidated++
+				w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			remaining--
+			w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+			fmt.Fprint(w, body)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/octo/demo/issues/1/comments", serve(`"issue"`,
+		`[{"id": 1, "user": {"login": "bob", "type": "User"}, "body": "looks good", "created_at": "2024-01-02T00:00:00Z"}]`))
+	mux.HandleFunc("/api/v3/repos/octo/demo/pulls/1/comments", serve(`"review"`,
+		`[{"id": 2, "user": {"login": "ci[bot]", "type": "Bot"}, "body": "nit", "created_at": "2024-01-02T00:00:00Z"}]`))
+	testGitHub(t, mux)
+	ctx := context.Background()
+	pr := PRRef{Author: "alice"}
+	copts := CommentOptions{}
+
+	first, err := GetPRCommentsBreakdown(ctx, "octo", "demo", 1, pr, copts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, _ := LatestRate()
+	hits := ConditionalHits()
+
+	second, err := GetPRCommentsBreakdown(ctx, "octo", "demo", 1, pr, copts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revalidated != 2 || ConditionalHits()-hits != 2 {
+		t.Errorf("server answered %d requests 304 and %d were served from the cache, want 2 each", revalidated, ConditionalHits()-hits)
+	}
+	if first.TotalComments != 2 || first.BotComments != 1 {
+		t.Fatalf("first run counted %+v, want 2 comments, 1 by a bot", first)
+	}
+	if fmt.Sprint(second) != fmt.Sprint(first) {
+		t.Errorf("cached pages counted %+v, want %+v", second, first)
+	}
+	if after, _ := LatestRate(); after.Remaining != rate.Remaining {
+		t.Errorf("rate remaining went from %d to %d, want it unchanged by 304s", rate.Remaining, after.Remaining)
+	}
+}
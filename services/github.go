@@ -3,15 +3,19 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dickeyy/github-scraper/types"
 	"github.com/google/go-github/v74/github"
 	"github.com/rs/zerolog/log"
 	githubv4 "github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
 )
 
 var (
@@ -19,33 +23,71 @@ var (
 	GitHubGraphQLClient *githubv4.Client
 )
 
-func InitGitHub(ctx context.Context) {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token != "" {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		tc := oauth2.NewClient(ctx, ts)
-		GitHubClient = github.NewClient(tc)
-		log.Info().Bool("token_present", true).Msg("GitHub client initialized")
-		return
+// InitGitHub initializes the REST client with the tokens from Tokens. It
+// talks to github.com unless GITHUB_BASE_URL points it at a GitHub
+// Enterprise Server, e.g. https://github.example.com/; GITHUB_UPLOAD_URL
+// defaults to the same host.
+func InitGitHub(ctx context.Context) error {
+	tokens := Tokens()
+	client := github.NewClient(newHTTPClient(ctx, tokens))
+	if base := os.Getenv("GITHUB_BASE_URL"); base != "" {
+		u, err := enterpriseURL(base)
+		if err != nil {
+			return err
+		}
+		upload := os.Getenv("GITHUB_UPLOAD_URL")
+		if upload == "" {
+			upload = u.Scheme + "://" + u.Host + "/"
+		}
+		if client, err = client.WithEnterpriseURLs(base, upload); err != nil {
+			return fmt.Errorf("GITHUB_UPLOAD_URL: %w", err)
+		}
 	}
+	GitHubClient = client
+	log.Info().Bool("token_present", len(tokens) > 0).Int("tokens", len(tokens)).Str("base_url", client.BaseURL.String()).Msg("GitHub client initialized")
+	return nil
+}
 
-	GitHubClient = github.NewClient(nil)
-	log.Info().Bool("token_present", false).Msg("GitHub client initialized")
+// InitGitHubGraphQL initializes the GraphQL client using the same tokens
+// and GITHUB_BASE_URL as InitGitHub.
+func InitGitHubGraphQL(ctx context.Context) error {
+	tokens := Tokens()
+	endpoint := "https://api.github.com/graphql"
+	if base := os.Getenv("GITHUB_BASE_URL"); base != "" {
+		u, err := enterpriseURL(base)
+		if err != nil {
+			return err
+		}
+		// Enterprise Server serves GraphQL at /api/graphql, next to
+		// REST's /api/v3, which the base URL may already include.
+		u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3") + "/api/graphql"
+		endpoint = u.String()
+	}
+	hc := newHTTPClient(ctx, tokens)
+	hc.Transport = &statusTransport{base: hc.Transport}
+	GitHubGraphQLClient = githubv4.NewEnterpriseClient(endpoint, hc)
+	log.Info().Bool("token_present", len(tokens) > 0).Int("tokens", len(tokens)).Str("endpoint", endpoint).Msg("GitHub GraphQL client initialized")
+	return nil
 }
 
-// InitGitHubGraphQL initializes the GraphQL client using the same token env var.
-func InitGitHubGraphQL(ctx context.Context) {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token != "" {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		tc := oauth2.NewClient(ctx, ts)
-		GitHubGraphQLClient = githubv4.NewClient(tc)
-		log.Info().Bool("token_present", true).Msg("GitHub GraphQL client initialized")
-		return
+// enterpriseURL parses GITHUB_BASE_URL, which must be absolute.
+func enterpriseURL(base string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUB_BASE_URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("GITHUB_BASE_URL %q must be an absolute URL like https://github.example.com/", base)
 	}
+	return u, nil
+}
 
-	GitHubGraphQLClient = githubv4.NewClient(nil)
-	log.Info().Bool("token_present", false).Msg("GitHub GraphQL client initialized")
+// APIHost is the host the REST client talks to, e.g. api.github.com.
+func APIHost() string {
+	if GitHubClient == nil || GitHubClient.BaseURL == nil {
+		return ""
+	}
+	return GitHubClient.BaseURL.Host
 }
 
 func GetPRs(ctx context.Context, owner, repo string) ([]*github.PullRequest, error) {
@@ -68,12 +110,33 @@ func GetPRs(ctx context.Context, owner, repo string) ([]*github.PullRequest, err
 	return prs, nil
 }
 
+// PageRange bounds REST PR enumeration to pages Start through End
+// (1-based, inclusive). Zero values mean the first and last page.
+type PageRange struct {
+	Start int
+	End   int
+}
+
+// Validate rejects negative bounds and ranges that end before they start.
+func (r PageRange) Validate() error {
+	if r.Start < 0 || r.End < 0 {
+		return fmt.Errorf("page range must not be negative, got %d-%d", r.Start, r.End)
+	}
+	if r.End > 0 && max(r.Start, 1) > r.End {
+		return fmt.Errorf("start page %d is after end page %d", r.Start, r.End)
+	}
+	return nil
+}
+
 // GetAllPRs fetches all PRs from the repository, paginating through results
 // and respecting GitHub API rate limits and abuse detection backoffs.
-func GetAllPRs(ctx context.Context, owner, repo string) ([]*github.PullRequest, error) {
+func GetAllPRs(ctx context.Context, owner, repo string, pages PageRange) ([]*github.PullRequest, error) {
 	if GitHubClient == nil {
 		return nil, errors.New("GitHub client not initialized")
 	}
+	if err := pages.Validate(); err != nil {
+		return nil, err
+	}
 
 	opts := &github.PullRequestListOptions{
 		State:     "all",
@@ -81,11 +144,11 @@ func GetAllPRs(ctx context.Context, owner, repo string) ([]*github.PullRequest,
 		Direction: "desc",
 		ListOptions: github.ListOptions{
 			PerPage: 100,
-			Page:    1,
+			Page:    max(pages.Start, 1),
 		},
 	}
 
-	log.Info().Str("owner", owner).Str("repo", repo).Int("per_page", opts.PerPage).Msg("begin fetching PRs")
+	log.Info().Str("owner", owner).Str("repo", repo).Int("per_page", opts.PerPage).Int("start_page", opts.Page).Int("end_page", pages.End).Msg("begin fetching PRs")
 
 	var allPRs []*github.PullRequest
 
@@ -98,53 +161,11 @@ func GetAllPRs(ctx context.Context, owner, repo string) ([]*github.PullRequest,
 
 		log.Debug().Str("owner", owner).Str("repo", repo).Int("page", opts.Page).Int("per_page", opts.PerPage).Msg("fetching PR page")
 
-		for {
+		err = withBackoff(ctx, log.With().Str("owner", owner).Str("repo", repo).Logger(), "listing PRs", func() (*github.Response, error) {
 			pagePRs, resp, err = GitHubClient.PullRequests.List(ctx, owner, repo, opts)
-			if err == nil {
-				break
-			}
-
-			if rlErr, ok := err.(*github.RateLimitError); ok {
-				resetAt := rlErr.Rate.Reset.Time
-				sleepFor := time.Until(resetAt) + time.Second
-				if sleepFor < 0 {
-					sleepFor = 5 * time.Second
-				}
-				log.Warn().Time("reset_at", resetAt).Dur("sleep_for", sleepFor).Msg("rate limit reached; sleeping")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-
-			if abuseErr, ok := err.(*github.AbuseRateLimitError); ok {
-				var sleepFor time.Duration
-				if abuseErr.RetryAfter != nil {
-					sleepFor = *abuseErr.RetryAfter
-				} else {
-					sleepFor = 10 * time.Second
-				}
-				log.Warn().Dur("sleep_for", sleepFor).Msg("abuse detection triggered; backing off")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-
-			if resp != nil && resp.Response != nil && resp.Response.StatusCode >= 500 {
-				log.Warn().Int("status", resp.Response.StatusCode).Msg("server error; retrying")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(3 * time.Second):
-				}
-				continue
-			}
-
+			return resp, err
+		})
+		if err != nil {
 			return nil, err
 		}
 
@@ -157,10 +178,11 @@ func GetAllPRs(ctx context.Context, owner, repo string) ([]*github.PullRequest,
 
 		allPRs = append(allPRs, pagePRs...)
 
-		if resp == nil || resp.NextPage == 0 {
+		next := nextPage(resp)
+		if next == 0 || (pages.End > 0 && opts.Page >= pages.End) {
 			break
 		}
-		opts.Page = resp.NextPage
+		opts.Page = next
 	}
 
 	log.Info().Str("owner", owner).Str("repo", repo).Int("total", len(allPRs)).Msg("completed fetching PRs")
@@ -174,145 +196,671 @@ func GetPRWithBackoff(ctx context.Context, owner, repo string, number int) (*git
 		return nil, errors.New("GitHub client not initialized")
 	}
 
-	for {
-		pr, resp, err := GitHubClient.PullRequests.Get(ctx, owner, repo, number)
-		if err == nil {
-			return pr, nil
+	var pr *github.PullRequest
+	err := withBackoff(ctx, log.With().Int("number", number).Logger(), "fetching PR", func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		pr, resp, err = GitHubClient.PullRequests.Get(ctx, owner, repo, number)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// CommentsBreakdown holds counts for total comments and bot-only comments across
+// issue comments and review comments for a PR. "Comments" includes both types.
+type CommentsBreakdown struct {
+	// IssueComments are conversation comments and ReviewComments are
+	// comments on the diff; TotalComments is their sum.
+	IssueComments  int
+	ReviewComments int
+	TotalComments  int
+	BotComments    int
+	// AuthorComments counts comments written by the PR's own author.
+	AuthorComments int
+	// BotsByLogin splits BotComments by bot login; nil without bot comments.
+	BotsByLogin map[string]int
+	// ByLogin splits TotalComments by commenter login (bots included);
+	// deleted accounts have an empty login. Only filled with
+	// CommentOptions.ByLogin.
+	ByLogin map[string]int
+	// FirstDayComments and FirstWeekComments count comments made within
+	// 24 hours and 7 days of the PR's creation; the week includes the day.
+	FirstDayComments  int
+	FirstWeekComments int
+	// Truncated is set when GitHub refused to paginate further, so the
+	// counts miss comments.
+	Truncated bool
+	// SentimentSum and SentimentScored accumulate CommentOptions.Scorer
+	// scores of non-bot comments.
+	SentimentSum    float64
+	SentimentScored int
+}
+
+// Merge adds the counts of o, e.g. newer comments, to b. Truncation
+// carries over.
+func (b *CommentsBreakdown) Merge(o CommentsBreakdown) {
+	b.IssueComments += o.IssueComments
+	b.ReviewComments += o.ReviewComments
+	b.TotalComments += o.TotalComments
+	b.BotComments += o.BotComments
+	b.AuthorComments += o.AuthorComments
+	b.FirstDayComments += o.FirstDayComments
+	b.FirstWeekComments += o.FirstWeekComments
+	b.Truncated = b.Truncated || o.Truncated
+	b.SentimentSum += o.SentimentSum
+	b.SentimentScored += o.SentimentScored
+	for login, n := range o.BotsByLogin {
+		if b.BotsByLogin == nil {
+			b.BotsByLogin = make(map[string]int)
 		}
+		b.BotsByLogin[login] += n
+	}
+	for login, n := range o.ByLogin {
+		if b.ByLogin == nil {
+			b.ByLogin = make(map[string]int)
+		}
+		b.ByLogin[login] += n
+	}
+}
 
-		if rlErr, ok := err.(*github.RateLimitError); ok {
-			resetAt := rlErr.Rate.Reset.Time
-			sleepFor := time.Until(resetAt) + time.Second
-			if sleepFor < 0 {
-				sleepFor = 5 * time.Second
-			}
-			log.Warn().Int("number", number).Time("reset_at", resetAt).Dur("sleep_for", sleepFor).Msg("rate limit reached while fetching PR; sleeping")
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(sleepFor):
+// Sentiment is the average score of the scored comments, or nil when none
+// were scored.
+func (b CommentsBreakdown) Sentiment() *float64 {
+	if b.SentimentScored == 0 {
+		return nil
+	}
+	avg := b.SentimentSum / float64(b.SentimentScored)
+	return &avg
+}
+
+// PRRef identifies what comment scans need to know about a PR besides
+// its number.
+type PRRef struct {
+	Author    string
+	CreatedAt time.Time
+	// UpdatedAt decides, when a repo-level scan is truncated, whether the
+	// PR may have comments the scan did not reach.
+	UpdatedAt time.Time
+	// CountSince, when set, makes repo-level scans count only the PR's
+	// comments created at or after it.
+	CountSince time.Time
+}
+
+// Comment windows, measured from the PR's creation.
+const (
+	firstDayWindow  = 24 * time.Hour
+	firstWeekWindow = 7 * 24 * time.Hour
+)
+
+// addTiming counts a comment made at into the windows after prCreated.
+// Nothing is counted when either time is unknown.
+func (b *CommentsBreakdown) addTiming(prCreated, at time.Time) {
+	if prCreated.IsZero() || at.IsZero() {
+		return
+	}
+	since := at.Sub(prCreated)
+	if since < firstDayWindow {
+		b.FirstDayComments++
+	}
+	if since < firstWeekWindow {
+		b.FirstWeekComments++
+	}
+}
+
+// addComment counts a comment, as a review (diff) comment if review is set
+// and an issue comment otherwise, and by login if byLogin is set.
+func (b *CommentsBreakdown) addComment(login string, review, byLogin bool) {
+	if review {
+		b.ReviewComments++
+	} else {
+		b.IssueComments++
+	}
+	b.TotalComments++
+	if !byLogin {
+		return
+	}
+	if b.ByLogin == nil {
+		b.ByLogin = make(map[string]int)
+	}
+	b.ByLogin[login]++
+}
+
+// addBot counts a bot comment by login.
+func (b *CommentsBreakdown) addBot(login string) {
+	b.BotComments++
+	if b.BotsByLogin == nil {
+		b.BotsByLogin = make(map[string]int)
+	}
+	b.BotsByLogin[login]++
+}
+
+// CommentOptions controls how comments are classified while counting.
+type CommentOptions struct {
+	// BotLogins are extra logins counted as bots, for automation accounts
+	// GitHub reports as regular users. Matched case-insensitively.
+	BotLogins []string
+	// Scorer, when set, scores the text of every non-bot comment into
+	// CommentsBreakdown's sentiment fields.
+	Scorer CommentScorer
+	// Since, when set, limits repo-level scans to comments updated at or
+	// after it.
+	Since time.Time
+	// ByLogin fills CommentsBreakdown.ByLogin, which costs a map per PR.
+	ByLogin bool
+}
+
+// score adds body's sentiment to b when a scorer is configured. Bot
+// comments are skipped so CI chatter doesn't drown out people.
+func (o CommentOptions) score(b *CommentsBreakdown, u *github.User, body string) {
+	if o.Scorer == nil || o.IsBot(u) {
+		return
+	}
+	b.SentimentSum += o.Scorer.Score(body)
+	b.SentimentScored++
+}
+
+// IsBot reports whether a comment author is a bot: a GitHub App/bot account
+// or one of the configured BotLogins.
+func (o CommentOptions) IsBot(u *github.User) bool {
+	if u == nil {
+		return false
+	}
+	if u.GetType() == "Bot" {
+		return true
+	}
+	for _, l := range o.BotLogins {
+		if strings.EqualFold(u.GetLogin(), l) {
+			return true
+		}
+	}
+	return false
+}
+
+// PRLite contains minimal PR details we need for rows
+type PRLite struct {
+	Number int
+	// NodeID is GitHub's global ID for the PR, stable across repo renames
+	// and transfers.
+	NodeID       string
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	State        string
+	CreatedAt    time.Time
+	// UpdatedAt is when the PR last changed, including new comments.
+	UpdatedAt time.Time
+	// ClosedAt is nil while the PR is open; merged PRs are closed too.
+	ClosedAt *time.Time
+	// MergedAt is nil unless the PR was merged.
+	MergedAt *time.Time
+	// TotalCommentsCount is GitHub's own combined comment count, nil when
+	// GitHub does not report it.
+	TotalCommentsCount *int
+	// Author is the login of the PR's author; empty for deleted accounts.
+	Author string
+	// AuthorType is the author's account type, e.g. User or Bot; empty
+	// for deleted accounts.
+	AuthorType string
+	// CrossRepository is set for PRs opened from a fork, including forks
+	// deleted since. HeadRef is the name of the PR's source branch.
+	CrossRepository bool
+	HeadRef         string
+	// MergeCommitSHA is empty for unmerged PRs and for merges GitHub did
+	// not record a merge commit for.
+	MergeCommitSHA string
+	// BaseRef is the name of the branch the PR targets.
+	BaseRef string
+	// Mergeable is MERGEABLE, CONFLICTING, or UNKNOWN; empty when not
+	// reported (REST listings).
+	Mergeable string
+	// BaseSHA and HeadSHA are the commits the PR's base and head refs pointed
+	// at, so the analyzed diff can be checked out later.
+	BaseSHA string
+	HeadSHA string
+	// Title and Body are the PR's title and description, only fetched
+	// with IncludeBody.
+	Title string
+	Body  string
+	// AutoMerged reports whether GitHub merged the PR via auto-merge, and
+	// AutoMergeEnabledBy who enabled auto-merge on an open PR where it is
+	// pending. Both are only fetched with IncludeAutoMerge.
+	AutoMerged         *bool
+	AutoMergeEnabledBy string
+	// Checks lists the head commit's check contexts as "name:result", only
+	// fetched with IncludeChecks.
+	Checks []string
+	// Labels are the names of the PR's labels; empty (not nil) when it
+	// has none.
+	Labels []string
+	// Commits is only fetched with IncludeCommits.
+	Commits *CommitInfo
+	// Deployments of the merge commit, only fetched with
+	// IncludeDeployments; empty (not nil) when there are none.
+	Deployments []types.Deployment
+	// Reviewers lists who reviewed the PR, only fetched with
+	// IncludeReviewers; empty (not nil) when nobody did.
+	Reviewers []string
+	// ReviewRequestEvents counts review requests and their removals over the
+	// PR's life, only fetched with IncludeTimeline.
+	ReviewRequestEvents *int
+	// FirstReviewRequestAt is when a reviewer was first requested, nil if
+	// never or without IncludeTimeline. ReviewSubmissions are when reviews
+	// by others than the author were submitted, only with IncludeReviewers.
+	// Reviewers and ReviewSubmissions cover the first 100 reviews only
+	// while ReviewsTruncated is set; list them all with GetPRReviews.
+	FirstReviewRequestAt *time.Time
+	ReviewSubmissions    []time.Time
+	ReviewsTruncated     bool
+	// ResolvedThreads and UnresolvedThreads count review threads, only
+	// fetched with IncludeReviewThreads. They cover the first page only
+	// while ReviewThreadsCursor is set; see CountReviewThreads.
+	ResolvedThreads     *int
+	UnresolvedThreads   *int
+	ReviewThreadsCursor string
+	// ReviewCounts is only fetched with IncludeReviewCounts.
+	ReviewCounts *ReviewCounts
+}
+
+// ReviewCounts counts a PR's submitted reviews by their state. Dismissed
+// and pending reviews are not counted.
+type ReviewCounts struct {
+	Approved         int
+	ChangesRequested int
+	Commented        int
+}
+
+// CommitInfo describes a PR's commits and, once merged, the commit GitHub
+// created on the base branch, so commit counts can be derived either way.
+type CommitInfo struct {
+	// PRCommits is the number of commits on the PR itself.
+	PRCommits int
+	// MergeParents is the parent count of the merge commit: 2 for merge
+	// commits, 1 for squash and rebase merges, 0 when unmerged.
+	MergeParents int
+	// MergeAuthoredAt and HeadAuthoredAt are the author dates of the merge
+	// commit and the PR's head commit. A rebase keeps the head commit's
+	// author date while a squash commit gets a new one.
+	MergeAuthoredAt time.Time
+	HeadAuthoredAt  time.Time
+}
+
+// EnumerateOptions selects optional fields fetched by GetAllPRsGraphQL.
+// Optional fields are skipped server-side via @include, so disabled ones
+// cost nothing.
+type EnumerateOptions struct {
+	// IncludeBody fetches each PR's title and description.
+	IncludeBody bool
+	// IncludeChecks fetches the CI check/status contexts of each PR's head
+	// commit, which noticeably raises the query's point cost.
+	IncludeChecks bool
+	// IncludeCommits fetches commit counts and merge commit details.
+	IncludeCommits bool
+	// IncludeDeployments fetches up to 10 deployments of each merge commit.
+	IncludeDeployments bool
+	// IncludeReviewers fetches the authors of each PR's first 100 reviews.
+	IncludeReviewers bool
+	// IncludeTimeline fetches review-request timeline event counts.
+	IncludeTimeline bool
+	// IncludeReviewThreads fetches the first 100 review threads of each PR
+	// to count resolved and unresolved ones.
+	IncludeReviewThreads bool
+	// IncludeReviewCounts fetches how many reviews of each PR approved,
+	// requested changes, or only commented. Only totals are requested, so
+	// they are exact without paginating the reviews.
+	IncludeReviewCounts bool
+	// IncludeAutoMerge fetches whether each PR was merged by auto-merge
+	// and who enabled pending auto-merge.
+	IncludeAutoMerge bool
+	// SincePRNumber, when positive, stops enumeration at the first PR
+	// numbered at or below it. Pages are newest-first, and PR numbers grow
+	// with creation time, so everything after that PR is older as well.
+	SincePRNumber int
+	// CreatedAfter, when set, likewise stops enumeration at the first PR
+	// created before it. A PR created at that instant is still returned.
+	CreatedAfter time.Time
+	// StartCursor resumes enumeration after a cursor returned by an earlier
+	// GetAllPRsGraphQL call. Cursors are only valid for the same query
+	// order and filters, and new PRs appear before, not after, them.
+	StartCursor string
+}
+
+// checkContextNode is a member of the StatusCheckRollupContext union: either
+// a check run (GitHub Actions, apps) or a legacy commit status.
+type checkContextNode struct {
+	Typename string `graphql:"__typename"`
+	CheckRun struct {
+		Name       string
+		Status     string
+		Conclusion string
+	} `graphql:"... on CheckRun"`
+	StatusContext struct {
+		Context string
+		State   string
+	} `graphql:"... on StatusContext"`
+}
+
+// checkEntries renders check contexts as "name:result" with lowercase
+// results, e.g. "build:success". Check runs without a conclusion yet report
+// their status (e.g. "in_progress").
+func checkEntries(nodes []checkContextNode) []string {
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		switch n.Typename {
+		case "CheckRun":
+			result := n.CheckRun.Conclusion
+			if result == "" {
+				result = n.CheckRun.Status
 			}
-			continue
+			out = append(out, n.CheckRun.Name+":"+strings.ToLower(result))
+		case "StatusContext":
+			out = append(out, n.StatusContext.Context+":"+strings.ToLower(n.StatusContext.State))
 		}
+	}
+	return out
+}
 
-		if abuseErr, ok := err.(*github.AbuseRateLimitError); ok {
-			var sleepFor time.Duration
-			if abuseErr.RetryAfter != nil {
-				sleepFor = *abuseErr.RetryAfter
-			} else {
-				sleepFor = 10 * time.Second
+// prNode is a PR as fetched by the bulk enumeration query.
+type prNode struct {
+	ID                 string
+	Number             int
+	Additions          int
+	Deletions          int
+	ChangedFiles       int
+	State              string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	ClosedAt           *time.Time
+	MergedAt           *time.Time
+	TotalCommentsCount *int
+	Author             *struct {
+		Typename string `graphql:"__typename"`
+		Login    string
+	}
+	IsCrossRepository bool
+	HeadRefName       string
+	Labels            struct {
+		TotalCount int
+		Nodes      []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 20)"`
+	MergeCommit *struct {
+		Oid     string
+		Parents struct {
+			TotalCount int
+		} `graphql:"parents @include(if: $includeCommits)"`
+		AuthoredDate time.Time `graphql:"authoredDate @include(if: $includeCommits)"`
+		Deployments  struct {
+			Nodes []struct {
+				Environment string
+				CreatedAt   time.Time
 			}
-			log.Warn().Int("number", number).Dur("sleep_for", sleepFor).Msg("abuse detection triggered while fetching PR; backing off")
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(sleepFor):
+		} `graphql:"deployments(first: 10) @include(if: $includeDeployments)"`
+	}
+	BaseRefName   string
+	BaseRefOid    string
+	HeadRefOid    string
+	Mergeable     string
+	ReviewThreads reviewThreadConnection `graphql:"reviewThreads(first: 100) @include(if: $includeReviewThreads)"`
+	Title         string                 `graphql:"title @include(if: $includeBody)"`
+	Body          string                 `graphql:"body @include(if: $includeBody)"`
+	// autoMergeRequest is cleared once a PR merges, so merged PRs are
+	// classified from their auto-merge timeline events instead.
+	AutoMergeRequest *struct {
+		EnabledBy *struct {
+			Login string
+		}
+	} `graphql:"autoMergeRequest @include(if: $includeAutoMerge)"`
+	AutoMergeEnabled struct {
+		TotalCount int
+	} `graphql:"autoMergeEnabled: timelineItems(itemTypes: [AUTO_MERGE_ENABLED_EVENT, AUTO_SQUASH_ENABLED_EVENT, AUTO_REBASE_ENABLED_EVENT]) @include(if: $includeAutoMerge)"`
+	AutoMergeDisabled struct {
+		TotalCount int
+	} `graphql:"autoMergeDisabled: timelineItems(itemTypes: [AUTO_MERGE_DISABLED_EVENT]) @include(if: $includeAutoMerge)"`
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					Contexts struct {
+						Nodes []checkContextNode
+					} `graphql:"contexts(first: 50)"`
+				}
 			}
-			continue
 		}
-
-		if resp != nil && resp.Response != nil && resp.Response.StatusCode >= 500 {
-			log.Warn().Int("number", number).Int("status", resp.Response.StatusCode).Msg("server error while fetching PR; retrying")
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(3 * time.Second):
+	} `graphql:"commits(last: 1) @include(if: $includeChecks)"`
+	ReviewRequested struct {
+		TotalCount int
+		Nodes      []struct {
+			ReviewRequestedEvent struct {
+				CreatedAt time.Time
+			} `graphql:"... on ReviewRequestedEvent"`
+		}
+	} `graphql:"reviewRequested: timelineItems(itemTypes: [REVIEW_REQUESTED_EVENT], first: 1) @include(if: $includeTimeline)"`
+	ReviewRequestRemoved struct {
+		TotalCount int
+	} `graphql:"reviewRequestRemoved: timelineItems(itemTypes: [REVIEW_REQUEST_REMOVED_EVENT]) @include(if: $includeTimeline)"`
+	Reviews struct {
+		TotalCount int
+		Nodes      []struct {
+			Author *struct {
+				Login string
 			}
-			continue
+			SubmittedAt *time.Time
 		}
-
-		return nil, err
-	}
+	} `graphql:"reviews(first: 100) @include(if: $includeReviewers)"`
+	ApprovedReviews struct {
+		TotalCount int
+	} `graphql:"approvedReviews: reviews(states: APPROVED) @include(if: $includeReviewCounts)"`
+	ChangesRequestedReviews struct {
+		TotalCount int
+	} `graphql:"changesRequestedReviews: reviews(states: CHANGES_REQUESTED) @include(if: $includeReviewCounts)"`
+	CommentedReviews struct {
+		TotalCount int
+	} `graphql:"commentedReviews: reviews(states: COMMENTED) @include(if: $includeReviewCounts)"`
+	HeadCommit struct {
+		TotalCount int
+		Nodes      []struct {
+			Commit struct {
+				AuthoredDate time.Time
+			}
+		}
+	} `graphql:"headCommit: commits(last: 1) @include(if: $includeCommits)"`
 }
 
-// CommentsBreakdown holds counts for total comments and bot-only comments across
-// issue comments and review comments for a PR. "Comments" includes both types.
-type CommentsBreakdown struct {
-	TotalComments int
-	BotComments   int
+// prPageQuery fetches one page of PRs. With $dryRun, GitHub only computes
+// the query's rate-limit cost without executing it.
+type prPageQuery struct {
+	RateLimit struct {
+		Cost      int
+		Remaining int
+	} `graphql:"rateLimit(dryRun: $dryRun)"`
+	Repository struct {
+		PullRequests struct {
+			TotalCount int
+			PageInfo   struct {
+				HasNextPage bool
+				EndCursor   githubv4.String
+			}
+			Nodes []prNode
+		} `graphql:"pullRequests(first: $pageSize, after: $cursor, orderBy: {field: CREATED_AT, direction: DESC}, states: [OPEN, CLOSED, MERGED])"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
 }
 
-// PRLite contains minimal PR details we need for rows
-type PRLite struct {
-	Number    int
-	Additions int
-	Deletions int
-	State     string
-	CreatedAt time.Time
+// prPageVars returns the variables for the first page of prPageQuery.
+func prPageVars(owner, repo string, eopts EnumerateOptions, dryRun bool) map[string]interface{} {
+	return map[string]interface{}{
+		"owner":                githubv4.String(owner),
+		"name":                 githubv4.String(repo),
+		"pageSize":             githubv4.Int(prPageSize),
+		"cursor":               (*githubv4.String)(nil),
+		"dryRun":               githubv4.Boolean(dryRun),
+		"includeBody":          githubv4.Boolean(eopts.IncludeBody),
+		"includeChecks":        githubv4.Boolean(eopts.IncludeChecks),
+		"includeCommits":       githubv4.Boolean(eopts.IncludeCommits),
+		"includeDeployments":   githubv4.Boolean(eopts.IncludeDeployments),
+		"includeReviewers":     githubv4.Boolean(eopts.IncludeReviewers),
+		"includeTimeline":      githubv4.Boolean(eopts.IncludeTimeline),
+		"includeReviewThreads": githubv4.Boolean(eopts.IncludeReviewThreads),
+		"includeReviewCounts":  githubv4.Boolean(eopts.IncludeReviewCounts),
+		"includeAutoMerge":     githubv4.Boolean(eopts.IncludeAutoMerge),
+	}
 }
 
+// prPageSize is the number of PRs per enumeration page, GitHub's maximum.
+const prPageSize = 100
+
 // GetAllPRsGraphQL fetches PR numbers and selected fields in bulk using
 // GitHub GraphQL API. It paginates through up to the repo's PR count.
-// It returns newest-first, matching our current sort order.
-func GetAllPRsGraphQL(ctx context.Context, owner, repo string) ([]PRLite, error) {
+// It returns newest-first, matching our current sort order, along with the
+// end cursor of the last page fetched (also on error), which can be passed
+// back as EnumerateOptions.StartCursor to continue after it.
+func GetAllPRsGraphQL(ctx context.Context, owner, repo string, eopts EnumerateOptions) ([]PRLite, string, error) {
 	if GitHubGraphQLClient == nil {
-		return nil, errors.New("GitHub GraphQL client not initialized")
+		return nil, "", errors.New("GitHub GraphQL client not initialized")
 	}
 
 	log.Info().Str("owner", owner).Str("repo", repo).Msg("fetching PRs via GraphQL")
 
-	type prNode struct {
-		Number    int
-		Additions int
-		Deletions int
-		State     string
-		CreatedAt time.Time
-	}
-	var q struct {
-		Repository struct {
-			PullRequests struct {
-				PageInfo struct {
-					HasNextPage bool
-					EndCursor   githubv4.String
-				}
-				Nodes []prNode
-			} `graphql:"pullRequests(first: $pageSize, after: $cursor, orderBy: {field: CREATED_AT, direction: DESC}, states: [OPEN, CLOSED, MERGED])"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	vars := map[string]interface{}{
-		"owner":    githubv4.String(owner),
-		"name":     githubv4.String(repo),
-		"pageSize": githubv4.Int(100),
-		"cursor":   (*githubv4.String)(nil),
+	var q prPageQuery
+	vars := prPageVars(owner, repo, eopts, false)
+	// cursor is the end of the last page fetched, so callers can resume
+	// even after an error.
+	cursor := eopts.StartCursor
+	if cursor != "" {
+		vars["cursor"] = githubv4.String(cursor)
 	}
 
 	var results []PRLite
+	totalCost := 0
+	reachedBound := false
 	for {
-		// Retry wrapper for GraphQL Query
-		var attempt int
-		for {
-			attempt++
-			err := GitHubGraphQLClient.Query(ctx, &q, vars)
-			if err == nil {
+		if err := queryWithRetry(ctx, log.With().Str("owner", owner).Str("repo", repo).Logger(), "fetching PRs", &q, vars); err != nil {
+			if strings.Contains(err.Error(), "Could not resolve to a Repository") {
+				return nil, cursor, fmt.Errorf("%s/%s: %w", owner, repo, ErrRepoNotFound)
+			}
+			return nil, cursor, err
+		}
+		totalCost += q.RateLimit.Cost
+		log.Debug().Str("owner", owner).Str("repo", repo).Int("cost", q.RateLimit.Cost).Int("remaining", q.RateLimit.Remaining).Msg("GraphQL page cost")
+		for _, n := range q.Repository.PullRequests.Nodes {
+			if (eopts.SincePRNumber > 0 && n.Number <= eopts.SincePRNumber) || n.CreatedAt.Before(eopts.CreatedAfter) {
+				reachedBound = true
 				break
 			}
-			// rate limit or transient 5xx
-			transient := strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "502") || strings.Contains(err.Error(), "503") || strings.Contains(err.Error(), "504")
-			if !transient || attempt >= 6 { // ~6 attempts
-				return nil, err
+			lite := PRLite{
+				NodeID:             n.ID,
+				Number:             n.Number,
+				Additions:          n.Additions,
+				Deletions:          n.Deletions,
+				ChangedFiles:       n.ChangedFiles,
+				State:              n.State,
+				CreatedAt:          n.CreatedAt,
+				UpdatedAt:          n.UpdatedAt,
+				ClosedAt:           n.ClosedAt,
+				MergedAt:           n.MergedAt,
+				TotalCommentsCount: n.TotalCommentsCount,
+				BaseRef:            n.BaseRefName,
+				Mergeable:          n.Mergeable,
+				BaseSHA:            n.BaseRefOid,
+				HeadSHA:            n.HeadRefOid,
+				Title:              n.Title,
+				Body:               n.Body,
+				CrossRepository:    n.IsCrossRepository,
+				HeadRef:            n.HeadRefName,
+			}
+			if n.Author != nil {
+				lite.Author, lite.AuthorType = n.Author.Login, n.Author.Typename
+			}
+			if n.MergeCommit != nil {
+				lite.MergeCommitSHA = n.MergeCommit.Oid
+			}
+			if eopts.IncludeAutoMerge {
+				if n.AutoMergeRequest != nil && n.AutoMergeRequest.EnabledBy != nil {
+					lite.AutoMergeEnabledBy = n.AutoMergeRequest.EnabledBy.Login
+				}
+				autoMerged := wasAutoMerged(n.State == "MERGED", n.AutoMergeEnabled.TotalCount, n.AutoMergeDisabled.TotalCount)
+				lite.AutoMerged = &autoMerged
+			}
+			lite.Labels = make([]string, 0, len(n.Labels.Nodes))
+			for _, l := range n.Labels.Nodes {
+				lite.Labels = append(lite.Labels, l.Name)
+			}
+			if n.Labels.TotalCount > len(n.Labels.Nodes) {
+				log.Warn().Str("owner", owner).Str("repo", repo).Int("number", n.Number).Int("labels", n.Labels.TotalCount).Int("kept", len(n.Labels.Nodes)).Msg("PR has more labels than fetched; storing the first ones")
+			}
+			for _, c := range n.Commits.Nodes {
+				if c.Commit.StatusCheckRollup != nil {
+					lite.Checks = checkEntries(c.Commit.StatusCheckRollup.Contexts.Nodes)
+				}
+			}
+			if eopts.IncludeCommits {
+				info := &CommitInfo{PRCommits: n.HeadCommit.TotalCount}
+				for _, c := range n.HeadCommit.Nodes {
+					info.HeadAuthoredAt = c.Commit.AuthoredDate
+				}
+				if n.MergeCommit != nil {
+					info.MergeParents = n.MergeCommit.Parents.TotalCount
+					info.MergeAuthoredAt = n.MergeCommit.AuthoredDate
+				}
+				lite.Commits = info
+			}
+			if eopts.IncludeDeployments {
+				lite.Deployments = []types.Deployment{}
+				if n.MergeCommit != nil {
+					for _, d := range n.MergeCommit.Deployments.Nodes {
+						lite.Deployments = append(lite.Deployments, types.Deployment{Environment: d.Environment, CreatedAt: d.CreatedAt})
+					}
+				}
+			}
+			if eopts.IncludeTimeline {
+				events := n.ReviewRequested.TotalCount + n.ReviewRequestRemoved.TotalCount
+				lite.ReviewRequestEvents = &events
+				for _, e := range n.ReviewRequested.Nodes {
+					t := e.ReviewRequestedEvent.CreatedAt
+					lite.FirstReviewRequestAt = &t
+				}
 			}
-			// exp backoff with jitter
-			base := time.Duration(500*(1<<uint(attempt-1))) * time.Millisecond
-			if base > 10*time.Second {
-				base = 10 * time.Second
+			if eopts.IncludeReviewThreads {
+				resolved, unresolved := n.ReviewThreads.countThreads()
+				lite.ResolvedThreads, lite.UnresolvedThreads = &resolved, &unresolved
+				if n.ReviewThreads.PageInfo.HasNextPage {
+					lite.ReviewThreadsCursor = string(n.ReviewThreads.PageInfo.EndCursor)
+				}
 			}
-			sleepFor := base + time.Duration(int64(time.Millisecond)*int64(100*attempt))
-			log.Warn().Int("attempt", attempt).Dur("sleep_for", sleepFor).Msg("GraphQL transient error; backing off")
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(sleepFor):
+			if eopts.IncludeReviewCounts {
+				lite.ReviewCounts = &ReviewCounts{
+					Approved:         n.ApprovedReviews.TotalCount,
+					ChangesRequested: n.ChangesRequestedReviews.TotalCount,
+					Commented:        n.CommentedReviews.TotalCount,
+				}
 			}
+			if eopts.IncludeReviewers {
+				logins := make([]string, 0, len(n.Reviews.Nodes))
+				lite.ReviewSubmissions = []time.Time{}
+				for _, r := range n.Reviews.Nodes {
+					if r.Author != nil {
+						logins = append(logins, r.Author.Login)
+					}
+					if r.SubmittedAt != nil && (r.Author == nil || !strings.EqualFold(r.Author.Login, lite.Author)) {
+						lite.ReviewSubmissions = append(lite.ReviewSubmissions, *r.SubmittedAt)
+					}
+				}
+				lite.Reviewers = reviewerLogins(logins, lite.Author)
+				lite.ReviewsTruncated = n.Reviews.TotalCount > len(n.Reviews.Nodes)
+			}
+			results = append(results, lite)
 		}
-		for _, n := range q.Repository.PullRequests.Nodes {
-			results = append(results, PRLite{
-				Number:    n.Number,
-				Additions: n.Additions,
-				Deletions: n.Deletions,
-				State:     n.State,
-				CreatedAt: n.CreatedAt,
-			})
+		if end := string(q.Repository.PullRequests.PageInfo.EndCursor); end != "" {
+			cursor = end
+		}
+		if reachedBound {
+			log.Info().Str("owner", owner).Str("repo", repo).Int("since_pr_number", eopts.SincePRNumber).Time("created_after", eopts.CreatedAfter).Msg("reached PR bound; stopping enumeration")
+			break
 		}
 		if !q.Repository.PullRequests.PageInfo.HasNextPage {
 			break
@@ -320,27 +868,109 @@ func GetAllPRsGraphQL(ctx context.Context, owner, repo string) ([]PRLite, error)
 		vars["cursor"] = q.Repository.PullRequests.PageInfo.EndCursor
 	}
 
-	log.Info().Str("owner", owner).Str("repo", repo).Int("total", len(results)).Msg("GraphQL fetched PR lites")
-	return results, nil
+	log.Info().Str("owner", owner).Str("repo", repo).Int("total", len(results)).Int("graphql_cost", totalCost).Msg("GraphQL fetched PR lites")
+	return results, cursor, nil
+}
+
+// LitesFromREST converts REST list results into PRLites. The list endpoint
+// omits diff stats, so callers must fetch each PR to fill in line counts.
+func LitesFromREST(prs []*github.PullRequest) []PRLite {
+	lites := make([]PRLite, 0, len(prs))
+	for _, pr := range prs {
+		// Match the GraphQL state enum, which reports merges distinctly.
+		state := strings.ToUpper(pr.GetState())
+		if pr.MergedAt != nil {
+			state = "MERGED"
+		}
+		lites = append(lites, PRLite{
+			NodeID:          pr.GetNodeID(),
+			Number:          pr.GetNumber(),
+			State:           state,
+			CreatedAt:       pr.GetCreatedAt().Time,
+			UpdatedAt:       pr.GetUpdatedAt().Time,
+			ClosedAt:        pr.ClosedAt.GetTime(),
+			MergedAt:        pr.MergedAt.GetTime(),
+			Author:          pr.GetUser().GetLogin(),
+			AuthorType:      pr.GetUser().GetType(),
+			Labels:          LabelNames(pr.Labels),
+			CrossRepository: IsCrossRepository(pr),
+			HeadRef:         pr.GetHead().GetRef(),
+			BaseRef:         pr.GetBase().GetRef(),
+			BaseSHA:         pr.GetBase().GetSHA(),
+			HeadSHA:         pr.GetHead().GetSHA(),
+			Title:           pr.GetTitle(),
+			Body:            pr.GetBody(),
+		})
+	}
+	return lites
+}
+
+// LabelNames returns the names of a REST PR's labels, empty (not nil) when
+// it has none.
+func LabelNames(labels []*github.Label) []string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.GetName())
+	}
+	return names
+}
+
+// IsCrossRepository reports whether a REST PR was opened from another repo.
+// The head repo is missing once a fork is deleted, and only forks can be.
+func IsCrossRepository(pr *github.PullRequest) bool {
+	head := pr.GetHead().GetRepo()
+	return head == nil || head.GetID() != pr.GetBase().GetRepo().GetID()
+}
+
+// isAuthor reports whether u is the PR author. Logins are case-insensitive.
+func isAuthor(u *github.User, author string) bool {
+	if author == "" || u == nil {
+		return false
+	}
+	return strings.EqualFold(u.GetLogin(), author)
+}
+
+// IsGraphQLUnavailable reports whether err means the GraphQL endpoint could
+// not be reached or refused the request outright (e.g. blocked by a proxy),
+// as opposed to the query itself failing. REST may still work in that case.
+func IsGraphQLUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isNetworkError(err) {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && !statusErr.RateLimited
+}
+
+// isNetworkError reports whether err came from the HTTP transport rather than
+// from a response.
+func isNetworkError(err error) bool {
+	var statusErr *StatusError
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrBudgetExhausted) || errors.As(err, &statusErr) {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
 }
 
-// GetPRCommentsBreakdown returns total and bot comment counts for a PR by
-// fetching issue comments and review comments with pagination and robust
-// backoff handling.
-func GetPRCommentsBreakdown(ctx context.Context, owner, repo string, number int) (CommentsBreakdown, error) {
+// GetPRCommentsBreakdown returns total, bot, and author comment counts for a
+// PR by fetching issue comments and review comments with pagination and
+// robust backoff handling. author is the PR author's login; comments are
+// never attributed to an empty author.
+func GetPRCommentsBreakdown(ctx context.Context, owner, repo string, number int, pr PRRef, copts CommentOptions) (CommentsBreakdown, error) {
 	if GitHubClient == nil {
 		return CommentsBreakdown{}, errors.New("GitHub client not initialized")
 	}
 
 	var breakdown CommentsBreakdown
+	// A PR's comment pages rarely change between runs, so re-scrapes
+	// revalidate them cheaply with -etag-cache.
+	ctx = withConditional(ctx)
 
-	// Helper to determine if a comment user is a bot
-	isBot := func(u *github.User) bool {
-		if u == nil || u.Type == nil {
-			return false
-		}
-		return *u.Type == "Bot"
-	}
+	isBot := copts.IsBot
+	logger := log.With().Int("number", number).Logger()
 
 	// Paginate Issue Comments (a.k.a. PR comments on the conversation tab)
 	issueOpts := &github.IssueListCommentsOptions{
@@ -352,61 +982,33 @@ func GetPRCommentsBreakdown(ctx context.Context, owner, repo string, number int)
 			resp     *github.Response
 			err      error
 		)
-		for {
+		err = withBackoff(ctx, logger, "listing issue comments", func() (*github.Response, error) {
 			comments, resp, err = GitHubClient.Issues.ListComments(ctx, owner, repo, number, issueOpts)
-			if err == nil {
+			return resp, err
+		})
+		if err != nil {
+			if paginationLimited(resp, issueOpts.Page) {
+				breakdown.Truncated = true
 				break
 			}
-			if rlErr, ok := err.(*github.RateLimitError); ok {
-				resetAt := rlErr.Rate.Reset.Time
-				sleepFor := time.Until(resetAt) + time.Second
-				if sleepFor < 0 {
-					sleepFor = 5 * time.Second
-				}
-				log.Warn().Int("number", number).Time("reset_at", resetAt).Dur("sleep_for", sleepFor).Msg("rate limit while listing issue comments; sleeping")
-				select {
-				case <-ctx.Done():
-					return CommentsBreakdown{}, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			if abuseErr, ok := err.(*github.AbuseRateLimitError); ok {
-				var sleepFor time.Duration
-				if abuseErr.RetryAfter != nil {
-					sleepFor = *abuseErr.RetryAfter
-				} else {
-					sleepFor = 10 * time.Second
-				}
-				log.Warn().Int("number", number).Dur("sleep_for", sleepFor).Msg("abuse while listing issue comments; backing off")
-				select {
-				case <-ctx.Done():
-					return CommentsBreakdown{}, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			if resp != nil && resp.Response != nil && resp.Response.StatusCode >= 500 {
-				log.Warn().Int("number", number).Int("status", resp.Response.StatusCode).Msg("server error listing issue comments; retrying")
-				select {
-				case <-ctx.Done():
-					return CommentsBreakdown{}, ctx.Err()
-				case <-time.After(3 * time.Second):
-				}
-				continue
-			}
 			return CommentsBreakdown{}, err
 		}
 		for _, c := range comments {
-			breakdown.TotalComments++
+			breakdown.addComment(c.User.GetLogin(), false, copts.ByLogin)
 			if isBot(c.User) {
-				breakdown.BotComments++
+				breakdown.addBot(c.User.GetLogin())
+			}
+			if isAuthor(c.User, pr.Author) {
+				breakdown.AuthorComments++
 			}
+			breakdown.addTiming(pr.CreatedAt, c.GetCreatedAt().Time)
+			copts.score(&breakdown, c.User, c.GetBody())
 		}
-		if resp == nil || resp.NextPage == 0 {
+		next := nextPage(resp)
+		if next == 0 {
 			break
 		}
-		issueOpts.Page = resp.NextPage
+		issueOpts.Page = next
 	}
 
 	// Paginate Review Comments (comments on diffs)
@@ -417,95 +1019,103 @@ func GetPRCommentsBreakdown(ctx context.Context, owner, repo string, number int)
 			resp     *github.Response
 			err      error
 		)
-		for {
+		err = withBackoff(ctx, logger, "listing review comments", func() (*github.Response, error) {
 			comments, resp, err = GitHubClient.PullRequests.ListComments(ctx, owner, repo, number, reviewOpts)
-			if err == nil {
+			return resp, err
+		})
+		if err != nil {
+			if paginationLimited(resp, reviewOpts.Page) {
+				breakdown.Truncated = true
 				break
 			}
-			if rlErr, ok := err.(*github.RateLimitError); ok {
-				resetAt := rlErr.Rate.Reset.Time
-				sleepFor := time.Until(resetAt) + time.Second
-				if sleepFor < 0 {
-					sleepFor = 5 * time.Second
-				}
-				log.Warn().Int("number", number).Time("reset_at", resetAt).Dur("sleep_for", sleepFor).Msg("rate limit while listing review comments; sleeping")
-				select {
-				case <-ctx.Done():
-					return CommentsBreakdown{}, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			if abuseErr, ok := err.(*github.AbuseRateLimitError); ok {
-				var sleepFor time.Duration
-				if abuseErr.RetryAfter != nil {
-					sleepFor = *abuseErr.RetryAfter
-				} else {
-					sleepFor = 10 * time.Second
-				}
-				log.Warn().Int("number", number).Dur("sleep_for", sleepFor).Msg("abuse while listing review comments; backing off")
-				select {
-				case <-ctx.Done():
-					return CommentsBreakdown{}, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			if resp != nil && resp.Response != nil && resp.Response.StatusCode >= 500 {
-				log.Warn().Int("number", number).Int("status", resp.Response.StatusCode).Msg("server error listing review comments; retrying")
-				select {
-				case <-ctx.Done():
-					return CommentsBreakdown{}, ctx.Err()
-				case <-time.After(3 * time.Second):
-				}
-				continue
-			}
 			return CommentsBreakdown{}, err
 		}
 		for _, c := range comments {
-			breakdown.TotalComments++
+			breakdown.addComment(c.User.GetLogin(), true, copts.ByLogin)
 			if isBot(c.User) {
-				breakdown.BotComments++
+				breakdown.addBot(c.User.GetLogin())
+			}
+			if isAuthor(c.User, pr.Author) {
+				breakdown.AuthorComments++
 			}
+			breakdown.addTiming(pr.CreatedAt, c.GetCreatedAt().Time)
+			copts.score(&breakdown, c.User, c.GetBody())
 		}
-		if resp == nil || resp.NextPage == 0 {
+		next := nextPage(resp)
+		if next == 0 {
 			break
 		}
-		reviewOpts.Page = resp.NextPage
+		reviewOpts.Page = next
 	}
 
+	if breakdown.Truncated {
+		log.Warn().Str("owner", owner).Str("repo", repo).Int("number", number).Int("counted", breakdown.TotalComments).Msg("GitHub stopped paginating comments; counts are incomplete")
+	}
 	return breakdown, nil
 }
 
 // GetRepoCommentsBreakdown aggregates comment counts for all PRs in the given
 // set by scanning repository-level endpoints, drastically reducing request
-// volume compared to per-PR calls. If prNumberSet is nil or empty, all
-// comments will be scanned but none will be recorded.
-func GetRepoCommentsBreakdown(ctx context.Context, owner, repo string, prNumberSet map[int]struct{}) (map[int]CommentsBreakdown, error) {
+// volume compared to per-PR calls. prs maps each PR number to record to its
+// author and creation time, used to count the author's own comments and
+// early comments. If prs is nil or empty, all comments will be scanned but
+// none will be recorded.
+//
+// GitHub stops paginating a listing after a few hundred pages. When it
+// does, the breakdowns gathered so far are returned with a
+// *CommentsTruncatedError: they are complete for PRs last updated before
+// its Cutoff.
+func GetRepoCommentsBreakdown(ctx context.Context, owner, repo string, prs map[int]PRRef, copts CommentOptions) (map[int]CommentsBreakdown, error) {
 	if GitHubClient == nil {
 		return nil, errors.New("GitHub client not initialized")
 	}
 
 	breakdowns := make(map[int]CommentsBreakdown)
 
-	// Helper to determine if a comment user is a bot
-	isBot := func(u *github.User) bool {
-		if u == nil || u.Type == nil {
-			return false
+	isBot := copts.IsBot
+	logger := log.With().Str("owner", owner).Str("repo", repo).Logger()
+	// Comment pages are oldest first, so on a re-scrape all but the last
+	// few are usually unchanged.
+	ctx = withConditional(ctx)
+
+	// Both endpoints filter by update time, so edits of older comments
+	// show up too; record skips those by creation time.
+	// Oldest first, so a truncated listing has seen every comment created
+	// before the last one it got.
+	since := "&sort=created&direction=asc"
+	if !copts.Since.IsZero() {
+		since += "&since=" + url.QueryEscape(copts.Since.UTC().Format(time.RFC3339))
+	}
+
+	var truncated *CommentsTruncatedError
+	// latest is the creation time of the newest comment of the listing
+	// being paged through.
+	var latest time.Time
+	truncate := func(listing string, page int) {
+		if truncated == nil || latest.Before(truncated.Cutoff) {
+			truncated = &CommentsTruncatedError{Listing: listing, Page: page, Cutoff: latest}
 		}
-		return *u.Type == "Bot"
 	}
 
 	// Helper to record counts for a PR
-	record := func(prNumber int, bot bool) {
-		if _, ok := prNumberSet[prNumber]; !ok {
+	record := func(prNumber int, u *github.User, at time.Time, body string, review bool) {
+		if at.After(latest) {
+			latest = at
+		}
+		pr, ok := prs[prNumber]
+		if !ok || at.Before(pr.CountSince) {
 			return
 		}
 		bd := breakdowns[prNumber]
-		bd.TotalComments++
-		if bot {
-			bd.BotComments++
+		bd.addComment(u.GetLogin(), review, copts.ByLogin)
+		if isBot(u) {
+			bd.addBot(u.GetLogin())
+		}
+		if isAuthor(u, pr.Author) {
+			bd.AuthorComments++
 		}
+		bd.addTiming(pr.CreatedAt, at)
+		copts.score(&bd, u, body)
 		breakdowns[prNumber] = bd
 	}
 
@@ -543,73 +1153,27 @@ func GetRepoCommentsBreakdown(ctx context.Context, owner, repo string, prNumberS
 		endpoint.WriteString(repo)
 		endpoint.WriteString("/issues/comments?per_page=100&page=")
 		endpoint.WriteString(strconv.Itoa(issPage))
+		endpoint.WriteString(since)
 
 		req, reqErr := GitHubClient.NewRequest("GET", endpoint.String(), nil)
 		if reqErr != nil {
 			return nil, reqErr
 		}
-		var comments []*github.IssueComment
-		// Retry wrapper for repo issue comments
-		var resp *github.Response
-		var doErr error
-		for attempt := 1; ; attempt++ {
-			resp, doErr = GitHubClient.Do(ctx, req, &comments)
-			if doErr == nil {
-				break
-			}
-			transient := strings.Contains(doErr.Error(), "502") || strings.Contains(doErr.Error(), "503") || strings.Contains(doErr.Error(), "504")
-			if !transient || attempt >= 6 {
+		var (
+			comments []*github.IssueComment
+			resp     *github.Response
+		)
+		err := withBackoff(ctx, logger, "listing repo issue comments", func() (*github.Response, error) {
+			var err error
+			resp, err = GitHubClient.Do(ctx, req, &comments)
+			return resp, err
+		})
+		if err != nil {
+			if paginationLimited(resp, issPage) {
+				truncate(owner+"/"+repo+" issue comments", issPage)
 				break
 			}
-			base := time.Duration(500*(1<<uint(attempt-1))) * time.Millisecond
-			if base > 10*time.Second {
-				base = 10 * time.Second
-			}
-			sleepFor := base + time.Duration(int64(time.Millisecond)*int64(100*attempt))
-			log.Warn().Int("attempt", attempt).Dur("sleep_for", sleepFor).Msg("transient 5xx for repo issue comments; backing off")
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(sleepFor):
-			}
-		}
-		if doErr != nil {
-			if rlErr, ok := doErr.(*github.RateLimitError); ok {
-				resetAt := rlErr.Rate.Reset.Time
-				sleepFor := time.Until(resetAt) + time.Second
-				if sleepFor < 0 {
-					sleepFor = 5 * time.Second
-				}
-				log.Warn().Dur("sleep_for", sleepFor).Msg("rate limit while listing repo issue comments; sleeping")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			if abuseErr, ok := doErr.(*github.AbuseRateLimitError); ok {
-				var sleepFor time.Duration
-				if abuseErr.RetryAfter != nil {
-					sleepFor = *abuseErr.RetryAfter
-				} else {
-					sleepFor = 10 * time.Second
-				}
-				log.Warn().Dur("sleep_for", sleepFor).Msg("abuse while listing repo issue comments; backing off")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			// Non-2xx or other errors; small backoff and retry
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(3 * time.Second):
-			}
-			continue
+			return nil, err
 		}
 		for _, c := range comments {
 			if c == nil || c.User == nil {
@@ -618,24 +1182,21 @@ func GetRepoCommentsBreakdown(ctx context.Context, owner, repo string, prNumberS
 			// Comment belongs to an issue number
 			if c.IssueURL != nil {
 				if n, ok := extractTrailingInt(*c.IssueURL); ok {
-					record(n, isBot(c.User))
+					record(n, c.User, c.GetCreatedAt().Time, c.GetBody(), false)
 				}
 			}
 		}
-		log.Info().Str("owner", owner).Str("repo", repo).Int("issue_comments_page", issPage).Int("fetched", len(comments)).Int("next_page", func() int {
-			if resp == nil {
-				return 0
-			}
-			return resp.NextPage
-		}()).Msg("fetched repo issue comments page")
-		if resp == nil || resp.NextPage == 0 {
+		next := nextPage(resp)
+		log.Info().Str("owner", owner).Str("repo", repo).Int("issue_comments_page", issPage).Int("fetched", len(comments)).Int("next_page", next).Msg("fetched repo issue comments page")
+		if next == 0 {
 			break
 		}
-		issPage = resp.NextPage
+		issPage = next
 	}
 
 	// 2) Repository-level Review Comments (code comments)
 	// Use a manual request as the go-github method for repo-level review comments may not be exposed.
+	latest = time.Time{}
 	revPage := 1
 	for {
 		endpoint := strings.Builder{}
@@ -645,75 +1206,28 @@ func GetRepoCommentsBreakdown(ctx context.Context, owner, repo string, prNumberS
 		endpoint.WriteString(repo)
 		endpoint.WriteString("/pulls/comments?per_page=100&page=")
 		endpoint.WriteString(strconv.Itoa(revPage))
+		endpoint.WriteString(since)
 
 		req, reqErr := GitHubClient.NewRequest("GET", endpoint.String(), nil)
 		if reqErr != nil {
 			return nil, reqErr
 		}
-		var comments []*github.PullRequestComment
-		// Retry wrapper for repo review comments
-		var resp *github.Response
-		var doErr error
-		for attempt := 1; ; attempt++ {
-			resp, doErr = GitHubClient.Do(ctx, req, &comments)
-			if doErr == nil {
-				break
-			}
-			transient := strings.Contains(doErr.Error(), "502") || strings.Contains(doErr.Error(), "503") || strings.Contains(doErr.Error(), "504")
-			if !transient || attempt >= 6 {
+		var (
+			comments []*github.PullRequestComment
+			resp     *github.Response
+		)
+		err := withBackoff(ctx, logger, "listing repo review comments", func() (*github.Response, error) {
+			var err error
+			resp, err = GitHubClient.Do(ctx, req, &comments)
+			return resp, err
+		})
+		if err != nil {
+			if paginationLimited(resp, revPage) {
+				truncate(owner+"/"+repo+" review comments", revPage)
 				break
 			}
-			base := time.Duration(500*(1<<uint(attempt-1))) * time.Millisecond
-			if base > 10*time.Second {
-				base = 10 * time.Second
-			}
-			sleepFor := base + time.Duration(int64(time.Millisecond)*int64(100*attempt))
-			log.Warn().Int("attempt", attempt).Dur("sleep_for", sleepFor).Msg("transient 5xx for repo review comments; backing off")
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(sleepFor):
-			}
-		}
-		if doErr != nil {
-			if rlErr, ok := doErr.(*github.RateLimitError); ok {
-				resetAt := rlErr.Rate.Reset.Time
-				sleepFor := time.Until(resetAt) + time.Second
-				if sleepFor < 0 {
-					sleepFor = 5 * time.Second
-				}
-				log.Warn().Dur("sleep_for", sleepFor).Msg("rate limit while listing repo review comments; sleeping")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			if abuseErr, ok := doErr.(*github.AbuseRateLimitError); ok {
-				var sleepFor time.Duration
-				if abuseErr.RetryAfter != nil {
-					sleepFor = *abuseErr.RetryAfter
-				} else {
-					sleepFor = 10 * time.Second
-				}
-				log.Warn().Dur("sleep_for", sleepFor).Msg("abuse while listing repo review comments; backing off")
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(sleepFor):
-				}
-				continue
-			}
-			// Non-2xx handled above; 5xx may not be parsed to Response; retry basic backoff
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(3 * time.Second):
-			}
-			continue
+			return nil, err
 		}
-
 		for _, c := range comments {
 			if c == nil || c.User == nil {
 				continue
@@ -728,21 +1242,388 @@ func GetRepoCommentsBreakdown(ctx context.Context, owner, repo string, prNumberS
 				prNumber, ok = extractTrailingInt(*c.HTMLURL)
 			}
 			if ok {
-				record(prNumber, isBot(c.User))
+				record(prNumber, c.User, c.GetCreatedAt().Time, c.GetBody(), true)
 			}
 		}
 
-		log.Info().Str("owner", owner).Str("repo", repo).Int("review_comments_page", revPage).Int("fetched", len(comments)).Int("next_page", func() int {
-			if resp == nil {
-				return 0
-			}
-			return resp.NextPage
-		}()).Msg("fetched repo review comments page")
-		if resp == nil || resp.NextPage == 0 {
+		next := nextPage(resp)
+		log.Info().Str("owner", owner).Str("repo", repo).Int("review_comments_page", revPage).Int("fetched", len(comments)).Int("next_page", next).Msg("fetched repo review comments page")
+		if next == 0 {
 			break
 		}
-		revPage = resp.NextPage
+		revPage = next
 	}
 
+	if truncated != nil {
+		return breakdowns, truncated
+	}
 	return breakdowns, nil
 }
+
+// GetPRFiles lists the files changed by a PR, paginating with the same
+// rate-limit and abuse backoff handling as the other per-PR fetches. GitHub
+// returns at most 3000 files per PR.
+func GetPRFiles(ctx context.Context, owner, repo string, number int) ([]*github.CommitFile, error) {
+	if GitHubClient == nil {
+		return nil, errors.New("GitHub client not initialized")
+	}
+
+	var all []*github.CommitFile
+	opts := &github.ListOptions{PerPage: 100, Page: 1}
+	for {
+		var (
+			files []*github.CommitFile
+			resp  *github.Response
+			err   error
+		)
+		err = withBackoff(ctx, log.With().Int("number", number).Logger(), "listing PR files", func() (*github.Response, error) {
+			files, resp, err = GitHubClient.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, files...)
+		next := nextPage(resp)
+		if next == 0 {
+			break
+		}
+		opts.Page = next
+	}
+	return all, nil
+}
+
+// FileStatusCounts tallies a PR's changed files by status.
+type FileStatusCounts struct {
+	Added    int
+	Modified int
+	Removed  int
+}
+
+// CountFileStatuses tallies files by their REST status. Renamed, copied, and
+// changed files count as modified since the file survives under some path.
+func CountFileStatuses(files []*github.CommitFile) FileStatusCounts {
+	var c FileStatusCounts
+	for _, f := range files {
+		switch f.GetStatus() {
+		case "added":
+			c.Added++
+		case "removed":
+			c.Removed++
+		case "modified", "renamed", "copied", "changed":
+			c.Modified++
+		}
+	}
+	return c
+}
+
+// MaxFileTypes caps how many extensions CountFileTypes reports; the rest are
+// folded into FileTypeOther.
+const MaxFileTypes = 10
+
+const (
+	// FileTypeNone counts files without an extension, e.g. Makefile.
+	FileTypeNone = "(none)"
+	// FileTypeOther counts files of extensions beyond the top MaxFileTypes.
+	FileTypeOther = "(other)"
+)
+
+// CountFileTypes tallies files by lowercased extension (".go", ".md"),
+// keeping the limit most common ones and folding the rest into
+// FileTypeOther, so the counts always sum to len(files). Ties are broken
+// alphabetically. Dotfiles count under their full name (".gitignore").
+func CountFileTypes(files []*github.CommitFile, limit int) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range files {
+		ext := strings.ToLower(path.Ext(f.GetFilename()))
+		if ext == "" {
+			ext = FileTypeNone
+		}
+		counts[ext]++
+	}
+	if len(counts) <= limit {
+		return counts
+	}
+
+	exts := make([]string, 0, len(counts))
+	for ext := range counts {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if counts[exts[i]] != counts[exts[j]] {
+			return counts[exts[i]] > counts[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+	top := make(map[string]int, limit+1)
+	for i, ext := range exts {
+		if i < limit {
+			top[ext] = counts[ext]
+		} else {
+			top[FileTypeOther] += counts[ext]
+		}
+	}
+	return top
+}
+
+// GetCommitInfo fills a CommitInfo for a PR fetched over REST, looking up the
+// merge and head commits of merged PRs (two extra requests each).
+func GetCommitInfo(ctx context.Context, owner, repo string, pr *github.PullRequest) (CommitInfo, error) {
+	info := CommitInfo{PRCommits: pr.GetCommits()}
+	if !pr.GetMerged() || pr.GetMergeCommitSHA() == "" {
+		return info, nil
+	}
+	merge, err := getCommitWithBackoff(ctx, owner, repo, pr.GetMergeCommitSHA())
+	if err != nil {
+		return info, err
+	}
+	head, err := getCommitWithBackoff(ctx, owner, repo, pr.GetHead().GetSHA())
+	if err != nil {
+		return info, err
+	}
+	info.MergeParents = len(merge.Parents)
+	info.MergeAuthoredAt = merge.GetAuthor().GetDate().Time
+	info.HeadAuthoredAt = head.GetAuthor().GetDate().Time
+	return info, nil
+}
+
+// getCommitWithBackoff fetches a git commit, retrying on rate limits and
+// server errors like the other REST fetches.
+func getCommitWithBackoff(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+	if GitHubClient == nil {
+		return nil, errors.New("GitHub client not initialized")
+	}
+
+	var commit *github.Commit
+	err := withBackoff(ctx, log.With().Str("sha", sha).Logger(), "fetching commit", func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		commit, resp, err = GitHubClient.Git.GetCommit(ctx, owner, repo, sha)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+// GetDeployments lists up to 10 deployments of a commit over REST, matching
+// what the GraphQL enumeration fetches. It returns an empty slice for
+// commits without deployments.
+func GetDeployments(ctx context.Context, owner, repo, sha string) ([]types.Deployment, error) {
+	if GitHubClient == nil {
+		return nil, errors.New("GitHub client not initialized")
+	}
+
+	opts := &github.DeploymentsListOptions{SHA: sha, ListOptions: github.ListOptions{PerPage: 10}}
+	var deps []*github.Deployment
+	err := withBackoff(ctx, log.With().Str("sha", sha).Logger(), "listing deployments", func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		deps, resp, err = GitHubClient.Repositories.ListDeployments(ctx, owner, repo, opts)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.Deployment, 0, len(deps))
+	for _, d := range deps {
+		out = append(out, types.Deployment{Environment: d.GetEnvironment(), CreatedAt: d.GetCreatedAt().Time})
+	}
+	return out, nil
+}
+
+// reviewerLogins deduplicates review authors case-insensitively, keeping the
+// order of their first review and dropping the PR author's own replies.
+func reviewerLogins(logins []string, author string) []string {
+	out := make([]string, 0, len(logins))
+	seen := make(map[string]bool, len(logins))
+	for _, l := range logins {
+		key := strings.ToLower(l)
+		if l == "" || seen[key] || strings.EqualFold(l, author) {
+			continue
+		}
+		seen[key] = true
+		out = append(out, l)
+	}
+	return out
+}
+
+// ReviewersOf returns who submitted reviews, as reviewerLogins does.
+func ReviewersOf(reviews []*github.PullRequestReview, author string) []string {
+	logins := make([]string, 0, len(reviews))
+	for _, r := range reviews {
+		logins = append(logins, r.GetUser().GetLogin())
+	}
+	return reviewerLogins(logins, author)
+}
+
+// ReviewSubmissions returns when reviews by anyone but author were
+// submitted, skipping pending ones.
+func ReviewSubmissions(reviews []*github.PullRequestReview, author string) []time.Time {
+	var out []time.Time
+	for _, r := range reviews {
+		if r.SubmittedAt == nil || strings.EqualFold(r.GetUser().GetLogin(), author) {
+			continue
+		}
+		out = append(out, r.SubmittedAt.Time)
+	}
+	return out
+}
+
+// GetPRReviews lists all of a PR's reviews over REST, paging through them,
+// for when the GraphQL enumeration is unavailable.
+func GetPRReviews(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	if GitHubClient == nil {
+		return nil, errors.New("GitHub client not initialized")
+	}
+
+	var all []*github.PullRequestReview
+	opts := &github.ListOptions{PerPage: 100, Page: 1}
+	for {
+		var (
+			reviews []*github.PullRequestReview
+			resp    *github.Response
+		)
+		err := withBackoff(ctx, log.With().Int("number", number).Logger(), "listing reviews", func() (*github.Response, error) {
+			var err error
+			reviews, resp, err = GitHubClient.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, reviews...)
+		next := nextPage(resp)
+		if next == 0 {
+			return all, nil
+		}
+		opts.Page = next
+	}
+}
+
+// GetPRReviewCounts counts a PR's reviews by state over REST, paging
+// through all of them, for when the GraphQL enumeration is unavailable.
+func GetPRReviewCounts(ctx context.Context, owner, repo string, number int) (ReviewCounts, error) {
+	if GitHubClient == nil {
+		return ReviewCounts{}, errors.New("GitHub client not initialized")
+	}
+
+	var counts ReviewCounts
+	opts := &github.ListOptions{PerPage: 100, Page: 1}
+	for {
+		var (
+			reviews []*github.PullRequestReview
+			resp    *github.Response
+		)
+		err := withBackoff(ctx, log.With().Int("number", number).Logger(), "listing reviews", func() (*github.Response, error) {
+			var err error
+			reviews, resp, err = GitHubClient.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+			return resp, err
+		})
+		if err != nil {
+			return ReviewCounts{}, err
+		}
+		for _, r := range reviews {
+			switch r.GetState() {
+			case "APPROVED":
+				counts.Approved++
+			case "CHANGES_REQUESTED":
+				counts.ChangesRequested++
+			case "COMMENTED":
+				counts.Commented++
+			}
+		}
+		next := nextPage(resp)
+		if next == 0 {
+			return counts, nil
+		}
+		opts.Page = next
+	}
+}
+
+// CountReviewRequestEvents counts review_requested and
+// review_request_removed events, i.e. reviewer churn, in a PR's timeline.
+func CountReviewRequestEvents(events []*github.Timeline) int {
+	n := 0
+	for _, e := range events {
+		switch e.GetEvent() {
+		case "review_requested", "review_request_removed":
+			n++
+		}
+	}
+	return n
+}
+
+// AutoMerged reports whether a PR with the given REST timeline was merged
+// by auto-merge.
+func AutoMerged(merged bool, events []*github.Timeline) bool {
+	enabled, disabled := 0, 0
+	for _, e := range events {
+		switch e.GetEvent() {
+		case "auto_merge_enabled", "auto_squash_enabled", "auto_rebase_enabled":
+			enabled++
+		case "auto_merge_disabled":
+			disabled++
+		}
+	}
+	return wasAutoMerged(merged, enabled, disabled)
+}
+
+// wasAutoMerged reports whether auto-merge was still on when a PR merged:
+// it was enabled more often than it was turned off again. GitHub clears a
+// PR's auto-merge request once it merges, so only the events tell.
+func wasAutoMerged(merged bool, enabled, disabled int) bool {
+	return merged && enabled > disabled
+}
+
+// FirstReviewRequest returns when a reviewer was first requested in a
+// PR's timeline, or nil if none ever was.
+func FirstReviewRequest(events []*github.Timeline) *time.Time {
+	var first *time.Time
+	for _, e := range events {
+		if e.GetEvent() != "review_requested" || e.CreatedAt == nil {
+			continue
+		}
+		if t := e.CreatedAt.Time; first == nil || t.Before(*first) {
+			first = &t
+		}
+	}
+	return first
+}
+
+// GetPRTimeline lists a PR's timeline events over REST, paginating with the
+// usual backoff handling.
+func GetPRTimeline(ctx context.Context, owner, repo string, number int) ([]*github.Timeline, error) {
+	if GitHubClient == nil {
+		return nil, errors.New("GitHub client not initialized")
+	}
+
+	var all []*github.Timeline
+	opts := &github.ListOptions{PerPage: 100, Page: 1}
+	for {
+		var (
+			events []*github.Timeline
+			resp   *github.Response
+			err    error
+		)
+		err = withBackoff(ctx, log.With().Int("number", number).Logger(), "listing PR timeline", func() (*github.Response, error) {
+			events, resp, err = GitHubClient.Issues.ListIssueTimeline(ctx, owner, repo, number, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, events...)
+		next := nextPage(resp)
+		if next == 0 {
+			break
+		}
+		opts.Page = next
+	}
+	return all, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddTiming(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var b CommentsBreakdown
+	for _, at := range []time.Time{
+		created.Add(time.Minute),                // day and week
+		created.Add(23*time.Hour + time.Minute), // day and week
+		created.Add(24 * time.Hour),             // week only: the day window is exclusive
+		created.Add(6 * 24 * time.Hour),         // week only
+		created.Add(7 * 24 * time.Hour),         // neither
+		created.Add(30 * 24 * time.Hour),        // neither
+		{},                                      // unknown time: not counted
+	} {
+		b.addTiming(created, at)
+	}
+	if b.FirstDayComments != 2 || b.FirstWeekComments != 4 {
+		t.Errorf("first day %d, first week %d; want 2 and 4", b.FirstDayComments, b.FirstWeekComments)
+	}
+
+	var unknown CommentsBreakdown
+	unknown.addTiming(time.Time{}, created)
+	if unknown.FirstDayComments != 0 || unknown.FirstWeekComments != 0 {
+		t.Errorf("counted a comment on a PR of unknown creation time: %+v", unknown)
+	}
+}
+
+func TestAddCommentByLogin(t *testing.T) {
+	var plain, byLogin CommentsBreakdown
+	for _, login := range []string{"alice", "bob", "alice"} {
+		plain.addComment(login, false, false)
+		byLogin.addComment(login, true, true)
+	}
+	if plain.TotalComments != 3 || plain.IssueComments != 3 || plain.ByLogin != nil {
+		t.Errorf("without byLogin: %+v, want 3 issue comments and no ByLogin map", plain)
+	}
+	if byLogin.ReviewComments != 3 || byLogin.ByLogin["alice"] != 2 || byLogin.ByLogin["bob"] != 1 {
+		t.Errorf("with byLogin: %+v", byLogin)
+	}
+}
+
+// testGitHub points GitHubClient and GitHubGraphQLClient at a test server
+// running handler, as a GitHub Enterprise Server, and restores the previous
+// clients afterwards. REST requests arrive under /api/v3/ and GraphQL
+// queries at /api/graphql.
+func TestReviewersBeyondFirstHundred(t *testing.T) {
+	const total = 150
+	// reviews lists reviews from to to in the GraphQL or the REST shape.
+	reviews := func(from, to int, graphQL bool) string {
+		var out []string
+		for i := from; i < to; i++ {
+			if graphQL {
+				out = append(out, fmt.Sprintf(`{"author": {"login": "r%d"}, "submittedAt": "2024-01-02T00:00:00Z"}`, i))
+			} else {
+				out = append(out, fmt.Sprintf(`{"user": {"login": "r%d"}, "state": "COMMENTED", "submitted_at": "2024-01-02T00:00:00Z"}`, i))
+			}
+		}
+		return "[" + strings.Join(out, ", ") + "]"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data": {"rateLimit": {"cost": 1, "remaining": 4999}, "repository": {"pullRequests": {"totalCount": 1,
+			"pageInfo": {"hasNextPage": false, "endCursor": null}, "nodes": [{"number": 1, "state": "OPEN",
+			"createdAt": "2024-01-01T00:00:00Z", "updatedAt": "2024-01-02T00:00:00Z", "labels": {"nodes": []},
+			"reviews": {"totalCount": %d, "nodes": %s}}]}}}}`, total, reviews(0, 100, true))
+	})
+	mux.HandleFunc("/api/v3/repos/octo/demo/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, reviews(100, total, false))
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/repos/octo/demo/pulls/1/reviews?page=2>; rel="next"`, r.Host))
+		fmt.Fprint(w, reviews(0, 100, false))
+	})
+	testGitHub(t, mux)
+	ctx := context.Background()
+
+	prs, _, err := GetAllPRsGraphQL(ctx, "octo", "demo", EnumerateOptions{IncludeReviewers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 1 || !prs[0].ReviewsTruncated || len(prs[0].Reviewers) != 100 {
+		t.Fatalf("bulk query: got %d PRs, want one with its first 100 reviewers and ReviewsTruncated", len(prs))
+	}
+	all, err := GetPRReviews(ctx, "octo", "demo", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ReviewersOf(all, "alice"); len(got) != total {
+		t.Errorf("listed %d reviewers, want %d", len(got), total)
+	}
+	if got := ReviewSubmissions(all, "alice"); len(got) != total {
+		t.Errorf("listed %d review submissions, want %d", len(got), total)
+	}
+}
+
+func testGitHub(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	prev, prevGraphQL := GitHubClient, GitHubGraphQLClient
+	t.Cleanup(func() { GitHubClient, GitHubGraphQLClient = prev, prevGraphQL })
+	t.Setenv("GITHUB_TOKENS", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GITHUB_BASE_URL", srv.URL+"/")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+	if err := InitGitHub(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitGitHubGraphQL(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog/log"
+)
+
+// nextPage returns the page to request after resp, or 0 when there is none.
+// go-github fills resp.NextPage from the Link header on every Do call, but it
+// only reads the first Link header value and skips rel="next" links whose
+// page isn't an integer. When it came up empty, the Link headers are parsed
+// here as well so a missed next page doesn't silently cut a listing short.
+func nextPage(resp *github.Response) int {
+	if resp == nil {
+		return 0
+	}
+	if resp.NextPage != 0 || resp.Response == nil {
+		return resp.NextPage
+	}
+	next, ok := linkNext(resp.Header.Values("Link"))
+	if !ok {
+		return 0
+	}
+	page, err := strconv.Atoi(next.Query().Get("page"))
+	if err != nil || page < 1 {
+		log.Warn().Str("next", next.String()).Msg("Link header has a next page without a usable page number; stopping pagination")
+		return 0
+	}
+	return page
+}
+
+// linkNext finds the rel="next" target across all Link header values.
+func linkNext(headers []string) (*url.URL, bool) {
+	for _, h := range headers {
+		for _, link := range strings.Split(h, ",") {
+			segments := strings.Split(strings.TrimSpace(link), ";")
+			if len(segments) < 2 {
+				continue
+			}
+			target := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, seg := range segments[1:] {
+				if strings.TrimSpace(seg) != `rel="next"` {
+					continue
+				}
+				u, err := url.Parse(target[1 : len(target)-1])
+				if err != nil {
+					return nil, false
+				}
+				return u, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestNextPageReadsEveryLinkHeader(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://api.github.com/repos/o/r/pulls/1/files?page=1>; rel="prev"`)
+	header.Add("Link", `<https://api.github.com/repos/o/r/pulls/1/files?page=3>; rel="next", <https://api.github.com/repos/o/r/pulls/1/files?page=9>; rel="last"`)
+	resp := &github.Response{Response: &http.Response{Header: header}}
+	if got := nextPage(resp); got != 3 {
+		t.Errorf("nextPage = %d, want 3", got)
+	}
+
+	resp.NextPage = 5
+	if got := nextPage(resp); got != 5 {
+		t.Errorf("nextPage = %d, want go-github's 5", got)
+	}
+
+	bad := http.Header{"Link": {`<https://api.github.com/x?cursor=abc>; rel="next"`}}
+	if got := nextPage(&github.Response{Response: &http.Response{Header: bad}}); got != 0 {
+		t.Errorf("nextPage without a page number = %d, want 0", got)
+	}
+}
+
+// TestPaginationFollowsSecondLinkHeader serves a listing whose next link
+// is only in a second Link header, which go-github does not read.
+func TestPaginationFollowsSecondLinkHeader(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/o/r/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Add("Link", `<http://example.invalid/?page=1>; rel="first"`)
+			w.Header().Add("Link", fmt.Sprintf(`<http://%s/api/v3/repos/o/r/pulls/1/files?page=2>; rel="next"`, r.Host))
+		}
+		fmt.Fprintf(w, `[{"filename": "page%s.go"}]`, page)
+	})
+	testGitHub(t, mux)
+
+	files, err := GetPRFiles(context.Background(), "o", "r", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[1].GetFilename() != "page2.go" {
+		t.Errorf("got %d files, want both pages", len(files))
+	}
+}
+
+func TestGetPRReviewsPaginates(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/o/r/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/repos/o/r/pulls/1/reviews?page=2>; rel="next"`, r.Host))
+		}
+		fmt.Fprintf(w, `[{"user": {"login": "reviewer%s"}, "state": "APPROVED"}]`, page)
+	})
+	testGitHub(t, mux)
+
+	reviews, err := GetPRReviews(context.Background(), "o", "r", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ReviewersOf(reviews, "author"); len(got) != 2 || got[1] != "reviewer2" {
+		t.Errorf("reviewers = %v, want both pages", got)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+	githubv4 "github.com/shurcooL/githubv4"
+)
+
+// BranchProtection is the protection rule covering a branch.
+type BranchProtection struct {
+	// Protected is false when no rule matches the branch; the other fields
+	// are then zero.
+	Protected                    bool
+	RequiresApprovingReviews     bool
+	RequiredApprovingReviewCount int
+}
+
+// branchProtectionQuery looks up the rule matching one branch.
+type branchProtectionQuery struct {
+	Repository struct {
+		Ref *struct {
+			BranchProtectionRule *struct {
+				RequiresApprovingReviews     bool
+				RequiredApprovingReviewCount *int
+			}
+		} `graphql:"ref(qualifiedName: $ref)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// protection maps the query result to BranchProtection; nil when the branch
+// no longer exists, so whether it was protected is unknown.
+func (q branchProtectionQuery) protection() *BranchProtection {
+	ref := q.Repository.Ref
+	if ref == nil {
+		return nil
+	}
+	if ref.BranchProtectionRule == nil {
+		return &BranchProtection{}
+	}
+	rule := ref.BranchProtectionRule
+	p := &BranchProtection{Protected: true, RequiresApprovingReviews: rule.RequiresApprovingReviews}
+	if rule.RequiredApprovingReviewCount != nil {
+		p.RequiredApprovingReviewCount = *rule.RequiredApprovingReviewCount
+	}
+	return p
+}
+
+// GetBranchProtection fetches the protection rule currently covering branch,
+// or nil when the branch no longer exists. Reading rules needs admin or
+// maintain access to the repo; without it GitHub answers with an error that
+// IsPermissionDenied recognizes.
+func GetBranchProtection(ctx context.Context, owner, repo, branch string) (*BranchProtection, error) {
+	if GitHubGraphQLClient == nil {
+		return nil, errors.New("GitHub GraphQL client not initialized")
+	}
+
+	var q branchProtectionQuery
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(repo),
+		"ref":   githubv4.String("refs/heads/" + branch),
+	}
+	logger := log.With().Str("owner", owner).Str("repo", repo).Str("branch", branch).Logger()
+	if err := queryWithRetry(ctx, logger, "reading branch protection", &q, vars); err != nil {
+		return nil, err
+	}
+
+	p := q.protection()
+	logger.Debug().Bool("known", p != nil).Bool("protected", p != nil && p.Protected).Msg("fetched branch protection")
+	return p, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetBranchProtection(t *testing.T) {
+	testGitHub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Variables["ref"] {
+		case "refs/heads/main":
+			fmt.Fprint(w, `{"data":{"repository":{"ref":{"branchProtectionRule":{"requiresApprovingReviews":true,"requiredApprovingReviewCount":2}}}}}`)
+		case "refs/heads/dev":
+			fmt.Fprint(w, `{"data":{"repository":{"ref":{"branchProtectionRule":null}}}}`)
+		case "refs/heads/gone":
+			fmt.Fprint(w, `{"data":{"repository":{"ref":null}}}`)
+		case "refs/heads/locked":
+			// What GitHub answers a token without admin or maintain access.
+			fmt.Fprint(w, `{"data":{"repository":{"ref":{"branchProtectionRule":null}}},"errors":[{"type":"FORBIDDEN","path":["repository","ref","branchProtectionRule"],"message":"Resource not accessible by integration"}]}`)
+		default:
+			t.Errorf("unexpected ref %v", req.Variables["ref"])
+		}
+	}))
+	ctx := context.Background()
+
+	tests := []struct {
+		branch string
+		want   *BranchProtection
+	}{
+		{"main", &BranchProtection{Protected: true, RequiresApprovingReviews: true, RequiredApprovingReviewCount: 2}},
+		{"dev", &BranchProtection{}},
+		{"gone", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			got, err := GetBranchProtection(ctx, "acme", "widgets", tt.branch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("GetBranchProtection(%s) = %+v, want %+v", tt.branch, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("permission denied", func(t *testing.T) {
+		_, err := GetBranchProtection(ctx, "acme", "widgets", "locked")
+		if !IsPermissionDenied(err) {
+			t.Errorf("GetBranchProtection error %v is not a permission error", err)
+		}
+	})
+}
+
+func TestErrorTypes(t *testing.T) {
+	tests := []struct{ body, want string }{
+		{`{"data":{}}`, ""},
+		{`{"errors":[{"type":"FORBIDDEN"},{"type":"FORBIDDEN"}]}`, "FORBIDDEN"},
+		{`{"errors":[{"type":"FORBIDDEN"},{"type":"NOT_FOUND"}]}`, ""},
+		{`{"errors":[{"type":"NOT_FOUND"},{"type":"RATE_LIMITED"}]}`, "RATE_LIMITED"},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if got := errorTypes([]byte(tt.body)); got != tt.want {
+			t.Errorf("errorTypes(%s) = %q, want %q", strings.TrimSpace(tt.body), got, tt.want)
+		}
+	}
+}
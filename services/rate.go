@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+var (
+	rateMu     sync.RWMutex
+	latestRate github.Rate
+	haveRate   bool
+)
+
+// recordRate stores the core rate-limit snapshot carried by a REST response.
+func recordRate(resp *github.Response) {
+	if resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+	rateMu.Lock()
+	latestRate = resp.Rate
+	haveRate = true
+	rateMu.Unlock()
+}
+
+// LatestRate returns the most recent core rate-limit snapshot observed on any
+// REST response. The boolean is false until a response has been seen.
+func LatestRate() (github.Rate, bool) {
+	rateMu.RLock()
+	defer rateMu.RUnlock()
+	return latestRate, haveRate
+}
+
+// PrintRateLimits fetches the current rate limits for the configured token
+// (with several, the one currently in use) and writes the core, search, and GraphQL budgets as an aligned table.
+// Checking the rate limit does not count against it.
+func PrintRateLimits(ctx context.Context, w io.Writer) error {
+	if GitHubClient == nil {
+		return errors.New("GitHub client not initialized")
+	}
+	limits, _, err := GitHubClient.RateLimit.Get(ctx)
+	if err != nil {
+		return err
+	}
+	return writeRateLimits(w, limits, len(Tokens()) > 0, time.Now())
+}
+
+func writeRateLimits(w io.Writer, limits *github.RateLimits, authenticated bool, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tLIMIT\tREMAINING\tUSED\tRESETS")
+	rows := []struct {
+		name string
+		rate *github.Rate
+	}{
+		{"core", limits.Core},
+		{"search", limits.Search},
+		{"graphql", limits.GraphQL},
+	}
+	for _, r := range rows {
+		// GraphQL is unavailable without a token, so the API omits it.
+		if r.rate == nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\n", r.name)
+			continue
+		}
+		reset := r.rate.Reset.Time
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s (in %s)\n", r.name, r.rate.Limit, r.rate.Remaining, r.rate.Used,
+			reset.Local().Format(time.Kitchen), reset.Sub(now).Round(time.Second))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if !authenticated {
+		_, err := fmt.Fprintln(w, "\nunauthenticated: limits are much lower and GraphQL is unavailable; set GITHUB_TOKEN to raise them")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func TestWriteRateLimits(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rate := func(limit, remaining int) *github.Rate {
+		return &github.Rate{Limit: limit, Remaining: remaining, Used: limit - remaining, Reset: github.Timestamp{Time: now.Add(30 * time.Minute)}}
+	}
+	tests := []struct {
+		name          string
+		limits        *github.RateLimits
+		authenticated bool
+		// want maps each resource to its LIMIT, REMAINING and USED columns.
+		want map[string]string
+		note bool
+	}{
+		{
+			name:          "token",
+			limits:        &github.RateLimits{Core: rate(5000, 4990), Search: rate(30, 30), GraphQL: rate(5000, 4000)},
+			authenticated: true,
+			want:          map[string]string{"core": "5000 4990 10", "search": "30 30 0", "graphql": "5000 4000 1000"},
+		},
+		{
+			name:   "anonymous",
+			limits: &github.RateLimits{Core: rate(60, 59), Search: rate(10, 10)},
+			want:   map[string]string{"core": "60 59 1", "search": "10 10 0", "graphql": "- - -"},
+			note:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeRateLimits(&buf, tt.limits, tt.authenticated, now); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, line := range strings.Split(buf.String(), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 4 && tt.want[fields[0]] != "" {
+					got[fields[0]] = strings.Join(fields[1:4], " ")
+					if fields[1] != "-" && !strings.Contains(line, "(in 30m0s)") {
+						t.Errorf("%s row %q lacks the time to reset", fields[0], line)
+					}
+				}
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s = %q, want %q in:\n%s", name, got[name], want, buf.String())
+				}
+			}
+			if note := strings.Contains(buf.String(), "unauthenticated"); note != tt.note {
+				t.Errorf("unauthenticated note shown = %v, want %v", note, tt.note)
+			}
+		})
+	}
+}
+
+func TestPrintRateLimits(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/rate_limit", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"resources": {"core": {"limit": 60, "remaining": 42, "used": 18, "reset": %d},
+			"search": {"limit": 10, "remaining": 9, "used": 1, "reset": %d}}}`, reset, reset)
+	})
+	testGitHub(t, mux)
+
+	var buf bytes.Buffer
+	if err := PrintRateLimits(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"RESOURCE", "core", "42", "graphql", "unauthenticated"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+	githubv4 "github.com/shurcooL/githubv4"
+)
+
+// RepoMetadata holds repository-level details fetched once per run.
+type RepoMetadata struct {
+	// Owner and Name use GitHub's canonical casing and reflect renames and
+	// transfers, whatever spelling was requested. NameWithOwner is the
+	// combined "owner/name".
+	Owner         string
+	Name          string
+	NameWithOwner string
+	// License is the SPDX ID of the detected license, empty when there is
+	// none. Topics are the repo's first 20 topics, empty when it has none.
+	License string
+	Topics  []string
+	// DefaultBranch is empty for repos without commits.
+	DefaultBranch string
+	// TotalIssues and OpenIssues count issues, excluding PRs; both are nil
+	// when the repo has issues disabled.
+	TotalIssues *int
+	OpenIssues  *int
+}
+
+// repoMetadataQuery is the GraphQL shape of RepoMetadata.
+type repoMetadataQuery struct {
+	Repository struct {
+		Name          string
+		NameWithOwner string
+		Owner         struct {
+			Login string
+		}
+		LicenseInfo *struct {
+			SpdxID string `graphql:"spdxId"`
+		}
+		DefaultBranchRef *struct {
+			Name string
+		}
+		HasIssuesEnabled bool
+		Issues           struct {
+			TotalCount int
+		} `graphql:"issues(states: [OPEN, CLOSED])"`
+		OpenIssues struct {
+			TotalCount int
+		} `graphql:"openIssues: issues(states: OPEN)"`
+		RepositoryTopics struct {
+			Nodes []struct {
+				Topic struct {
+					Name string
+				}
+			}
+		} `graphql:"repositoryTopics(first: 20)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// metadata maps the query result to RepoMetadata.
+func (q repoMetadataQuery) metadata() RepoMetadata {
+	r := q.Repository
+	meta := RepoMetadata{
+		Owner:         r.Owner.Login,
+		Name:          r.Name,
+		NameWithOwner: r.NameWithOwner,
+		Topics:        make([]string, 0, len(r.RepositoryTopics.Nodes)),
+	}
+	if r.LicenseInfo != nil {
+		meta.License = r.LicenseInfo.SpdxID
+	}
+	if r.DefaultBranchRef != nil {
+		meta.DefaultBranch = r.DefaultBranchRef.Name
+	}
+	// Disabled issues report zero counts, which would read as a repo
+	// nobody files issues against.
+	if r.HasIssuesEnabled {
+		total, open := r.Issues.TotalCount, r.OpenIssues.TotalCount
+		meta.TotalIssues, meta.OpenIssues = &total, &open
+	}
+	for _, n := range r.RepositoryTopics.Nodes {
+		meta.Topics = append(meta.Topics, n.Topic.Name)
+	}
+	return meta
+}
+
+// GetRepoMetadata fetches repository-level details in a single GraphQL query.
+func GetRepoMetadata(ctx context.Context, owner, repo string) (RepoMetadata, error) {
+	if GitHubGraphQLClient == nil {
+		return RepoMetadata{}, errors.New("GitHub GraphQL client not initialized")
+	}
+
+	var q repoMetadataQuery
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(repo),
+	}
+	if err := queryWithRetry(ctx, log.With().Str("owner", owner).Str("repo", repo).Logger(), "fetching repo metadata", &q, vars); err != nil {
+		return RepoMetadata{}, err
+	}
+
+	meta := q.metadata()
+	log.Debug().Str("owner", meta.Owner).Str("repo", meta.Name).Msg("fetched repo metadata")
+	return meta, nil
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetRepoMetadataIssueCounts(t *testing.T) {
+	repos := map[string]string{
+		"tracker": `{"name": "tracker", "nameWithOwner": "octo/tracker", "owner": {"login": "octo"},
+			"hasIssuesEnabled": true, "issues": {"totalCount": 120}, "openIssues": {"totalCount": 7},
+			"repositoryTopics": {"nodes": []}}`,
+		"quiet": `{"name": "quiet", "nameWithOwner": "octo/quiet", "owner": {"login": "octo"},
+			"hasIssuesEnabled": false, "issues": {"totalCount": 0}, "openIssues": {"totalCount": 0},
+			"repositoryTopics": {"nodes": []}}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct{ Name string }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"repository": %s}}`, repos[req.Variables.Name])
+	})
+	testGitHub(t, mux)
+	ctx := context.Background()
+
+	meta, err := GetRepoMetadata(ctx, "octo", "tracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TotalIssues == nil || *meta.TotalIssues != 120 || meta.OpenIssues == nil || *meta.OpenIssues != 7 {
+		t.Errorf("tracker issues = %v/%v, want 120 total and 7 open", meta.TotalIssues, meta.OpenIssues)
+	}
+
+	meta, err = GetRepoMetadata(ctx, "octo", "quiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TotalIssues != nil || meta.OpenIssues != nil {
+		t.Error("issues-disabled repo has issue counts, want nil")
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/rs/zerolog/log"
+)
+
+// RepoListing is a repository as shown by -list-repos.
+type RepoListing struct {
+	Owner    string
+	Name     string
+	Stars    int
+	Archived bool
+	Fork     bool
+	// PushedAt is zero for repositories that were never pushed to.
+	PushedAt time.Time
+}
+
+// RepoFilter selects which listed repositories are kept. Archived repos and
+// forks are dropped unless explicitly included.
+type RepoFilter struct {
+	IncludeArchived bool
+	IncludeForks    bool
+	MinStars        int
+}
+
+// GetOrgRepos lists every repository of an organization.
+func GetOrgRepos(ctx context.Context, org string) ([]RepoListing, error) {
+	opts := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100, Page: 1}}
+	return listRepos(ctx, org, func(page int) ([]*github.Repository, *github.Response, error) {
+		opts.Page = page
+		return GitHubClient.Repositories.ListByOrg(ctx, org, opts)
+	})
+}
+
+// GetUserRepos lists the repositories owned by a user.
+func GetUserRepos(ctx context.Context, user string) ([]RepoListing, error) {
+	opts := &github.RepositoryListByUserOptions{Type: "owner", ListOptions: github.ListOptions{PerPage: 100, Page: 1}}
+	return listRepos(ctx, user, func(page int) ([]*github.Repository, *github.Response, error) {
+		opts.Page = page
+		return GitHubClient.Repositories.ListByUser(ctx, user, opts)
+	})
+}
+
+// listRepos paginates a repository listing with the usual rate-limit and
+// abuse backoff handling.
+func listRepos(ctx context.Context, owner string, fetch func(page int) ([]*github.Repository, *github.Response, error)) ([]RepoListing, error) {
+	if GitHubClient == nil {
+		return nil, errors.New("GitHub client not initialized")
+	}
+
+	var all []RepoListing
+	page := 1
+	for {
+		var (
+			repos []*github.Repository
+			resp  *github.Response
+			err   error
+		)
+		err = withBackoff(ctx, log.With().Str("owner", owner).Logger(), "listing repos", func() (*github.Response, error) {
+			repos, resp, err = fetch(page)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range repos {
+			l := RepoListing{
+				Owner:    r.GetOwner().GetLogin(),
+				Name:     r.GetName(),
+				Stars:    r.GetStargazersCount(),
+				Archived: r.GetArchived(),
+				Fork:     r.GetFork(),
+			}
+			if r.PushedAt != nil {
+				l.PushedAt = r.PushedAt.Time
+			}
+			all = append(all, l)
+		}
+		next := nextPage(resp)
+		if next == 0 {
+			break
+		}
+		page = next
+	}
+	log.Info().Str("owner", owner).Int("total", len(all)).Msg("listed repos")
+	return all, nil
+}
+
+// FilterRepos returns the repos f keeps, preserving order.
+func FilterRepos(repos []RepoListing, f RepoFilter) []RepoListing {
+	out := make([]RepoListing, 0, len(repos))
+	for _, r := range repos {
+		if r.Archived && !f.IncludeArchived {
+			continue
+		}
+		if r.Fork && !f.IncludeForks {
+			continue
+		}
+		if r.Stars < f.MinStars {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// WriteRepoList prints repos as a table whose first column is owner/repo, so
+// it can be cut into a -repos-file.
+func WriteRepoList(w io.Writer, repos []RepoListing) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tSTARS\tARCHIVED\tFORK\tLAST PUSH")
+	for _, r := range repos {
+		pushed := "-"
+		if !r.PushedAt.IsZero() {
+			pushed = r.PushedAt.UTC().Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s/%s\t%d\t%t\t%t\t%s\n", r.Owner, r.Name, r.Stars, r.Archived, r.Fork, pushed)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CommentScorer rates the sentiment of a comment's text from -1 (negative)
+// to 1 (positive), 0 being neutral. Scorers are called from several workers
+// at once and must be safe for concurrent use.
+type CommentScorer interface {
+	Score(text string) float64
+}
+
+// LexiconScorer is a simple built-in CommentScorer that counts words from
+// small positive and negative word lists and scores
+// (positive - negative) / (positive + negative), or 0 without any. It has
+// no notion of negation, sarcasm, or code in comments; plug in a real NLP
+// scorer through CommentScorer for anything beyond rough trends.
+type LexiconScorer struct{}
+
+var (
+	positiveWords = wordSet("thanks thank thx great good nice awesome excellent love lgtm perfect clean neat helpful agree approve approved appreciate cool glad happy elegant brilliant works fixed well")
+	negativeWords = wordSet("bad wrong broken ugly hate terrible awful confusing confused disagree reject rejected fail fails failing failed bug buggy hack hacky worse worst annoying unclear mess messy problem")
+)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+func (LexiconScorer) Score(text string) float64 {
+	pos, neg := 0, 0
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		switch {
+		case positiveWords[w]:
+			pos++
+		case negativeWords[w]:
+			neg++
+		}
+	}
+	if pos+neg == 0 {
+		return 0
+	}
+	return float64(pos-neg) / float64(pos+neg)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+	githubv4 "github.com/shurcooL/githubv4"
+)
+
+// reviewThreadsPageSize is how many review threads are fetched per page,
+// both nested in the bulk query and in follow-up queries.
+const reviewThreadsPageSize = 100
+
+// reviewThreadConnection is a page of a PR's review threads.
+type reviewThreadConnection struct {
+	PageInfo struct {
+		HasNextPage bool
+		EndCursor   githubv4.String
+	}
+	Nodes []struct {
+		IsResolved bool
+	}
+}
+
+// countThreads returns how many of a page's threads are resolved and
+// unresolved.
+func (c reviewThreadConnection) countThreads() (resolved, unresolved int) {
+	for _, n := range c.Nodes {
+		if n.IsResolved {
+			resolved++
+		} else {
+			unresolved++
+		}
+	}
+	return resolved, unresolved
+}
+
+// CountReviewThreads counts a PR's resolved and unresolved review threads
+// after cursor (from the start when empty), paginating through all of them.
+// The bulk query only fetches the first page, so it leaves PRLite's
+// ReviewThreadsCursor set for PRs needing this.
+func CountReviewThreads(ctx context.Context, owner, repo string, number int, cursor string) (resolved, unresolved int, err error) {
+	if GitHubGraphQLClient == nil {
+		return 0, 0, errors.New("GitHub GraphQL client not initialized")
+	}
+
+	var q struct {
+		Repository struct {
+			PullRequest struct {
+				ReviewThreads reviewThreadConnection `graphql:"reviewThreads(first: $pageSize, after: $cursor)"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	vars := map[string]interface{}{
+		"owner":    githubv4.String(owner),
+		"name":     githubv4.String(repo),
+		"number":   githubv4.Int(number),
+		"pageSize": githubv4.Int(reviewThreadsPageSize),
+		"cursor":   (*githubv4.String)(nil),
+	}
+	if cursor != "" {
+		vars["cursor"] = githubv4.String(cursor)
+	}
+
+	for {
+		if err = queryWithRetry(ctx, log.With().Int("number", number).Logger(), "counting review threads", &q, vars); err != nil {
+			return 0, 0, err
+		}
+		threads := q.Repository.PullRequest.ReviewThreads
+		r, u := threads.countThreads()
+		resolved += r
+		unresolved += u
+		if !threads.PageInfo.HasNextPage {
+			return resolved, unresolved, nil
+		}
+		vars["cursor"] = threads.PageInfo.EndCursor
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Tokens returns the GitHub tokens to authenticate with: the
+// comma-separated GITHUB_TOKENS if set, else GITHUB_TOKEN. It is empty for
+// unauthenticated use.
+func Tokens() []string {
+	if list := os.Getenv("GITHUB_TOKENS"); list != "" {
+		var tokens []string
+		for _, tok := range strings.Split(list, ",") {
+			if tok = strings.TrimSpace(tok); tok != "" {
+				tokens = append(tokens, tok)
+			}
+		}
+		return tokens
+	}
+	if tok := os.Getenv("GITHUB_TOKEN"); tok != "" {
+		return []string{tok}
+	}
+	return nil
+}
+
+// tokenPool authenticates each request with one of several tokens, moving
+// on to the next once GitHub reports the current one's primary rate limit
+// spent. The refused request is replayed with the next token, so callers
+// only see a rate limit when every token is out; it is then the one that
+// resets first. Each client gets its own pool, as REST and GraphQL limits
+// are counted separately.
+type tokenPool struct {
+	base   http.RoundTripper
+	tokens []string
+
+	mu      sync.Mutex
+	current int
+	resets  []time.Time // when each token's limit resets; zero if usable
+}
+
+func newTokenPool(base http.RoundTripper, tokens []string) *tokenPool {
+	return &tokenPool{base: base, tokens: tokens, resets: make([]time.Time, len(tokens))}
+}
+
+// pick returns the current token if usable, else the next usable one, else
+// the one that resets first.
+func (p *tokenPool) pick() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	earliest := p.current
+	for k := range p.tokens {
+		i := (p.current + k) % len(p.tokens)
+		if !p.resets[i].After(now) {
+			p.current = i
+			return i
+		}
+		if p.resets[i].Before(p.resets[earliest]) {
+			earliest = i
+		}
+	}
+	return earliest
+}
+
+// exhaust records that token i is limited until reset, and reports whether
+// another token can take over now. A reset already past, e.g. from a
+// skewed clock, still benches the token for a second, so it is not picked
+// straight back.
+func (p *tokenPool) exhaust(i int, reset time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if floor := now.Add(time.Second); reset.Before(floor) {
+		reset = floor
+	}
+	p.resets[i] = reset
+	for _, r := range p.resets {
+		if !r.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundTrip sends req with each token at most once, returning the last
+// refusal when every token turned out to be limited.
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		i := p.pick()
+		r := req.Clone(req.Context())
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		r.Header.Set("Authorization", "Bearer "+p.tokens[i])
+		resp, err := p.base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		reset, limited, err := primaryLimited(resp)
+		if err != nil {
+			return nil, err
+		}
+		if !limited || (req.Body != nil && req.GetBody == nil) || !p.exhaust(i, reset) || attempt >= len(p.tokens) {
+			return resp, nil
+		}
+		log.Info().Int("token", i+1).Time("reset_at", reset).Msg("token rate limit reached; rotating to the next token")
+		resp.Body.Close()
+	}
+}
+
+// primaryLimited reports whether resp is GitHub refusing a request because
+// the token's primary rate limit is spent, and when it resets. REST
+// answers 403 or 429; GraphQL answers 200 with RATE_LIMITED errors, so its
+// body is read and put back.
+func primaryLimited(resp *http.Response) (time.Time, bool, error) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false, nil
+	}
+	secs, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+	reset := time.Unix(secs, 0)
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return reset, true, nil
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return reset, errorTypes(body) == "RATE_LIMITED", nil
+	}
+	return time.Time{}, false, nil
+}
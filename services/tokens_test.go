@@ -0,0 +1,77 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitedTransport answers requests authenticated with a token in limited
+// as GitHub does once the token's primary rate limit is spent, and records
+// the token of every request.
+type limitedTransport struct {
+	limited map[string]bool
+	reset   time.Time
+	seen    []string
+}
+
+func (t *limitedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	t.seen = append(t.seen, tok)
+	if r.Body != nil {
+		if b, _ := io.ReadAll(r.Body); string(b) != "query" {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	resp := &http.Response{Request: r, StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}
+	if t.limited[tok] {
+		resp.StatusCode = http.StatusForbidden
+		resp.Header.Set("X-RateLimit-Remaining", "0")
+		resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(t.reset.Unix(), 10))
+	}
+	return resp, nil
+}
+
+func TestTokenPoolRotates(t *testing.T) {
+	base := &limitedTransport{limited: map[string]bool{"a": true}, reset: time.Now().Add(time.Hour)}
+	pool := newTokenPool(base, []string{"a", "b", "c"})
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodPost, "https://api.github.com/graphql", strings.NewReader("query"))
+		resp, err := pool.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d, want the request replayed with the next token", resp.StatusCode)
+		}
+	}
+	// a is refused once, then b serves both requests.
+	if got := strings.Join(base.seen, ","); got != "a,b,b" {
+		t.Errorf("tokens used %s, want a,b,b", got)
+	}
+}
+
+func TestTokenPoolTriesEachTokenOnce(t *testing.T) {
+	// Every token is out, with a reset already past, e.g. a skewed clock.
+	base := &limitedTransport{limited: map[string]bool{"a": true, "b": true}, reset: time.Now().Add(-time.Minute)}
+	pool := newTokenPool(base, []string{"a", "b"})
+	req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/rate_limit", nil)
+	resp, err := pool.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status %d, want the last refusal", resp.StatusCode)
+	}
+	if got := strings.Join(base.seen, ","); got != "a,b" {
+		t.Errorf("tokens used %s, want each once", got)
+	}
+	for i, r := range pool.resets {
+		if !r.After(time.Now()) {
+			t.Errorf("token %d benched until %s, which is already past", i+1, r)
+		}
+	}
+}
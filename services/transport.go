@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	apiRequests   atomic.Int64
+	requestBudget atomic.Int64
+)
+
+// ErrBudgetExhausted is returned (wrapped) for every request once the
+// budget set with SetRequestBudget has been spent.
+var ErrBudgetExhausted = errors.New("request budget exhausted")
+
+// APIRequests returns how many HTTP requests the GitHub clients have sent,
+// REST and GraphQL combined, including retries.
+func APIRequests() int64 { return apiRequests.Load() }
+
+// SetRequestBudget caps the requests the GitHub clients may send over the
+// life of the process, retries included; n <= 0 removes the cap.
+func SetRequestBudget(n int64) { requestBudget.Store(n) }
+
+// countingTransport counts requests before handing them to base, and
+// refuses them once the request budget is spent.
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := apiRequests.Add(1)
+	if budget := requestBudget.Load(); budget > 0 && n > budget {
+		apiRequests.Add(-1)
+		return nil, ErrBudgetExhausted
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newHTTPClient returns the HTTP client for the GitHub clients, authenticated
+// with tokens when there are any, rotating between them when there are
+// several.
+func newHTTPClient(ctx context.Context, tokens []string) *http.Client {
+	if len(tokens) == 0 {
+		return &http.Client{Transport: &conditionalTransport{base: &countingTransport{base: http.DefaultTransport}}}
+	}
+	if len(tokens) > 1 {
+		return &http.Client{Transport: &conditionalTransport{base: newTokenPool(&countingTransport{base: http.DefaultTransport}, tokens)}}
+	}
+	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tokens[0]}))
+	tc.Transport = &conditionalTransport{base: &countingTransport{base: tc.Transport}}
+	return tc
+}
+
+// statusTransport turns GraphQL responses GitHub refused into a
+// *StatusError carrying the status and how long to wait, which the GraphQL
+// client would otherwise flatten into a message. Primary rate limits come
+// back as a 200 with RATE_LIMITED errors and the budget at zero, and
+// fields the token may not read as a 200 with FORBIDDEN errors, reported
+// as a 403.
+type statusTransport struct {
+	base http.RoundTripper
+}
+
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 300
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	wait, hinted := retryAfter(resp)
+	if ok {
+		switch errorTypes(body) {
+		case "RATE_LIMITED":
+			return nil, &StatusError{StatusCode: resp.StatusCode, RateLimited: true, RetryAfter: wait, Body: string(body)}
+		case "FORBIDDEN":
+			return nil, &StatusError{StatusCode: http.StatusForbidden, Body: string(body)}
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+	limited := resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusForbidden && hinted)
+	return nil, &StatusError{StatusCode: resp.StatusCode, RateLimited: limited, RetryAfter: wait, Body: string(body)}
+}
+
+// errorTypes reports the type shared by every error of a GraphQL response
+// body: RATE_LIMITED when any error is one, else the type of the errors
+// when they all have the same, else "".
+func errorTypes(body []byte) string {
+	var out struct {
+		Errors []struct {
+			Type string `json:"type"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &out) != nil || len(out.Errors) == 0 {
+		return ""
+	}
+	typ := out.Errors[0].Type
+	for _, e := range out.Errors {
+		if e.Type == "RATE_LIMITED" {
+			return e.Type
+		}
+		if e.Type != typ {
+			typ = ""
+		}
+	}
+	return typ
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PostWebhook POSTs payload as JSON to url. Each attempt is bounded by
+// timeout; network errors and 5xx responses are retried a couple of times.
+func PostWebhook(ctx context.Context, url string, payload any, timeout time.Duration) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	const attempts = 3
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		retry, err := postWebhookOnce(ctx, url, body, timeout)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == attempts {
+			break
+		}
+		sleepFor := time.Duration(attempt) * time.Second
+		log.Warn().Err(err).Int("attempt", attempt).Dur("sleep_for", sleepFor).Msg("webhook POST failed; retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleepFor):
+		}
+	}
+	return lastErr
+}
+
+// postWebhookOnce performs a single POST and reports whether a failure is
+// worth retrying.
+func postWebhookOnce(ctx context.Context, url string, body []byte, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
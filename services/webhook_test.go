@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		wantPOST int
+	}{
+		{"ok", []int{http.StatusOK}, false, 1},
+		{"5xx retried", []int{http.StatusBadGateway, http.StatusNoContent}, false, 2},
+		{"4xx not retried", []int{http.StatusBadRequest}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+				}
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["status"] != "success" {
+					t.Errorf("body = %v (%v), want the payload", body, err)
+				}
+				w.WriteHeader(tt.statuses[min(posts, len(tt.statuses)-1)])
+				posts++
+			}))
+			defer srv.Close()
+
+			err := PostWebhook(context.Background(), srv.URL, map[string]string{"status": "success"}, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if posts != tt.wantPOST {
+				t.Errorf("posted %d times, want %d", posts, tt.wantPOST)
+			}
+		})
+	}
+}
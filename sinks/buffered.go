@@ -0,0 +1,145 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// Buffered is implemented by sinks that hold rows back and store them
+// later, e.g. in batches. Their Write only accepts a row, so a nil error
+// does not mean it was stored. Flush stores the rows of owner/repo still
+// held and reports every row of that repo accepted since its last Flush
+// that could not be stored. Close stores whatever no Flush covered.
+type Buffered interface {
+	Sink
+	Flush(ctx context.Context, owner, repo string) []RowError
+}
+
+// RowError is a row a Buffered sink accepted but could not store.
+type RowError struct {
+	Owner  string
+	Repo   string
+	Number int
+	Err    error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("%s/%s#%d: %v", e.Owner, e.Repo, e.Number, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// joinRowErrors folds errs into one error for Close, nil if there are none.
+func joinRowErrors(errs []RowError) error {
+	out := make([]error, len(errs))
+	for i, e := range errs {
+		out[i] = e
+	}
+	return errors.Join(out...)
+}
+
+// repoKey identifies a repo's rows in a batcher. GitHub names are
+// case-insensitive.
+func repoKey(owner, repo string) string {
+	return strings.ToLower(owner + "/" + repo)
+}
+
+// batcher holds rows per repo and stores each repo's rows size at a time
+// with store, which returns the rows it failed to store. Failures are kept
+// until the repo's flush, so they are reported for the rows that failed
+// rather than for whichever row filled the batch.
+type batcher struct {
+	size  int
+	store func(ctx context.Context, rows []types.PRRow) []RowError
+
+	mu     sync.Mutex
+	rows   map[string][]types.PRRow
+	failed map[string][]RowError
+}
+
+func newBatcher(size int, store func(ctx context.Context, rows []types.PRRow) []RowError) *batcher {
+	return &batcher{size: size, store: store, rows: make(map[string][]types.PRRow), failed: make(map[string][]RowError)}
+}
+
+// add holds row, storing its repo's rows once a batch is full.
+func (b *batcher) add(ctx context.Context, row types.PRRow) {
+	key := repoKey(row.Owner, row.Repo)
+	b.mu.Lock()
+	b.rows[key] = append(b.rows[key], row)
+	if len(b.rows[key]) < b.size {
+		b.mu.Unlock()
+		return
+	}
+	rows := b.rows[key]
+	delete(b.rows, key)
+	b.mu.Unlock()
+	b.storeRows(ctx, key, rows)
+}
+
+func (b *batcher) storeRows(ctx context.Context, key string, rows []types.PRRow) {
+	if len(rows) == 0 {
+		return
+	}
+	errs := b.store(ctx, rows)
+	if len(errs) == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.failed[key] = append(b.failed[key], errs...)
+	b.mu.Unlock()
+}
+
+// flush stores the rows held for owner/repo and returns the repo's
+// failures since its last flush.
+func (b *batcher) flush(ctx context.Context, owner, repo string) []RowError {
+	key := repoKey(owner, repo)
+	b.mu.Lock()
+	rows := b.rows[key]
+	delete(b.rows, key)
+	b.mu.Unlock()
+	b.storeRows(ctx, key, rows)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errs := b.failed[key]
+	delete(b.failed, key)
+	return errs
+}
+
+// flushAll stores the rows of every repo and returns all failures not yet
+// reported.
+func (b *batcher) flushAll(ctx context.Context) []RowError {
+	b.mu.Lock()
+	held := b.rows
+	b.rows = make(map[string][]types.PRRow)
+	b.mu.Unlock()
+	for key, rows := range held {
+		b.storeRows(ctx, key, rows)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs []RowError
+	for _, e := range b.failed {
+		errs = append(errs, e...)
+	}
+	b.failed = make(map[string][]RowError)
+	return errs
+}
+
+// storeEach stores rows one at a time with write, for when a batch
+// failed as a whole, so one bad row does not cost the others.
+func storeEach(ctx context.Context, rows []types.PRRow, write func(context.Context, types.PRRow) error) []RowError {
+	var errs []RowError
+	for _, row := range rows {
+		if err := write(ctx, row); err != nil {
+			errs = append(errs, RowError{Owner: row.Owner, Repo: row.Repo, Number: row.ID, Err: err})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,49 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestBatcherReportsFailedRowsPerRepo(t *testing.T) {
+	bad := errors.New("bad row")
+	var stored []int
+	b := newBatcher(2, func(ctx context.Context, rows []types.PRRow) []RowError {
+		return storeEach(ctx, rows, func(_ context.Context, row types.PRRow) error {
+			if row.ID == 2 {
+				return bad
+			}
+			stored = append(stored, row.ID)
+			return nil
+		})
+	})
+	ctx := context.Background()
+	for _, row := range []types.PRRow{
+		{ID: 1, Owner: "o", Repo: "a"},
+		{ID: 1, Owner: "o", Repo: "b"},
+		{ID: 2, Owner: "O", Repo: "A"}, // fills a's batch, which holds the bad row
+		{ID: 3, Owner: "o", Repo: "a"},
+	} {
+		b.add(ctx, row)
+	}
+
+	errs := b.flush(ctx, "o", "a")
+	if len(errs) != 1 || errs[0].Number != 2 || !errors.Is(errs[0], bad) {
+		t.Fatalf("flush(a) = %v, want only #2 failed", errs)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("stored %v after flushing a, want #1 and #3", stored)
+	}
+	if errs := b.flush(ctx, "o", "a"); len(errs) != 0 {
+		t.Errorf("second flush(a) = %v, want failures reported once", errs)
+	}
+	if errs := b.flushAll(ctx); len(errs) != 0 {
+		t.Errorf("flushAll = %v, want none", errs)
+	}
+	if len(stored) != 3 {
+		t.Errorf("stored %v, want b's row stored by flushAll", stored)
+	}
+}
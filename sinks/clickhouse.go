@@ -0,0 +1,336 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/rs/zerolog/log"
+)
+
+// clickhouseColumn is a column of the ClickHouse prs table and how a row
+// fills it. value returns the Go type clickhouse-go appends to a column of
+// typ, e.g. uint32 for UInt32 and *uint32 for Nullable(UInt32).
+type clickhouseColumn struct {
+	name  string
+	typ   string
+	value func(types.PRRow) any
+}
+
+// clickhouseColumns are the columns inserted from each row, in table
+// order. Every insert names them all, so a table missing one fails the
+// insert instead of dropping the value.
+var clickhouseColumns = []clickhouseColumn{
+	{"id", "UInt32", func(r types.PRRow) any { return uint32(r.ID) }},
+	{"owner", "LowCardinality(String)", func(r types.PRRow) any { return r.Owner }},
+	{"repo", "LowCardinality(String)", func(r types.PRRow) any { return r.Repo }},
+	{"author", "String", func(r types.PRRow) any { return r.Author }},
+	{"comment_count", "UInt32", func(r types.PRRow) any { return uint32(r.CommentCount) }},
+	{"issue_comments", "UInt32", func(r types.PRRow) any { return uint32(r.IssueComments) }},
+	{"review_comments", "UInt32", func(r types.PRRow) any { return uint32(r.ReviewComments) }},
+	{"github_comment_count", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.GitHubCommentCount) }},
+	{"bot_comments", "UInt32", func(r types.PRRow) any { return uint32(r.BotComments) }},
+	{"author_comments", "UInt32", func(r types.PRRow) any { return uint32(r.AuthorComments) }},
+	{"lines_changed", "UInt32", func(r types.PRRow) any { return uint32(r.LinesChanged) }},
+	{"additions", "UInt32", func(r types.PRRow) any { return uint32(r.Additions) }},
+	{"deletions", "UInt32", func(r types.PRRow) any { return uint32(r.Deletions) }},
+	{"stats_truncated", "Bool", func(r types.PRRow) any { return r.StatsTruncated }},
+	{"files_added", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.FilesAdded) }},
+	{"files_modified", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.FilesModified) }},
+	{"files_removed", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.FilesRemoved) }},
+	{"status", "LowCardinality(String)", func(r types.PRRow) any { return r.Status }},
+	{"state", "LowCardinality(String)", func(r types.PRRow) any { return r.State }},
+	{"body_word_count", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.BodyWordCount) }},
+	{"checklist_total", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ChecklistTotal) }},
+	{"checklist_checked", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ChecklistChecked) }},
+	{"created_at", "DateTime64(3, 'UTC')", func(r types.PRRow) any { return r.CreatedAt.UTC() }},
+	{"open_duration_days", "Float64", func(r types.PRRow) any { return r.OpenDuration }},
+	{"merge_commit_sha", "String", func(r types.PRRow) any { return r.MergeCommitSHA }},
+	{"base_sha", "String", func(r types.PRRow) any { return r.BaseSHA }},
+	{"head_sha", "String", func(r types.PRRow) any { return r.HeadSHA }},
+	{"checks", "Array(String)", func(r types.PRRow) any { return r.Checks }},
+	{"labels", "Array(String)", func(r types.PRRow) any { return r.Labels }},
+	{"auto_merged", "Nullable(Bool)", func(r types.PRRow) any { return r.AutoMerged }},
+	{"auto_merge_enabled_by", "String", func(r types.PRRow) any { return r.AutoMergeEnabledBy }},
+	{"commit_count", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.CommitCount) }},
+	{"commit_source", "LowCardinality(String)", func(r types.PRRow) any { return r.CommitSource }},
+	{"bot_comment_breakdown", "Map(String, UInt32)", func(r types.PRRow) any { return uint32Map(r.BotCommentBreakdown) }},
+	{"reviewers", "Array(String)", func(r types.PRRow) any { return r.Reviewers }},
+	{"node_id", "String", func(r types.PRRow) any { return r.NodeID }},
+	{"review_request_events", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ReviewRequestEvents) }},
+	{"base_ref", "LowCardinality(String)", func(r types.PRRow) any { return r.BaseRef }},
+	{"title", "String", func(r types.PRRow) any { return r.Title }},
+	{"body", "String", func(r types.PRRow) any { return r.Body }},
+	{"mergeable", "LowCardinality(String)", func(r types.PRRow) any { return r.Mergeable }},
+	{"resolved_threads", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ResolvedThreads) }},
+	{"unresolved_threads", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.UnresolvedThreads) }},
+	{"approved_reviews", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ApprovedReviews) }},
+	{"changes_requested_reviews", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ChangesRequestedReviews) }},
+	{"commented_reviews", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.CommentedReviews) }},
+	{"comments_first_day", "UInt32", func(r types.PRRow) any { return uint32(r.CommentsFirstDay) }},
+	{"comments_first_week", "UInt32", func(r types.PRRow) any { return uint32(r.CommentsFirstWeek) }},
+	{"review_response_latency", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.ReviewResponseLatency) }},
+	{"comments_truncated", "Bool", func(r types.PRRow) any { return r.CommentsTruncated }},
+	{"dedup_group", "String", func(r types.PRRow) any { return r.DedupGroup }},
+	{"file_types", "Map(String, UInt32)", func(r types.PRRow) any { return uint32Map(r.FileTypes) }},
+	{"base_protected", "Nullable(Bool)", func(r types.PRRow) any { return r.BaseProtected }},
+	{"requires_approving_reviews", "Nullable(Bool)", func(r types.PRRow) any { return r.RequiresApprovingReviews }},
+	{"required_approving_reviews", "Nullable(UInt32)", func(r types.PRRow) any { return optUint32(r.RequiredApprovingReviews) }},
+	{"comment_sentiment", "Nullable(Float64)", func(r types.PRRow) any { return r.CommentSentiment }},
+	{"origin", "LowCardinality(String)", func(r types.PRRow) any { return r.Origin }},
+	{"closed_at", "Nullable(DateTime64(3, 'UTC'))", func(r types.PRRow) any { return utcTime(r.ClosedAt) }},
+	{"merged_at", "Nullable(DateTime64(3, 'UTC'))", func(r types.PRRow) any { return utcTime(r.MergedAt) }},
+}
+
+// clickhouseSchema keeps one row per PR. The table is sorted and indexed by
+// (owner, repo, created_at); id only breaks ties in the sorting key, so that
+// ReplacingMergeTree collapses re-scrapes of the same PR onto the newest
+// scraped_at during merges. Query with FINAL (or argMax) for exact per-PR
+// values.
+func clickhouseSchema() string {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE IF NOT EXISTS prs (\n")
+	for _, c := range clickhouseColumns {
+		fmt.Fprintf(&b, "    %s %s,\n", c.name, c.typ)
+	}
+	b.WriteString("    scraped_at DateTime64(3, 'UTC') DEFAULT now64(3)\n)\n")
+	b.WriteString("ENGINE = ReplacingMergeTree(scraped_at)\nPRIMARY KEY (owner, repo, created_at)\nORDER BY (owner, repo, created_at, id)")
+	return b.String()
+}
+
+// ClickHouse batches rows per repo and inserts each batch through
+// clickhouse-go as one async insert, so the server can coalesce small
+// batches. A batch the server refuses is retried row by row so the failure
+// is reported for the rows that caused it.
+type ClickHouse struct {
+	conn driver.Conn
+	rows *batcher
+}
+
+// NewClickHouseFromEnv connects using CLICKHOUSE_URL (default
+// http://localhost:8123), CLICKHOUSE_DB, CLICKHOUSE_USER, and
+// CLICKHOUSE_PASSWORD, and creates the prs table if needed.
+func NewClickHouseFromEnv(ctx context.Context, batch int) (*ClickHouse, error) {
+	opts, err := clickhouseOptions(os.Getenv("CLICKHOUSE_URL"))
+	if err != nil {
+		return nil, err
+	}
+	opts.Auth = clickhouse.Auth{
+		Database: os.Getenv("CLICKHOUSE_DB"),
+		Username: os.Getenv("CLICKHOUSE_USER"),
+		Password: os.Getenv("CLICKHOUSE_PASSWORD"),
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("connect to ClickHouse: %w", err)
+	}
+	if batch < 1 {
+		batch = 500
+	}
+	ch := &ClickHouse{conn: conn}
+	ch.rows = newBatcher(batch, ch.store)
+	if err := conn.Exec(ctx, clickhouseSchema()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create ClickHouse table: %w", err)
+	}
+	if err := ch.migrate(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("migrate ClickHouse table: %w", err)
+	}
+	log.Info().Str("addr", opts.Addr[0]).Msg("connected to ClickHouse")
+	return ch, nil
+}
+
+// clickhouseOptions reads the server address and protocol from raw: an
+// http or https URL uses the HTTP interface, a clickhouse or tcp URL the
+// native protocol.
+func clickhouseOptions(raw string) (*clickhouse.Options, error) {
+	if raw == "" {
+		raw = "http://localhost:8123"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid CLICKHOUSE_URL %q", raw)
+	}
+	opts := &clickhouse.Options{
+		Addr:        []string{u.Host},
+		DialTimeout: 10 * time.Second,
+		ReadTimeout: 60 * time.Second,
+	}
+	switch u.Scheme {
+	case "http":
+		opts.Protocol = clickhouse.HTTP
+	case "https":
+		opts.Protocol = clickhouse.HTTP
+		opts.TLS = &tls.Config{}
+	case "clickhouse", "tcp":
+		opts.Protocol = clickhouse.Native
+	default:
+		return nil, fmt.Errorf("invalid CLICKHOUSE_URL %q: scheme must be http, https, clickhouse, or tcp", raw)
+	}
+	return opts, nil
+}
+
+// storedColumn is a column of the prs table as ClickHouse reports it.
+type storedColumn struct {
+	Name           string `ch:"name"`
+	Type           string `ch:"type"`
+	IsInSortingKey uint8  `ch:"is_in_sorting_key"`
+}
+
+// migrate brings a prs table created by an earlier version up to
+// clickhouseColumns, which CREATE TABLE IF NOT EXISTS leaves alone.
+func (c *ClickHouse) migrate(ctx context.Context) error {
+	var stored []storedColumn
+	if err := c.conn.Select(ctx, &stored, "SELECT name, type, is_in_sorting_key FROM system.columns WHERE database = currentDatabase() AND table = 'prs'"); err != nil {
+		return err
+	}
+	for _, stmt := range clickhouseMigrations(stored) {
+		if err := c.conn.Exec(ctx, stmt); err != nil {
+			return err
+		}
+		log.Info().Str("statement", stmt).Msg("migrated ClickHouse prs table")
+	}
+	return nil
+}
+
+// clickhouseMigrations returns the ALTER TABLE statements that add the
+// columns stored lacks and change the ones whose type changed since, e.g.
+// counts that became Nullable. Sorting key columns cannot be changed in
+// place; a mismatch there is only logged, and inserts may then fail.
+func clickhouseMigrations(stored []storedColumn) []string {
+	byName := make(map[string]storedColumn, len(stored))
+	for _, col := range stored {
+		byName[col.Name] = col
+	}
+	var stmts []string
+	for _, c := range clickhouseColumns {
+		col, ok := byName[c.name]
+		switch {
+		case !ok:
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE prs ADD COLUMN IF NOT EXISTS %s %s", c.name, c.typ))
+		case col.Type == c.typ:
+		case col.IsInSortingKey != 0:
+			log.Warn().Str("column", c.name).Str("type", col.Type).Str("want", c.typ).Msg("ClickHouse prs sorting key column has an outdated type; recreate the table to change it")
+		default:
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE prs MODIFY COLUMN %s %s", c.name, c.typ))
+		}
+	}
+	return stmts
+}
+
+// Write accepts row; it is stored once its repo's batch fills or on Flush.
+func (c *ClickHouse) Write(ctx context.Context, row types.PRRow) error {
+	c.rows.add(ctx, row)
+	return nil
+}
+
+func (c *ClickHouse) Flush(ctx context.Context, owner, repo string) []RowError {
+	return c.rows.flush(ctx, owner, repo)
+}
+
+func (c *ClickHouse) Close() error {
+	err := joinRowErrors(c.rows.flushAll(context.Background()))
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// store inserts rows as one batch, falling back to one insert per row if
+// the batch is refused.
+func (c *ClickHouse) store(ctx context.Context, rows []types.PRRow) []RowError {
+	err := c.insert(ctx, rows)
+	if err == nil {
+		return nil
+	}
+	if len(rows) == 1 {
+		return []RowError{{Owner: rows[0].Owner, Repo: rows[0].Repo, Number: rows[0].ID, Err: err}}
+	}
+	log.Warn().Err(err).Int("rows", len(rows)).Msg("ClickHouse batch insert failed; retrying rows one at a time")
+	return storeEach(ctx, rows, func(ctx context.Context, row types.PRRow) error {
+		return c.insert(ctx, []types.PRRow{row})
+	})
+}
+
+// insert sends rows as one batch. async_insert lets the server buffer
+// small batches from concurrent repos into fewer parts, and
+// wait_for_async_insert holds the call until the rows are stored, so a
+// nil error still means they were written.
+func (c *ClickHouse) insert(ctx context.Context, rows []types.PRRow) error {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"async_insert":          1,
+		"wait_for_async_insert": 1,
+	}))
+	batch, err := c.conn.PrepareBatch(ctx, clickhouseInsert())
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := batch.Append(clickhouseRow(r)...); err != nil {
+			batch.Abort()
+			return fmt.Errorf("PR #%d: %w", r.ID, err)
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return err
+	}
+	log.Debug().Int("rows", len(rows)).Msg("inserted rows into ClickHouse")
+	return nil
+}
+
+// clickhouseInsert names every column, so the insert fails if the table
+// lacks one rather than storing the row without it.
+func clickhouseInsert() string {
+	names := make([]string, len(clickhouseColumns))
+	for i, c := range clickhouseColumns {
+		names[i] = c.name
+	}
+	return "INSERT INTO prs (" + strings.Join(names, ", ") + ")"
+}
+
+// clickhouseRow returns r's values in clickhouseColumns order.
+func clickhouseRow(r types.PRRow) []any {
+	values := make([]any, len(clickhouseColumns))
+	for i, c := range clickhouseColumns {
+		values[i] = c.value(r)
+	}
+	return values
+}
+
+// utcTime converts an optional timestamp to UTC, leaving nil as nil.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// optUint32 converts an optional count, leaving nil as nil.
+func optUint32(n *int) *uint32 {
+	if n == nil {
+		return nil
+	}
+	v := uint32(*n)
+	return &v
+}
+
+// uint32Map converts a map of counts; nil stays nil and is stored empty.
+func uint32Map(m map[string]int) map[string]uint32 {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]uint32, len(m))
+	for k, v := range m {
+		out[k] = uint32(v)
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestClickHouseRows(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	merged := time.Date(2024, 3, 2, 10, 0, 0, 0, est)
+	row := types.PRRow{
+		ID:           7,
+		Owner:        "octo",
+		Repo:         "demo",
+		Author:       "alice",
+		Status:       "merged",
+		CreatedAt:    time.Date(2024, 3, 1, 9, 0, 0, 0, est),
+		MergedAt:     &merged,
+		Labels:       []string{"bug"},
+		LastRunID:    "run-1",
+		LinesChanged: 42,
+		Deployments:  []types.Deployment{{Environment: "prod"}},
+	}
+
+	row.FileTypes = map[string]int{".go": 2}
+
+	values := clickhouseRow(row)
+	if len(values) != len(clickhouseColumns) {
+		t.Fatalf("got %d values, want one per column (%d)", len(values), len(clickhouseColumns))
+	}
+	got := make(map[string]any, len(values))
+	for i, c := range clickhouseColumns {
+		got[c.name] = values[i]
+	}
+	for _, key := range []string{"last_run_id", "deployments", "scraped_at"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("row has %q, which is not an inserted column", key)
+		}
+	}
+	wantMerged := time.Date(2024, 3, 2, 15, 0, 0, 0, time.UTC)
+	for key, want := range map[string]any{
+		"id":                   uint32(7),
+		"owner":                "octo",
+		"status":               "merged",
+		"lines_changed":        uint32(42),
+		"created_at":           time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC),
+		"merged_at":            &wantMerged,
+		"closed_at":            (*time.Time)(nil),
+		"github_comment_count": (*uint32)(nil),
+		"labels":               []string{"bug"},
+		"file_types":           map[string]uint32{".go": 2},
+	} {
+		if !reflect.DeepEqual(got[key], want) {
+			t.Errorf("%s = %#v, want %#v", key, got[key], want)
+		}
+	}
+	if loc := got["created_at"].(time.Time).Location(); loc != time.UTC {
+		t.Errorf("created_at is in %v, want UTC", loc)
+	}
+}
+
+func TestClickHouseOptions(t *testing.T) {
+	tests := []struct {
+		url      string
+		addr     string
+		protocol clickhouse.Protocol
+		tls      bool
+		wantErr  bool
+	}{
+		{"", "localhost:8123", clickhouse.HTTP, false, false},
+		{"https://ch.example.com:8443", "ch.example.com:8443", clickhouse.HTTP, true, false},
+		{"clickhouse://ch.example.com:9000", "ch.example.com:9000", clickhouse.Native, false, false},
+		{"ftp://ch.example.com", "", 0, false, true},
+		{"localhost:8123", "", 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			opts, err := clickhouseOptions(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if opts.Addr[0] != tt.addr || opts.Protocol != tt.protocol || (opts.TLS != nil) != tt.tls {
+				t.Errorf("got addr %s, protocol %v, TLS %v", opts.Addr[0], opts.Protocol, opts.TLS != nil)
+			}
+		})
+	}
+}
+
+func TestClickHouseSchemaMatchesInsert(t *testing.T) {
+	schema, insert := clickhouseSchema(), clickhouseInsert()
+	for _, c := range clickhouseColumns {
+		if !strings.Contains(schema, "    "+c.name+" "+c.typ+",\n") {
+			t.Errorf("schema lacks column %s %s", c.name, c.typ)
+		}
+		if !strings.Contains(insert, c.name) {
+			t.Errorf("insert does not name column %s", c.name)
+		}
+	}
+}
+
+func TestClickHouseMigrations(t *testing.T) {
+	// An older table: no state column, non-nullable file counts, and a
+	// sorting key column of another type.
+	var stored []storedColumn
+	for _, c := range clickhouseColumns {
+		col := storedColumn{Name: c.name, Type: c.typ}
+		switch c.name {
+		case "state":
+			continue
+		case "files_added":
+			col.Type = "UInt32"
+		case "id":
+			col.Type, col.IsInSortingKey = "UInt64", 1
+		}
+		stored = append(stored, col)
+	}
+	want := []string{
+		"ALTER TABLE prs MODIFY COLUMN files_added Nullable(UInt32)",
+		"ALTER TABLE prs ADD COLUMN IF NOT EXISTS state LowCardinality(String)",
+	}
+	if got := clickhouseMigrations(stored); !reflect.DeepEqual(got, want) {
+		t.Errorf("statements = %q, want %q", got, want)
+	}
+}
+
+// TestClickHouseIntegration round-trips a row through a real server. It
+// runs only with CLICKHOUSE_URL set, and writes to the prs table there.
+func TestClickHouseIntegration(t *testing.T) {
+	if os.Getenv("CLICKHOUSE_URL") == "" {
+		t.Skip("CLICKHOUSE_URL not set")
+	}
+	ctx := context.Background()
+	ch, err := NewClickHouseFromEnv(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := fmt.Sprintf("it-%d", time.Now().UnixNano())
+	row := types.PRRow{ID: 1, Owner: "github-scraper-test", Repo: repo, Author: "alice", Status: "open", CreatedAt: time.Now()}
+	if err := ch.Write(ctx, row); err != nil {
+		t.Fatal(err)
+	}
+	if errs := ch.Flush(ctx, row.Owner, row.Repo); len(errs) > 0 {
+		t.Fatal(joinRowErrors(errs))
+	}
+	if err := ch.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := NewClickHouseFromEnv(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer check.conn.Close()
+	var count uint64
+	if err := check.conn.QueryRow(ctx, "SELECT count() FROM prs WHERE owner = ? AND repo = ?", row.Owner, row.Repo).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("stored %d rows, want 1", count)
+	}
+}
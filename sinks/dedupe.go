@@ -0,0 +1,173 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// DedupGroups finds PRs that are likely the same contribution opened in
+// several repos of a fork network. Two PRs are linked when they share an
+// author and either their head commit or their (case- and
+// whitespace-insensitive) title, and the rows sharing that key span more
+// than one repo; links are transitive. The result holds a group ID for each
+// row, or "" for rows without duplicates. A group's ID is the
+// owner/repo#number of its earliest PR.
+//
+// This is a heuristic. Unrelated PRs with a generic title ("Update
+// README.md") by the same author are grouped too, and once a key spans two
+// repos, PRs sharing it within one repo join the same group. Titles are only
+// compared when rows carry them (-store-bodies).
+func DedupGroups(rows []types.PRRow) []string {
+	parent := make([]int, len(rows))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	buckets := make(map[string][]int)
+	for i, r := range rows {
+		if r.Author == "" {
+			continue
+		}
+		if r.HeadSHA != "" {
+			buckets["sha\x00"+r.Author+"\x00"+r.HeadSHA] = append(buckets["sha\x00"+r.Author+"\x00"+r.HeadSHA], i)
+		}
+		if t := normalizeTitle(r.Title); t != "" {
+			buckets["title\x00"+r.Author+"\x00"+t] = append(buckets["title\x00"+r.Author+"\x00"+t], i)
+		}
+	}
+	for _, idx := range buckets {
+		if !spansRepos(rows, idx) {
+			continue
+		}
+		for _, j := range idx[1:] {
+			parent[find(j)] = find(idx[0])
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range rows {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+	groups := make([]string, len(rows))
+	for _, idx := range members {
+		if len(idx) < 2 {
+			continue
+		}
+		sort.Slice(idx, func(a, b int) bool {
+			ra, rb := rows[idx[a]], rows[idx[b]]
+			if !ra.CreatedAt.Equal(rb.CreatedAt) {
+				return ra.CreatedAt.Before(rb.CreatedAt)
+			}
+			return prRef(ra) < prRef(rb)
+		})
+		id := prRef(rows[idx[0]])
+		for _, i := range idx {
+			groups[i] = id
+		}
+	}
+	return groups
+}
+
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+func spansRepos(rows []types.PRRow, idx []int) bool {
+	for _, i := range idx[1:] {
+		if !strings.EqualFold(rows[i].Owner, rows[idx[0]].Owner) || !strings.EqualFold(rows[i].Repo, rows[idx[0]].Repo) {
+			return true
+		}
+	}
+	return false
+}
+
+func prRef(r types.PRRow) string {
+	return fmt.Sprintf("%s/%s#%d", r.Owner, r.Repo, r.ID)
+}
+
+// Dedupe sets DedupGroup with DedupGroups before passing rows to the
+// wrapped sink. A repo's rows are held until its Flush and then written
+// with the groups known from the repos flushed so far. Close regroups
+// across the whole batch and writes again the rows whose group changed,
+// e.g. an earlier repo's PR that a later fork duplicated. Every row stays
+// in memory until Close.
+type Dedupe struct {
+	next Sink
+
+	mu   sync.Mutex
+	rows []types.PRRow
+	// written is the group each row was last written with, by index into
+	// rows; rows not written yet are absent.
+	written map[int]string
+}
+
+// NewDedupe returns a sink grouping rows before passing them to next.
+func NewDedupe(next Sink) *Dedupe {
+	return &Dedupe{next: next, written: make(map[int]string)}
+}
+
+func (d *Dedupe) Write(_ context.Context, row types.PRRow) error {
+	d.mu.Lock()
+	d.rows = append(d.rows, row)
+	d.mu.Unlock()
+	return nil
+}
+
+// Flush writes the rows of owner/repo to the wrapped sink, flushing it
+// too if it is Buffered, and reports each row that could not be stored.
+func (d *Dedupe) Flush(ctx context.Context, owner, repo string) []RowError {
+	key := repoKey(owner, repo)
+	d.mu.Lock()
+	var rows []types.PRRow
+	for i, g := range DedupGroups(d.rows) {
+		if _, ok := d.written[i]; ok || repoKey(d.rows[i].Owner, d.rows[i].Repo) != key {
+			continue
+		}
+		d.rows[i].DedupGroup = g
+		d.written[i] = g
+		rows = append(rows, d.rows[i])
+	}
+	d.mu.Unlock()
+
+	errs := storeEach(ctx, rows, d.next.Write)
+	if b, ok := d.next.(Buffered); ok {
+		errs = append(errs, b.Flush(ctx, owner, repo)...)
+	}
+	return errs
+}
+
+// Close writes the rows no Flush covered and those whose group changed
+// since they were written, then closes the wrapped sink. A failed row does
+// not stop the others. Close has no context of its own, so the writes
+// can't be cancelled.
+func (d *Dedupe) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var rows []types.PRRow
+	for i, g := range DedupGroups(d.rows) {
+		if w, ok := d.written[i]; ok && w == g {
+			continue
+		}
+		d.rows[i].DedupGroup = g
+		rows = append(rows, d.rows[i])
+	}
+	d.rows, d.written = nil, make(map[int]string)
+
+	errs := []error{joinRowErrors(storeEach(context.Background(), rows, d.next.Write))}
+	errs = append(errs, d.next.Close())
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestDedupGroupsByHeadSHA(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []types.PRRow{
+		{ID: 1, Owner: "up", Repo: "lib", Author: "alice", HeadSHA: "abc", CreatedAt: day.Add(time.Hour)},
+		{ID: 9, Owner: "fork", Repo: "lib", Author: "alice", HeadSHA: "abc", CreatedAt: day},
+		{ID: 2, Owner: "up", Repo: "lib", Author: "bob", HeadSHA: "abc", CreatedAt: day},
+		{ID: 3, Owner: "up", Repo: "lib", Author: "alice", HeadSHA: "def", CreatedAt: day},
+	}
+	got := DedupGroups(rows)
+	want := []string{"fork/lib#9", "fork/lib#9", "", ""}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d group = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// recordSink keeps every written row and fails rows numbered in fail.
+type recordSink struct {
+	mu     sync.Mutex
+	rows   []types.PRRow
+	fail   map[int]bool
+	closed bool
+}
+
+func (s *recordSink) Write(_ context.Context, row types.PRRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail[row.ID] {
+		return errors.New("refused")
+	}
+	s.rows = append(s.rows, row)
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestDedupeFlushesPerRepo(t *testing.T) {
+	next := &recordSink{fail: map[int]bool{2: true}}
+	d := NewDedupe(next)
+	ctx := context.Background()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, row := range []types.PRRow{
+		{ID: 1, Owner: "up", Repo: "lib", Author: "alice", HeadSHA: "abc", CreatedAt: day},
+		{ID: 2, Owner: "up", Repo: "lib", Author: "bob"},
+		{ID: 3, Owner: "up", Repo: "lib", Author: "carol"},
+	} {
+		d.Write(ctx, row)
+	}
+	errs := d.Flush(ctx, "up", "lib")
+	if len(errs) != 1 || errs[0].Number != 2 {
+		t.Fatalf("Flush = %v, want only #2 failed", errs)
+	}
+	if len(next.rows) != 2 || next.rows[0].DedupGroup != "" {
+		t.Fatalf("after first Flush got %+v, want #1 and #3 ungrouped", next.rows)
+	}
+
+	d.Write(ctx, types.PRRow{ID: 7, Owner: "fork", Repo: "lib", Author: "alice", HeadSHA: "abc", CreatedAt: day.Add(time.Hour)})
+	if errs := d.Flush(ctx, "fork", "lib"); len(errs) != 0 {
+		t.Fatalf("Flush(fork) = %v", errs)
+	}
+	if last := next.rows[len(next.rows)-1]; last.ID != 7 || last.DedupGroup != "up/lib#1" {
+		t.Fatalf("fork row = %+v, want grouped under up/lib#1", last)
+	}
+
+	n := len(next.rows)
+	// #2's failure was reported by its Flush already.
+	if err := d.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if !next.closed {
+		t.Error("Close did not close the wrapped sink")
+	}
+	rewritten := next.rows[n:]
+	if len(rewritten) != 1 || rewritten[0].ID != 1 || rewritten[0].DedupGroup != "up/lib#1" {
+		t.Errorf("Close rewrote %+v, want only #1 with its new group", rewritten)
+	}
+}
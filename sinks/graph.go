@@ -0,0 +1,99 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// ReviewEdge is an author → reviewer edge of the collaboration graph,
+// weighted by the number of the author's PRs the reviewer reviewed.
+type ReviewEdge struct {
+	Author   string
+	Reviewer string
+	PRs      int
+}
+
+// ReviewEdges builds the collaboration graph from rows with reviewers,
+// sorted by descending weight, then author and reviewer. PRs of deleted
+// authors are skipped.
+func ReviewEdges(rows []types.PRRow) []ReviewEdge {
+	weights := make(map[[2]string]int)
+	for _, r := range rows {
+		if r.Author == "" {
+			continue
+		}
+		for _, rev := range r.Reviewers {
+			weights[[2]string{r.Author, rev}]++
+		}
+	}
+	edges := make([]ReviewEdge, 0, len(weights))
+	for k, n := range weights {
+		edges = append(edges, ReviewEdge{Author: k[0], Reviewer: k[1], PRs: n})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].PRs != edges[j].PRs {
+			return edges[i].PRs > edges[j].PRs
+		}
+		if edges[i].Author != edges[j].Author {
+			return edges[i].Author < edges[j].Author
+		}
+		return edges[i].Reviewer < edges[j].Reviewer
+	})
+	return edges
+}
+
+// WriteDOT renders edges as a GraphViz digraph labelled with PR counts.
+func WriteDOT(w io.Writer, edges []ReviewEdge) error {
+	if _, err := fmt.Fprintln(w, "digraph reviews {"); err != nil {
+		return err
+	}
+	for _, e := range edges {
+		if _, err := fmt.Fprintf(w, "  %s -> %s [weight=%d, label=\"%d\"];\n", strconv.Quote(e.Author), strconv.Quote(e.Reviewer), e.PRs, e.PRs); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// Graph collects authors and reviewers and writes the collaboration graph
+// as DOT to a file on Close.
+type Graph struct {
+	path string
+
+	mu   sync.Mutex
+	rows []types.PRRow
+}
+
+// NewGraph returns a sink writing the graph to path.
+func NewGraph(path string) *Graph {
+	return &Graph{path: path}
+}
+
+func (g *Graph) Write(_ context.Context, row types.PRRow) error {
+	g.mu.Lock()
+	g.rows = append(g.rows, types.PRRow{Author: row.Author, Reviewers: row.Reviewers})
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *Graph) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f, err := os.Create(g.path)
+	if err != nil {
+		return err
+	}
+	if err := WriteDOT(f, ReviewEdges(g.rows)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,38 @@
+package sinks
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestReviewEdges(t *testing.T) {
+	rows := []types.PRRow{
+		{Author: "alice", Reviewers: []string{"bob", "carol"}},
+		{Author: "alice", Reviewers: []string{"bob"}},
+		{Author: "bob", Reviewers: []string{"alice"}},
+		{Author: "dave"},
+		{Author: "", Reviewers: []string{"bob"}}, // deleted author
+	}
+	want := []ReviewEdge{
+		{Author: "alice", Reviewer: "bob", PRs: 2},
+		{Author: "alice", Reviewer: "carol", PRs: 1},
+		{Author: "bob", Reviewer: "alice", PRs: 1},
+	}
+	if got := ReviewEdges(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReviewEdges = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, []ReviewEdge{{Author: "alice", Reviewer: "bot[bot]", PRs: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "digraph reviews {\n  \"alice\" -> \"bot[bot]\" [weight=3, label=\"3\"];\n}\n"
+	if buf.String() != want {
+		t.Errorf("WriteDOT =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,166 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// JSONL appends rows as JSON lines to dated files in a directory, e.g.
+// prs-2024-06-01.jsonl, starting a new file each UTC day. With a rotation
+// size, a file that would grow past it is closed and the day continues in
+// prs-2024-06-01.1.jsonl, prs-2024-06-01.2.jsonl, and so on. Existing files
+// are appended to, so repeated runs on the same day never overwrite data.
+type JSONL struct {
+	dir        string
+	rotateSize int64
+	now        func() time.Time
+
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	day  string
+	seq  int
+	size int64
+}
+
+// NewJSONL returns a sink writing under dir, which is created if needed.
+// rotateSize is in bytes; 0 rotates daily only.
+func NewJSONL(dir string, rotateSize int64) (*JSONL, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &JSONL{dir: dir, rotateSize: rotateSize, now: time.Now}, nil
+}
+
+func (j *JSONL) Write(_ context.Context, row types.PRRow) error {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	day := j.now().UTC().Format("2006-01-02")
+	if j.f == nil || day != j.day {
+		if err := j.open(day, 0, int64(len(line))); err != nil {
+			return err
+		}
+	} else if j.full(int64(len(line))) {
+		if err := j.open(day, j.seq+1, int64(len(line))); err != nil {
+			return err
+		}
+	}
+	n, err := j.w.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+// full reports whether adding n bytes would push a non-empty file past the
+// rotation size. A single oversized row still gets a file of its own.
+func (j *JSONL) full(n int64) bool {
+	return j.rotateSize > 0 && j.size > 0 && j.size+n > j.rotateSize
+}
+
+// open closes the current file and opens the first file for day, from seq
+// on, that can take another n bytes.
+func (j *JSONL) open(day string, seq int, n int64) error {
+	if err := j.closeFile(); err != nil {
+		return err
+	}
+	for ; ; seq++ {
+		f, err := os.OpenFile(j.path(day, seq), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		j.f, j.w, j.day, j.seq, j.size = f, bufio.NewWriter(f), day, seq, info.Size()
+		if !j.full(n) {
+			return nil
+		}
+		if err := j.closeFile(); err != nil {
+			return err
+		}
+	}
+}
+
+func (j *JSONL) path(day string, seq int) string {
+	if seq == 0 {
+		return filepath.Join(j.dir, fmt.Sprintf("prs-%s.jsonl", day))
+	}
+	return filepath.Join(j.dir, fmt.Sprintf("prs-%s.%d.jsonl", day, seq))
+}
+
+// closeFile flushes and closes the current file, if any.
+func (j *JSONL) closeFile() error {
+	if j.f == nil {
+		return nil
+	}
+	ferr := j.w.Flush()
+	cerr := j.f.Close()
+	j.f, j.w = nil, nil
+	if ferr != nil {
+		return ferr
+	}
+	return cerr
+}
+
+func (j *JSONL) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.closeFile()
+}
+
+// PrettyJSON buffers every row and, on Close, writes them as one indented
+// JSON array to prs-<time>.json in a directory. Unlike JSONL it holds the
+// whole run in memory and the file is only readable once complete, so it
+// suits small or debugging runs.
+type PrettyJSON struct {
+	dir string
+	now func() time.Time
+
+	mu   sync.Mutex
+	rows []types.PRRow
+}
+
+// NewPrettyJSON returns a sink writing under dir, which is created if
+// needed.
+func NewPrettyJSON(dir string) (*PrettyJSON, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &PrettyJSON{dir: dir, now: time.Now, rows: []types.PRRow{}}, nil
+}
+
+func (p *PrettyJSON) Write(_ context.Context, row types.PRRow) error {
+	p.mu.Lock()
+	p.rows = append(p.rows, row)
+	p.mu.Unlock()
+	return nil
+}
+
+// Close writes the array, named by the current UTC time so earlier files
+// are never overwritten.
+func (p *PrettyJSON) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, err := json.MarshalIndent(p.rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	name := fmt.Sprintf("prs-%s.json", p.now().UTC().Format("20060102T150405Z"))
+	return os.WriteFile(filepath.Join(p.dir, name), b, 0o644)
+}
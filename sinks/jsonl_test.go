@@ -0,0 +1,153 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestJSONLRotation(t *testing.T) {
+	day1 := time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	rowSize := func() int64 {
+		b, _ := json.Marshal(types.PRRow{ID: 1})
+		return int64(len(b)) + 1
+	}()
+
+	tests := []struct {
+		name       string
+		rotateSize int64
+		days       []time.Time // one entry per row written
+		want       map[string][]int
+	}{
+		{
+			name:       "daily only",
+			rotateSize: 0,
+			days:       []time.Time{day1, day1, day1, day2},
+			want: map[string][]int{
+				"prs-2024-06-01.jsonl": {1, 2, 3},
+				"prs-2024-06-02.jsonl": {4},
+			},
+		},
+		{
+			name:       "two rows per file",
+			rotateSize: 2 * rowSize,
+			days:       []time.Time{day1, day1, day1, day1, day1},
+			want: map[string][]int{
+				"prs-2024-06-01.jsonl":   {1, 2},
+				"prs-2024-06-01.1.jsonl": {3, 4},
+				"prs-2024-06-01.2.jsonl": {5},
+			},
+		},
+		{
+			name:       "new day restarts the sequence",
+			rotateSize: 2 * rowSize,
+			days:       []time.Time{day1, day1, day1, day2},
+			want: map[string][]int{
+				"prs-2024-06-01.jsonl":   {1, 2},
+				"prs-2024-06-01.1.jsonl": {3},
+				"prs-2024-06-02.jsonl":   {4},
+			},
+		},
+		{
+			name:       "oversized row gets its own file",
+			rotateSize: rowSize / 2,
+			days:       []time.Time{day1, day1},
+			want: map[string][]int{
+				"prs-2024-06-01.jsonl":   {1},
+				"prs-2024-06-01.1.jsonl": {2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			j, err := NewJSONL(dir, tt.rotateSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, day := range tt.days {
+				j.now = func() time.Time { return day }
+				if err := j.Write(context.Background(), types.PRRow{ID: i + 1}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := j.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Errorf("got %d files, want %d", len(entries), len(tt.want))
+			}
+			for _, e := range entries {
+				want, ok := tt.want[e.Name()]
+				if !ok {
+					t.Errorf("unexpected file %s", e.Name())
+					continue
+				}
+				got := readJSONLIDs(t, filepath.Join(dir, e.Name()))
+				if len(got) != len(want) {
+					t.Errorf("%s has ids %v, want %v", e.Name(), got, want)
+					continue
+				}
+				for i := range got {
+					if got[i] != want[i] {
+						t.Errorf("%s has ids %v, want %v", e.Name(), got, want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestJSONLAppendsToExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+	for run := 1; run <= 2; run++ {
+		j, err := NewJSONL(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.now = now
+		if err := j.Write(context.Background(), types.PRRow{ID: run}); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := readJSONLIDs(t, filepath.Join(dir, "prs-2024-06-01.jsonl"))
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("ids = %v, want [1 2]", got)
+	}
+}
+
+func readJSONLIDs(t *testing.T, path string) []int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ids []int
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r types.PRRow
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
@@ -0,0 +1,147 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBatch is how many rows of a repo are published in one produce call.
+const kafkaBatch = 100
+
+// kafkaWriter publishes messages to a topic, as *kafka.Writer does.
+// WriteMessages returns once the brokers acknowledged every message, or
+// with an error if any was not.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Kafka publishes each row as a JSON message keyed by owner/repo/number,
+// so a PR always lands on the same partition and compacted topics keep
+// its latest row. Rows are published per repo kafkaBatch at a time; a
+// Write that fills a batch blocks until the brokers acknowledge it, so a
+// slow cluster slows the scrape instead of rows piling up in memory.
+type Kafka struct {
+	w    kafkaWriter
+	rows *batcher
+}
+
+// NewKafka connects to the comma-separated brokers and looks up topic,
+// which has to exist already.
+func NewKafka(ctx context.Context, brokers, topic string) (*Kafka, error) {
+	var addrs []string
+	for _, b := range strings.Split(brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			addrs = append(addrs, b)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers given")
+	}
+	if err := checkKafkaTopic(ctx, addrs, topic); err != nil {
+		return nil, err
+	}
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    kafkaBatch,
+		// Rows arrive in batches already; don't hold a partial one back.
+		BatchTimeout: 10 * time.Millisecond,
+	}
+	log.Info().Strs("brokers", addrs).Str("topic", topic).Msg("connected to Kafka")
+	return newKafka(w), nil
+}
+
+// checkKafkaTopic fails unless one of brokers answers and knows topic, so
+// a typo is reported at startup rather than on the first batch.
+func checkKafkaTopic(ctx context.Context, brokers []string, topic string) error {
+	var err error
+	for _, addr := range brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", addr); err != nil {
+			continue
+		}
+		_, err = conn.ReadPartitions(topic)
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("kafka topic %s: %w", topic, err)
+}
+
+func newKafka(w kafkaWriter) *Kafka {
+	k := &Kafka{w: w}
+	k.rows = newBatcher(kafkaBatch, k.store)
+	return k
+}
+
+// Write accepts row; it is published once its repo's batch fills or on
+// Flush.
+func (k *Kafka) Write(ctx context.Context, row types.PRRow) error {
+	k.rows.add(ctx, row)
+	return nil
+}
+
+func (k *Kafka) Flush(ctx context.Context, owner, repo string) []RowError {
+	return k.rows.flush(ctx, owner, repo)
+}
+
+// Close publishes what is left and closes the broker connections.
+func (k *Kafka) Close() error {
+	err := joinRowErrors(k.rows.flushAll(context.Background()))
+	if cerr := k.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// store publishes rows in one call, falling back to one call per row if it
+// fails, so the rows that cannot be published are the ones reported.
+func (k *Kafka) store(ctx context.Context, rows []types.PRRow) []RowError {
+	msgs := make([]kafka.Message, 0, len(rows))
+	for _, row := range rows {
+		msg, err := kafkaRow(row)
+		if err != nil {
+			return storeEach(ctx, rows, k.publish)
+		}
+		msgs = append(msgs, msg)
+	}
+	err := k.w.WriteMessages(ctx, msgs...)
+	if err == nil {
+		return nil
+	}
+	if len(rows) == 1 {
+		return []RowError{{Owner: rows[0].Owner, Repo: rows[0].Repo, Number: rows[0].ID, Err: err}}
+	}
+	log.Warn().Err(err).Int("rows", len(rows)).Msg("Kafka batch publish failed; retrying rows one at a time")
+	return storeEach(ctx, rows, k.publish)
+}
+
+func (k *Kafka) publish(ctx context.Context, row types.PRRow) error {
+	msg, err := kafkaRow(row)
+	if err != nil {
+		return err
+	}
+	return k.w.WriteMessages(ctx, msg)
+}
+
+// kafkaRow is row's message: the same JSON object as -output jsonl, keyed
+// by owner/repo/number.
+func kafkaRow(row types.PRRow) (kafka.Message, error) {
+	value, err := json.Marshal(row)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	key := fmt.Sprintf("%s/%s/%d", row.Owner, row.Repo, row.ID)
+	return kafka.Message{Key: []byte(key), Value: value}, nil
+}
@@ -0,0 +1,78 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/segmentio/kafka-go"
+)
+
+// mockKafkaWriter records published messages and refuses any call that
+// includes a key in reject.
+type mockKafkaWriter struct {
+	calls  int
+	msgs   []kafka.Message
+	reject map[string]bool
+	closed bool
+}
+
+func (w *mockKafkaWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.calls++
+	for _, m := range msgs {
+		if w.reject[string(m.Key)] {
+			return errors.New("rejected")
+		}
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *mockKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestKafkaPublishesKeyedJSON(t *testing.T) {
+	w := &mockKafkaWriter{reject: map[string]bool{"octo/demo/2": true}}
+	k := newKafka(w)
+	ctx := context.Background()
+	for _, n := range []int{1, 2, 3} {
+		if err := k.Write(ctx, types.PRRow{ID: n, Owner: "octo", Repo: "demo", Author: "alice"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(w.msgs) != 0 {
+		t.Fatalf("published %d messages before Flush, want 0", len(w.msgs))
+	}
+
+	errs := k.Flush(ctx, "octo", "demo")
+	if len(errs) != 1 || errs[0].Number != 2 {
+		t.Fatalf("Flush = %v, want only #2 failed", errs)
+	}
+	if len(w.msgs) != 2 {
+		t.Fatalf("published %d messages, want 2", len(w.msgs))
+	}
+	for i, want := range []int{1, 3} {
+		m := w.msgs[i]
+		if key := string(m.Key); key != "octo/demo/"+[]string{"1", "3"}[i] {
+			t.Errorf("message %d key = %q", i, key)
+		}
+		var row types.PRRow
+		if err := json.Unmarshal(m.Value, &row); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if row.ID != want || row.Owner != "octo" || row.Repo != "demo" || row.Author != "alice" {
+			t.Errorf("message %d payload = %+v", i, row)
+		}
+	}
+
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.closed {
+		t.Error("Close did not close the writer")
+	}
+}
@@ -0,0 +1,111 @@
+// Package sinks holds the destinations built PR rows are written to.
+package sinks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dickeyy/github-scraper/db"
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Sink receives built rows. Write may be called from several workers at
+// once; Close is called once, after the last Write of the whole run (or
+// batch), to flush anything buffered.
+type Sink interface {
+	Write(ctx context.Context, row types.PRRow) error
+	Close() error
+}
+
+// Postgres upserts each row into the prs table. It requires db.Init.
+type Postgres struct{}
+
+func (Postgres) Write(ctx context.Context, row types.PRRow) error {
+	return db.InsertPRRow(ctx, row)
+}
+
+func (Postgres) Close() error { return nil }
+
+// PostgresBatch buffers rows per repo and upserts them batch at a time
+// with db.InsertPRRows. A batch that fails is upserted again row by row,
+// so only the rows that cannot be stored are lost and reported. It
+// requires db.Init.
+type PostgresBatch struct {
+	rows *batcher
+}
+
+// NewPostgresBatch returns a PostgresBatch storing every batch rows.
+func NewPostgresBatch(batch int) *PostgresBatch {
+	if batch < 1 {
+		batch = 500
+	}
+	return &PostgresBatch{rows: newBatcher(batch, storePostgres)}
+}
+
+// Write accepts row; it is stored once its repo's batch fills or on Flush.
+func (p *PostgresBatch) Write(ctx context.Context, row types.PRRow) error {
+	p.rows.add(ctx, row)
+	return nil
+}
+
+func (p *PostgresBatch) Flush(ctx context.Context, owner, repo string) []RowError {
+	return p.rows.flush(ctx, owner, repo)
+}
+
+func (p *PostgresBatch) Close() error {
+	return joinRowErrors(p.rows.flushAll(context.Background()))
+}
+
+func storePostgres(ctx context.Context, rows []types.PRRow) []RowError {
+	err := db.InsertPRRows(ctx, rows)
+	if err == nil {
+		return nil
+	}
+	if len(rows) == 1 {
+		return []RowError{{Owner: rows[0].Owner, Repo: rows[0].Repo, Number: rows[0].ID, Err: err}}
+	}
+	log.Warn().Err(err).Int("rows", len(rows)).Msg("batch upsert failed; retrying rows one at a time")
+	return storeEach(ctx, rows, db.InsertPRRow)
+}
+
+// Multi writes every row to each of its sinks in order.
+type Multi []Sink
+
+func (m Multi) Write(ctx context.Context, row types.PRRow) error {
+	for _, s := range m {
+		if err := s.Write(ctx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every sink, even if some fail.
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Flush flushes the Buffered sinks among m, reporting each failed row
+// once even if several sinks failed to store it.
+func (m Multi) Flush(ctx context.Context, owner, repo string) []RowError {
+	var errs []RowError
+	seen := make(map[int]bool)
+	for _, s := range m {
+		b, ok := s.(Buffered)
+		if !ok {
+			continue
+		}
+		for _, e := range b.Flush(ctx, owner, repo) {
+			if !seen[e.Number] {
+				seen[e.Number] = true
+				errs = append(errs, e)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,44 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dickeyy/github-scraper/db"
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// SQLScript writes the statements the Postgres sink would run for each row
+// as a SQL script instead of executing them; see db.RenderPRRow.
+type SQLScript struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+// NewSQLScript writes the script to path, or to stdout for "-".
+func NewSQLScript(path string) (*SQLScript, error) {
+	if path == "-" {
+		return &SQLScript{w: os.Stdout}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLScript{w: f, c: f}, nil
+}
+
+func (s *SQLScript) Write(_ context.Context, row types.PRRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return db.RenderPRRow(s.w, row)
+}
+
+func (s *SQLScript) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
@@ -0,0 +1,122 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+	"github.com/rs/zerolog/log"
+)
+
+// streamDialAttempts bounds how often a Write tries to reach the consumer,
+// counting failed dials and dropped writes alike, before it gives up.
+const streamDialAttempts = 6
+
+// Stream writes rows as JSON lines to a TCP or Unix socket consumer as they
+// are produced. A slow consumer slows the scrape down: writes block until
+// the connection accepts them. When the consumer drops, the connection is
+// redialled with backoff and the row is sent again, so a row that was
+// partially sent before the drop may arrive truncated and then once more.
+type Stream struct {
+	network, addr string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewStream connects to addr, which is host:port for TCP or unix:/path for
+// a Unix socket. The consumer has to be listening already.
+func NewStream(ctx context.Context, addr string) (*Stream, error) {
+	s := &Stream{network: "tcp", addr: addr}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		s.network, s.addr = "unix", path
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func (s *Stream) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("stream %s %s: %w", s.network, s.addr, err)
+	}
+	return conn, nil
+}
+
+func (s *Stream) Write(ctx context.Context, row types.PRRow) error {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for attempt := 1; ; attempt++ {
+		if s.conn == nil {
+			conn, derr := s.dial(ctx)
+			if derr != nil {
+				if attempt >= streamDialAttempts {
+					return derr
+				}
+				if err := streamBackoff(ctx, attempt, derr, "stream consumer unreachable; backing off"); err != nil {
+					return err
+				}
+				continue
+			}
+			s.conn = conn
+			log.Info().Str("addr", s.addr).Msg("reconnected to stream consumer")
+		}
+		if _, err := s.conn.Write(line); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			if attempt >= streamDialAttempts {
+				return fmt.Errorf("stream %s %s: %w", s.network, s.addr, err)
+			}
+			if err := streamBackoff(ctx, attempt, err, "stream consumer dropped; reconnecting"); err != nil {
+				return err
+			}
+			continue
+		}
+		return nil
+	}
+}
+
+// streamBackoff logs msg and waits before the next attempt: 500ms, doubling
+// up to 10s.
+func streamBackoff(ctx context.Context, attempt int, err error, msg string) error {
+	base := time.Duration(500*(1<<uint(attempt-1))) * time.Millisecond
+	if base > 10*time.Second {
+		base = 10 * time.Second
+	}
+	log.Warn().Err(err).Int("attempt", attempt).Dur("sleep_for", base).Msg(msg)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(base):
+		return nil
+	}
+}
+
+// Close closes the connection. Rows are written unbuffered, so there is
+// nothing left to flush.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,81 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestStreamFramesRowsAsJSONLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		addr    func(t *testing.T) string
+	}{
+		{"tcp", "tcp", func(*testing.T) string { return "127.0.0.1:0" }},
+		{"unix", "unix", func(t *testing.T) string { return filepath.Join(t.TempDir(), "s.sock") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen(tt.network, tt.addr(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			got := make(chan []types.PRRow, 1)
+			go func() {
+				var rows []types.PRRow
+				defer func() { got <- rows }()
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					var r types.PRRow
+					if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+						t.Errorf("line %q: %v", sc.Text(), err)
+						return
+					}
+					rows = append(rows, r)
+				}
+			}()
+
+			addr := ln.Addr().String()
+			if tt.network == "unix" {
+				addr = "unix:" + addr
+			}
+			s, err := NewStream(context.Background(), addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 3; i++ {
+				// Titles with newlines must stay inside one JSON line.
+				row := types.PRRow{ID: i, Owner: "octo", Repo: "demo", Title: "line\nbreak"}
+				if err := s.Write(context.Background(), row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rows := <-got
+			if len(rows) != 3 {
+				t.Fatalf("consumer got %d rows, want 3", len(rows))
+			}
+			for i, r := range rows {
+				if r.ID != i+1 || r.Title != "line\nbreak" {
+					t.Errorf("row %d = #%d %q, want #%d in order", i, r.ID, r.Title, i+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// Table buffers rows and prints them as an aligned text table on Close.
+type Table struct {
+	w     io.Writer
+	limit int
+
+	mu   sync.Mutex
+	rows []types.PRRow
+}
+
+// NewTable returns a sink rendering to w, showing at most limit rows
+// (0 for all).
+func NewTable(w io.Writer, limit int) *Table {
+	return &Table{w: w, limit: limit}
+}
+
+func (t *Table) Write(_ context.Context, row types.PRRow) error {
+	t.mu.Lock()
+	t.rows = append(t.rows, row)
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Table) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return RenderTable(t.w, t.rows, t.limit)
+}
+
+// RenderTable writes rows as an aligned table, newest PR first within each
+// repo. At most limit rows are shown (0 for all), followed by a footer
+// counting the rest.
+func RenderTable(w io.Writer, rows []types.PRRow, limit int) error {
+	sorted := make([]types.PRRow, len(rows))
+	copy(sorted, rows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Owner != sorted[j].Owner {
+			return sorted[i].Owner < sorted[j].Owner
+		}
+		if sorted[i].Repo != sorted[j].Repo {
+			return sorted[i].Repo < sorted[j].Repo
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+
+	shown := sorted
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+
+	multiRepo := false
+	for _, r := range sorted {
+		if r.Owner != sorted[0].Owner || r.Repo != sorted[0].Repo {
+			multiRepo = true
+			break
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"PR", "AUTHOR", "+/-", "COMMENTS", "BOT%", "STATE"}
+	if multiRepo {
+		header = append([]string{"REPO"}, header...)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, r := range shown {
+		botPct := 0.0
+		if r.CommentCount > 0 {
+			botPct = 100 * float64(r.BotComments) / float64(r.CommentCount)
+		}
+		author := r.Author
+		if author == "" {
+			author = "-"
+		}
+		cells := []string{
+			fmt.Sprintf("#%d", r.ID),
+			author,
+			fmt.Sprintf("+%d/-%d", r.Additions, r.Deletions),
+			fmt.Sprintf("%d", r.CommentCount),
+			fmt.Sprintf("%.0f%%", botPct),
+			r.Status,
+		}
+		if multiRepo {
+			cells = append([]string{r.Owner + "/" + r.Repo}, cells...)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if more := len(sorted) - len(shown); more > 0 {
+		if _, err := fmt.Fprintf(w, "... and %d more\n", more); err != nil {
+			return err
+		}
+	}
+	return nil
+}
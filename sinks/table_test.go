@@ -0,0 +1,38 @@
+package sinks
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files under testdata")
+
+func TestRenderTable(t *testing.T) {
+	rows := []types.PRRow{
+		{ID: 7, Owner: "octo", Repo: "demo", Author: "alice", Additions: 120, Deletions: 30, LinesChanged: 150, CommentCount: 4, BotComments: 1, Status: "merged"},
+		{ID: 9, Owner: "octo", Repo: "demo", Author: "", Additions: 0, Deletions: 12, LinesChanged: 12, Status: "open"},
+		{ID: 3, Owner: "octo", Repo: "demo", Author: "dependabot[bot]", Additions: 2, Deletions: 2, LinesChanged: 4, CommentCount: 2, BotComments: 2, Status: "closed"},
+	}
+	var buf bytes.Buffer
+	if err := RenderTable(&buf, rows, 2); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "table.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("RenderTable output differs from %s:\n%s\nwant:\n%s", golden, buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,157 @@
+package sinks
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+// Weekly output formats.
+const (
+	WeeklyCSV  = "csv"
+	WeeklyJSON = "json"
+)
+
+// WeekBucket rolls up the PRs created in one ISO week.
+type WeekBucket struct {
+	// Week is the ISO week, e.g. "2024-W05"; Start is its Monday (UTC).
+	Week  string    `json:"week"`
+	Start time.Time `json:"start"`
+	PRs   int       `json:"prs"`
+	// Merged counts the week's PRs that are merged by now, not merges
+	// that happened during the week.
+	Merged             int     `json:"merged"`
+	Comments           int     `json:"comments"`
+	MedianLinesChanged float64 `json:"median_lines_changed"`
+}
+
+// BucketByWeek groups rows by the ISO week (UTC) they were created in,
+// oldest week first. With fillGaps, weeks without PRs between the first
+// and last are included as zero buckets; otherwise they are skipped.
+func BucketByWeek(rows []types.PRRow, fillGaps bool) []WeekBucket {
+	lines := make(map[time.Time][]float64)
+	buckets := make(map[time.Time]*WeekBucket)
+	for _, r := range rows {
+		start := weekStart(r.CreatedAt)
+		b := buckets[start]
+		if b == nil {
+			b = &WeekBucket{Week: isoWeek(start), Start: start}
+			buckets[start] = b
+		}
+		b.PRs++
+		if r.Status == "merged" {
+			b.Merged++
+		}
+		b.Comments += r.CommentCount
+		lines[start] = append(lines[start], float64(r.LinesChanged))
+	}
+
+	starts := make([]time.Time, 0, len(buckets))
+	for s := range buckets {
+		starts = append(starts, s)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	var out []WeekBucket
+	for i, s := range starts {
+		if fillGaps && i > 0 {
+			for gap := starts[i-1].AddDate(0, 0, 7); gap.Before(s); gap = gap.AddDate(0, 0, 7) {
+				out = append(out, WeekBucket{Week: isoWeek(gap), Start: gap})
+			}
+		}
+		b := *buckets[s]
+		b.MedianLinesChanged = Median(lines[s])
+		out = append(out, b)
+	}
+	return out
+}
+
+// weekStart returns midnight UTC on the Monday of t's ISO week.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// Median returns the median of vs (0 when empty), reordering vs.
+func Median(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	sort.Float64s(vs)
+	mid := len(vs) / 2
+	if len(vs)%2 == 1 {
+		return vs[mid]
+	}
+	return (vs[mid-1] + vs[mid]) / 2
+}
+
+// Weekly buffers rows and writes their weekly rollup on Close, as CSV with
+// a header or as one JSON object per line. Rows of every repo in the run
+// share the buckets.
+type Weekly struct {
+	w        io.Writer
+	format   string
+	fillGaps bool
+
+	mu   sync.Mutex
+	rows []types.PRRow
+}
+
+// NewWeekly returns a sink rolling rows up into w in format (WeeklyCSV or
+// WeeklyJSON).
+func NewWeekly(w io.Writer, format string, fillGaps bool) (*Weekly, error) {
+	if format != WeeklyCSV && format != WeeklyJSON {
+		return nil, fmt.Errorf("unknown weekly format %q; expected %s or %s", format, WeeklyCSV, WeeklyJSON)
+	}
+	return &Weekly{w: w, format: format, fillGaps: fillGaps}, nil
+}
+
+func (s *Weekly) Write(_ context.Context, row types.PRRow) error {
+	s.mu.Lock()
+	s.rows = append(s.rows, row)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Weekly) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := BucketByWeek(s.rows, s.fillGaps)
+	if s.format == WeeklyJSON {
+		enc := json.NewEncoder(s.w)
+		for _, b := range buckets {
+			if err := enc.Encode(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	cw := csv.NewWriter(s.w)
+	cw.Write([]string{"week", "start", "prs", "merged", "comments", "median_lines_changed"})
+	for _, b := range buckets {
+		cw.Write([]string{
+			b.Week,
+			b.Start.Format(time.DateOnly),
+			strconv.Itoa(b.PRs),
+			strconv.Itoa(b.Merged),
+			strconv.Itoa(b.Comments),
+			strconv.FormatFloat(b.MedianLinesChanged, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,97 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dickeyy/github-scraper/types"
+)
+
+func TestBucketByWeek(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC) }
+	rows := []types.PRRow{
+		// 2024-W01: odd count, median is the middle value.
+		{CreatedAt: at(1, 9), Status: "merged", CommentCount: 2, LinesChanged: 10},
+		{CreatedAt: at(3, 9), Status: "open", CommentCount: 1, LinesChanged: 30},
+		{CreatedAt: at(7, 23), Status: "merged", LinesChanged: 20}, // Sunday, still W01
+		// 2024-W02 has no PRs.
+		// 2024-W03: even count, median averages the middle two.
+		{CreatedAt: at(15, 0), Status: "closed", CommentCount: 4, LinesChanged: 40},
+		{CreatedAt: at(21, 12), Status: "merged", CommentCount: 3, LinesChanged: 5},
+	}
+	w01 := WeekBucket{Week: "2024-W01", Start: at(1, 0), PRs: 3, Merged: 2, Comments: 3, MedianLinesChanged: 20}
+	w02 := WeekBucket{Week: "2024-W02", Start: at(8, 0)}
+	w03 := WeekBucket{Week: "2024-W03", Start: at(15, 0), PRs: 2, Merged: 1, Comments: 7, MedianLinesChanged: 22.5}
+
+	tests := []struct {
+		name     string
+		fillGaps bool
+		want     []WeekBucket
+	}{
+		{"skip gaps", false, []WeekBucket{w01, w03}},
+		{"fill gaps", true, []WeekBucket{w01, w02, w03}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BucketByWeek(rows, tt.fillGaps)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d buckets, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("bucket %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name string
+		vs   []float64
+		want float64
+	}{
+		{"empty", nil, 0},
+		{"one", []float64{7}, 7},
+		{"odd", []float64{9, 1, 5}, 5},
+		{"even", []float64{8, 2, 4, 6}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Median(tt.vs); got != tt.want {
+				t.Errorf("Median(%v) = %v, want %v", tt.vs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeeklyCSV(t *testing.T) {
+	var buf bytes.Buffer
+	s, err := NewWeekly(&buf, WeeklyCSV, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []int{2, 16} {
+		row := types.PRRow{CreatedAt: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC), Status: "merged", LinesChanged: day}
+		if err := s.Write(context.Background(), row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"week,start,prs,merged,comments,median_lines_changed",
+		"2024-W01,2024-01-01,1,1,0,2",
+		"2024-W02,2024-01-08,0,0,0,0",
+		"2024-W03,2024-01-15,1,1,0,16",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
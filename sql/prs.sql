@@ -1,3 +1,5 @@
+-- With -partitioned, prs is created PARTITION BY LIST (owner) instead, with
+-- PRIMARY KEY (id, owner), and pr_deployments.pr_id has no foreign key.
 CREATE TABLE IF NOT EXISTS prs (
     id TEXT PRIMARY KEY,
     repo TEXT NOT NULL,
@@ -6,5 +8,87 @@ CREATE TABLE IF NOT EXISTS prs (
     bot_comments INTEGER NOT NULL DEFAULT 0,
     lines_changed INTEGER NOT NULL,
     status TEXT NOT NULL DEFAULT 'open',
+    created_at TIMESTAMPTZ NOT NULL,
+    merge_commit_sha TEXT,
+    base_sha TEXT,
+    head_sha TEXT,
+    author_comments INTEGER NOT NULL DEFAULT 0,
+    open_duration_days DOUBLE PRECISION,
+    files_added INTEGER,
+    files_modified INTEGER,
+    files_removed INTEGER,
+    github_comment_count INTEGER,
+    stats_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    body_word_count INTEGER,
+    checklist_total INTEGER,
+    checklist_checked INTEGER,
+    checks TEXT[],
+    auto_merged BOOLEAN,
+    auto_merge_enabled_by TEXT,
+    commit_count INTEGER,
+    commit_source TEXT,
+    bot_comment_breakdown JSONB,
+    reviewers TEXT[],
+    node_id TEXT UNIQUE,
+    review_request_events INTEGER,
+    base_ref TEXT,
+    last_run_id TEXT,
+    title TEXT,
+    body TEXT,
+    mergeable TEXT,
+    resolved_threads INTEGER,
+    unresolved_threads INTEGER,
+    comments_first_day INTEGER NOT NULL DEFAULT 0,
+    comments_first_week INTEGER NOT NULL DEFAULT 0,
+    review_response_latency INTEGER,
+    comments_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    dedup_group TEXT,
+    file_types JSONB,
+    base_protected BOOLEAN,
+    requires_approving_reviews BOOLEAN,
+    required_approving_reviews INTEGER,
+    comment_sentiment DOUBLE PRECISION,
+    origin TEXT,
+    closed_at TIMESTAMPTZ,
+    merged_at TIMESTAMPTZ,
+    author TEXT NOT NULL DEFAULT '',
+    labels TEXT[] NOT NULL DEFAULT '{}',
+    approved_reviews INTEGER,
+    changes_requested_reviews INTEGER,
+    commented_reviews INTEGER,
+    issue_comments INTEGER NOT NULL DEFAULT 0,
+    review_comments INTEGER NOT NULL DEFAULT 0,
+    prev_run_id TEXT,
+    prev_status TEXT,
+    prev_comment_count INTEGER,
+    state TEXT,
+    additions INTEGER,
+    deletions INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS pr_deployments (
+    pr_id TEXT NOT NULL REFERENCES prs(id) ON DELETE CASCADE,
+    environment TEXT NOT NULL,
     created_at TIMESTAMPTZ NOT NULL
-);
\ No newline at end of file
+);
+CREATE INDEX IF NOT EXISTS pr_deployments_pr_id_idx ON pr_deployments (pr_id);
+
+CREATE TABLE IF NOT EXISTS repos (
+    owner TEXT NOT NULL,
+    name TEXT NOT NULL,
+    license TEXT,
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    total_issues INTEGER,
+    open_issues INTEGER,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (owner, name)
+);
+
+CREATE TABLE IF NOT EXISTS scrape_checkpoints (
+    owner TEXT NOT NULL,
+    repo TEXT NOT NULL,
+    pr_number INTEGER NOT NULL,
+    run_id TEXT,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (owner, repo)
+);
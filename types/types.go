@@ -1,14 +1,168 @@
 package types
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 type PRRow struct {
-	ID           int       `json:"id"`
-	Repo         string    `json:"repo"`
-	Owner        string    `json:"owner"`
-	CommentCount int       `json:"comment_count"`
-	BotComments  int       `json:"bot_comments"`
-	LinesChanged int       `json:"lines_changed"`
-	Status       string    `json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID     int    `json:"id"`
+	NodeID string `json:"node_id"`
+	Repo   string `json:"repo"`
+	Owner  string `json:"owner"`
+	Author string `json:"author"`
+	// Origin is one of human, bot, fork-external, or automation.
+	Origin       string `json:"origin"`
+	CommentCount int    `json:"comment_count"`
+	// IssueComments and ReviewComments split CommentCount into
+	// conversation and diff comments.
+	IssueComments      int  `json:"issue_comments"`
+	ReviewComments     int  `json:"review_comments"`
+	GitHubCommentCount *int `json:"github_comment_count"`
+	BotComments        int  `json:"bot_comments"`
+	AuthorComments     int  `json:"author_comments"`
+	CommentsFirstDay   int  `json:"comments_first_day"`
+	CommentsFirstWeek  int  `json:"comments_first_week"`
+	CommentsTruncated  bool `json:"comments_truncated"`
+	// CommentSentiment is the average comment score, -1 to 1; nil unless
+	// comments were scored.
+	CommentSentiment *float64 `json:"comment_sentiment"`
+	// LinesChanged is Additions plus Deletions.
+	LinesChanged   int  `json:"lines_changed"`
+	Additions      int  `json:"additions"`
+	Deletions      int  `json:"deletions"`
+	StatsTruncated bool `json:"stats_truncated"`
+	// FilesAdded, FilesModified and FilesRemoved count changed files by
+	// status; nil unless files were fetched.
+	FilesAdded    *int `json:"files_added"`
+	FilesModified *int `json:"files_modified"`
+	FilesRemoved  *int `json:"files_removed"`
+	// FileTypes counts changed files by extension; nil unless files were
+	// fetched.
+	FileTypes map[string]int `json:"file_types,omitempty"`
+	Status    string         `json:"status"`
+	// BodyWordCount and the checklist counts describe the PR description;
+	// nil unless it was fetched.
+	BodyWordCount    *int `json:"body_word_count"`
+	ChecklistTotal   *int `json:"checklist_total"`
+	ChecklistChecked *int `json:"checklist_checked"`
+	// State is open, closed (without merging) or merged, as Status; it
+	// mirrors GitHub's name for the field.
+	State string `json:"state"`
+	// Title and Body are only stored on request, possibly redacted.
+	Title     string    `json:"title,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ClosedAt is nil while the PR is open, MergedAt unless it was merged.
+	ClosedAt           *time.Time `json:"closed_at"`
+	MergedAt           *time.Time `json:"merged_at"`
+	OpenDuration       float64    `json:"open_duration_days"`
+	MergeCommitSHA     string     `json:"merge_commit_sha"`
+	BaseRef            string     `json:"base_ref"`
+	Mergeable          string     `json:"mergeable"`
+	BaseSHA            string     `json:"base_sha"`
+	HeadSHA            string     `json:"head_sha"`
+	Checks             []string   `json:"checks"`
+	Labels             []string   `json:"labels"`
+	AutoMerged         *bool      `json:"auto_merged"`
+	AutoMergeEnabledBy string     `json:"auto_merge_enabled_by"`
+	CommitCount        *int       `json:"commit_count"`
+	CommitSource       string     `json:"commit_source"`
+	// BotCommentBreakdown maps bot logins to their comment counts; nil
+	// unless the breakdown was requested.
+	BotCommentBreakdown map[string]int `json:"bot_comment_breakdown,omitempty"`
+	Reviewers           []string       `json:"reviewers"`
+	ReviewRequestEvents *int           `json:"review_request_events"`
+	// ReviewResponseLatency is in seconds.
+	ReviewResponseLatency *int `json:"review_response_latency"`
+	ResolvedThreads       *int `json:"resolved_threads"`
+	UnresolvedThreads     *int `json:"unresolved_threads"`
+	// The review counts are nil unless requested.
+	ApprovedReviews         *int   `json:"approved_reviews"`
+	ChangesRequestedReviews *int   `json:"changes_requested_reviews"`
+	CommentedReviews        *int   `json:"commented_reviews"`
+	LastRunID               string `json:"last_run_id,omitempty"`
+	// BaseProtected and the approval requirements describe the base
+	// branch's current protection rule; nil when unknown. The approval
+	// fields are also nil for unprotected branches.
+	BaseProtected            *bool `json:"base_protected"`
+	RequiresApprovingReviews *bool `json:"requires_approving_reviews"`
+	RequiredApprovingReviews *int  `json:"required_approving_reviews"`
+	// DedupGroup is set by -dedupe-across-forks; see sinks.DedupGroups.
+	DedupGroup string `json:"dedup_group,omitempty"`
+	// Deployments is nil unless deployments were fetched; an empty slice
+	// means the merge commit has none.
+	Deployments []Deployment `json:"deployments,omitempty"`
+}
+
+// Deployment is a deployment of a PR's merge commit.
+type Deployment struct {
+	Environment string    `json:"environment"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate checks the row's invariants and returns an error describing every
+// violation, or nil if the row is sane.
+func (r PRRow) Validate() error {
+	var errs []error
+	if r.ID <= 0 {
+		errs = append(errs, fmt.Errorf("id must be positive, got %d", r.ID))
+	}
+	if r.Owner == "" || r.Repo == "" {
+		errs = append(errs, errors.New("owner and repo must be set"))
+	}
+	counts := []struct {
+		name string
+		v    int
+	}{
+		{"comment_count", r.CommentCount},
+		{"issue_comments", r.IssueComments},
+		{"review_comments", r.ReviewComments},
+		{"bot_comments", r.BotComments},
+		{"author_comments", r.AuthorComments},
+		{"comments_first_day", r.CommentsFirstDay},
+		{"comments_first_week", r.CommentsFirstWeek},
+		{"lines_changed", r.LinesChanged},
+		{"additions", r.Additions},
+		{"deletions", r.Deletions},
+		// Counts that were not fetched are nil, which passes as 0.
+		{"files_added", intOrZero(r.FilesAdded)},
+		{"files_modified", intOrZero(r.FilesModified)},
+		{"files_removed", intOrZero(r.FilesRemoved)},
+	}
+	for _, c := range counts {
+		if c.v < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", c.name, c.v))
+		}
+	}
+	if r.IssueComments+r.ReviewComments != r.CommentCount {
+		errs = append(errs, fmt.Errorf("issue_comments (%d) + review_comments (%d) does not equal comment_count (%d)", r.IssueComments, r.ReviewComments, r.CommentCount))
+	}
+	if r.BotComments > r.CommentCount {
+		errs = append(errs, fmt.Errorf("bot_comments (%d) exceeds comment_count (%d)", r.BotComments, r.CommentCount))
+	}
+	if r.AuthorComments > r.CommentCount {
+		errs = append(errs, fmt.Errorf("author_comments (%d) exceeds comment_count (%d)", r.AuthorComments, r.CommentCount))
+	}
+	if r.CommentsFirstDay > r.CommentsFirstWeek || r.CommentsFirstWeek > r.CommentCount {
+		errs = append(errs, fmt.Errorf("comments_first_day (%d) <= comments_first_week (%d) <= comment_count (%d) does not hold", r.CommentsFirstDay, r.CommentsFirstWeek, r.CommentCount))
+	}
+	if r.CreatedAt.IsZero() {
+		errs = append(errs, errors.New("created_at is not set"))
+	}
+	if r.OpenDuration < 0 {
+		errs = append(errs, fmt.Errorf("open_duration_days must not be negative, got %g", r.OpenDuration))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid row for PR #%d: %w", r.ID, err)
+	}
+	return nil
+}
+
+func intOrZero(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
 }
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPRRowValidate(t *testing.T) {
+	valid := func() PRRow {
+		three := 3
+		return PRRow{
+			ID:                1,
+			Owner:             "octo",
+			Repo:              "demo",
+			CommentCount:      5,
+			IssueComments:     3,
+			ReviewComments:    2,
+			BotComments:       1,
+			AuthorComments:    2,
+			CommentsFirstDay:  2,
+			CommentsFirstWeek: 4,
+			LinesChanged:      12,
+			Additions:         10,
+			Deletions:         2,
+			FilesAdded:        &three,
+			CreatedAt:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			OpenDuration:      1.5,
+		}
+	}
+	neg := -1
+	tests := []struct {
+		name   string
+		modify func(*PRRow)
+		// want are substrings of the error; none means the row is valid.
+		want []string
+	}{
+		{"valid", func(*PRRow) {}, nil},
+		{"nil optional counts", func(r *PRRow) { r.FilesAdded, r.FilesModified, r.FilesRemoved = nil, nil, nil }, nil},
+		{"zero id", func(r *PRRow) { r.ID = 0 }, []string{"id must be positive, got 0"}},
+		{"missing owner", func(r *PRRow) { r.Owner = "" }, []string{"owner and repo must be set"}},
+		{"missing repo", func(r *PRRow) { r.Repo = "" }, []string{"owner and repo must be set"}},
+		{"negative count", func(r *PRRow) { r.LinesChanged = -3 }, []string{"lines_changed must not be negative, got -3"}},
+		{"negative optional count", func(r *PRRow) { r.FilesRemoved = &neg }, []string{"files_removed must not be negative, got -1"}},
+		{"split does not add up", func(r *PRRow) { r.ReviewComments = 1 }, []string{"issue_comments (3) + review_comments (1) does not equal comment_count (5)"}},
+		{"bot comments exceed total", func(r *PRRow) { r.BotComments = 6 }, []string{"bot_comments (6) exceeds comment_count (5)"}},
+		{"author comments exceed total", func(r *PRRow) { r.AuthorComments = 6 }, []string{"author_comments (6) exceeds comment_count (5)"}},
+		{"first day above first week", func(r *PRRow) { r.CommentsFirstDay = 5 }, []string{"comments_first_day (5) <= comments_first_week (4) <= comment_count (5) does not hold"}},
+		{"first week above total", func(r *PRRow) { r.CommentsFirstWeek = 6 }, []string{"comments_first_week (6)"}},
+		{"no created_at", func(r *PRRow) { r.CreatedAt = time.Time{} }, []string{"created_at is not set"}},
+		{"negative open duration", func(r *PRRow) { r.OpenDuration = -0.5 }, []string{"open_duration_days must not be negative, got -0.5"}},
+		{"several violations", func(r *PRRow) {
+			r.ID, r.Owner, r.CreatedAt = -2, "", time.Time{}
+		}, []string{"invalid row for PR #-2", "id must be positive, got -2", "owner and repo must be set", "created_at is not set"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := valid()
+			tt.modify(&row)
+			err := row.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate = nil, want an error mentioning %q", tt.want)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("Validate = %q, want it to mention %q", err, w)
+				}
+			}
+		})
+	}
+}